@@ -0,0 +1,107 @@
+package address
+
+import (
+	"strings"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+)
+
+// в пакете собрана работа с адресами доставки: разбор адреса, записанного одной строкой,
+// приведение частей к каноническому виду (Normalize) и проверка по набору правил (Validator).
+// Адрес хранится по частям в models.Address, однострочная форма
+// принимается для совместимости с прежними клиентами API и утилиты
+
+// функция Parse разбирает адрес, записанный одной строкой через запятые,
+// например "180000, Псковская обл., г. Псков, ул. Лесная, д. 5, кв. 12",
+// и возвращает его части в каноническом виде.
+// Часть распознается по индексу из 6 цифр, названию страны, словам "обл.", "ул.", "д." и т.п.
+// Части без таких слов заполняют по порядку город и улицу, а начинающиеся с цифры -
+// дом и квартиру. Части, которым не нашлось места, добавляются к городу через запятую,
+// поэтому разбор не теряет данных, а полноту адреса проверяет Validator
+// Параметры
+// line - адрес одной строкой
+func Parse(line string) models.Address {
+	var (
+		a         models.Address
+		unmarked  []string
+		leftovers []string
+	)
+
+	// set записывает часть в поле, если оно еще не заполнено
+	set := func(field *string, part string) {
+		if *field != "" {
+			leftovers = append(leftovers, part)
+			return
+		}
+		*field = part
+	}
+
+	for _, part := range strings.Split(line, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		ws := words(part)
+		first, last := key(ws[0]), key(ws[len(ws)-1])
+		switch {
+		case isPostalCode(part) && a.PostalCode == "":
+			a.PostalCode = part
+		case countryAliases[strings.ToLower(part)] != "":
+			set(&a.Country, part)
+		case hasWord(ws, regionWords):
+			set(&a.Region, part)
+		case cityPrefixes[first] && len(ws) > 1:
+			set(&a.City, part)
+		case len(ws) > 1 && (streetTypes[first] != "" || streetTypes[last] != ""):
+			set(&a.Street, part)
+		case housePrefixes[first] && len(ws) > 1 && startsWithDigit(ws[1]):
+			set(&a.House, part)
+		case buildingWords[first] != "" && a.House != "":
+			// корпус или строение, записанные через запятую после номера дома
+			a.House += " " + part
+		case apartmentPrefixes[first] && len(ws) > 1:
+			set(&a.Apartment, part)
+		default:
+			unmarked = append(unmarked, part)
+		}
+	}
+
+	for _, part := range unmarked {
+		switch {
+		case startsWithDigit(part) && a.House == "":
+			a.House = part
+		case startsWithDigit(part) && a.Apartment == "":
+			a.Apartment = part
+		case !startsWithDigit(part) && a.City == "":
+			a.City = part
+		case !startsWithDigit(part) && a.Street == "":
+			a.Street = part
+		default:
+			leftovers = append(leftovers, part)
+		}
+	}
+
+	if len(leftovers) > 0 {
+		a.City = strings.Join(append([]string{a.City}, leftovers...), ", ")
+		a.City = strings.TrimPrefix(a.City, ", ")
+	}
+
+	return Normalize(a)
+}
+
+// функция isPostalCode проверяет, что часть адреса похожа на почтовый индекс России
+func isPostalCode(part string) bool {
+	return len(part) == postalCodeLen && strings.Trim(part, "0123456789") == ""
+}
+
+// функция hasWord проверяет, что одно из слов ws есть в таблице сокращений table
+func hasWord(ws []string, table map[string]string) bool {
+	for _, w := range ws {
+		if table[key(w)] != "" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+package address
+
+import (
+	// импортируем пакеты standard library
+	"strings"
+	"testing"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// TestParse проверяет разбор адреса, записанного одной строкой
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want models.Address
+	}{
+		{
+			line: "180000, Россия, Псковская обл., г. Псков, ул. Лесная, д. 5, кв. 12",
+			want: models.Address{PostalCode: "180000", Country: "Россия", Region: "Псковская обл.",
+				City: "Псков", Street: "ул. Лесная", House: "5", Apartment: "12"},
+		},
+		{
+			// полные слова, тип улицы после названия, корпус через запятую
+			line: "РФ, Псковская область, город Псков, Лесная улица, дом 5, корпус 2, квартира 12",
+			want: models.Address{Country: "Россия", Region: "Псковская обл.",
+				City: "Псков", Street: "ул. Лесная", House: "5 корп. 2", Apartment: "12"},
+		},
+		{
+			// части без слов-признаков и сокращения без пробела
+			line: " Москва ,пр-т.Мира,  д.1 ,  ",
+			want: models.Address{City: "Москва", Street: "пр-т Мира", House: "1"},
+		},
+		{
+			line: "Москва, Тверская, 1, 5",
+			want: models.Address{City: "Москва", Street: "Тверская", House: "1", Apartment: "5"},
+		},
+		{
+			// "д." перед названием - деревня, а не дом: часть добавляется к населенному пункту
+			line: "Псков, д. Пушкина, ул. Колотушкина, д. 5",
+			want: models.Address{City: "Псков, д. Пушкина", Street: "ул. Колотушкина", House: "5"},
+		},
+		{
+			line: "test",
+			want: models.Address{City: "test"},
+		},
+		{
+			line: " , ",
+			want: models.Address{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			assert.Equal(t, tt.want, Parse(tt.line))
+		})
+	}
+}
+
+// TestParseString проверяет, что однострочная форма адреса разбирается обратно в тот же адрес
+func TestParseString(t *testing.T) {
+	a := models.Address{PostalCode: "190000", Country: "Россия", Region: "Ленинградская обл.",
+		City: "Санкт-Петербург", Street: "наб. Фонтанки", House: "10 стр. 1", Apartment: "3"}
+
+	assert.Equal(t, "190000, Россия, Ленинградская обл., Санкт-Петербург, наб. Фонтанки, д. 10 стр. 1, кв. 3", a.String())
+	assert.Equal(t, a, Parse(a.String()))
+}
+
+// TestNormalize проверяет приведение частей адреса к каноническому виду
+func TestNormalize(t *testing.T) {
+	a := Normalize(models.Address{
+		PostalCode: " 180 000 ",
+		Country:    "российская  федерация",
+		Region:     "Республика Татарстан",
+		City:       "г.Казань",
+		Street:     "Проспект  Победы",
+		House:      "Д. 5 Строение 2",
+		Apartment:  "кв 7",
+	})
+
+	assert.Equal(t, models.Address{PostalCode: "180000", Country: "Россия", Region: "респ. Татарстан",
+		City: "Казань", Street: "пр-т Победы", House: "5 стр. 2", Apartment: "7"}, a)
+
+	// нормализация канонического адреса ничего не меняет
+	assert.Equal(t, a, Normalize(a))
+
+	// одно слово не считается сокращением: город "Г" или улица "Шоссе" остаются как есть
+	assert.Equal(t, models.Address{City: "Г", Street: "Шоссе"}, Normalize(models.Address{City: "Г", Street: "Шоссе"}))
+}
+
+// TestValidator проверяет правила проверки адреса
+func TestValidator(t *testing.T) {
+	valid := models.Address{PostalCode: "180000", City: "Псков", Street: "ул. Лесная", House: "5"}
+	require.NoError(t, DefaultValidator().Validate(valid))
+
+	tests := []struct {
+		name  string
+		edit  func(a *models.Address)
+		field string
+	}{
+		{"пустой адрес", func(a *models.Address) { *a = models.Address{} }, "address"},
+		{"нет города", func(a *models.Address) { a.City = "" }, FieldCity},
+		{"нет дома", func(a *models.Address) { a.House = "" }, FieldHouse},
+		{"короткий индекс", func(a *models.Address) { a.PostalCode = "18000" }, FieldPostalCode},
+		{"индекс не из цифр", func(a *models.Address) { a.PostalCode = "18000A" }, FieldPostalCode},
+		{"длинная улица", func(a *models.Address) { a.Street = strings.Repeat("я", maxPartLen+1) }, FieldStreet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid
+			tt.edit(&a)
+
+			var validationErr *errors.ValidationError
+			require.ErrorAs(t, DefaultValidator().Validate(a), &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+		})
+	}
+
+	// индекс другой страны правилом России не проверяется
+	foreign := valid
+	foreign.Country = "Казахстан"
+	foreign.PostalCode = "A05T3C1"
+	assert.NoError(t, DefaultValidator().Validate(foreign))
+
+	// дополнительное правило
+	withApartment := DefaultValidator().With(Required(FieldApartment))
+	assert.ErrorIs(t, withApartment.Validate(valid), errors.ErrValidation)
+	assert.NoError(t, DefaultValidator().Validate(valid))
+}
@@ -0,0 +1,178 @@
+package address
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+)
+
+// в файле собрано приведение частей адреса к каноническому виду.
+// Слова сравниваются без учета регистра и точки в конце, поэтому
+// "Улица", "ул" и "ул." распознаются одинаково
+
+// CountryRussia - каноническое название России
+const CountryRussia = "Россия"
+
+// countryAliases - написания названия страны, приводимые к каноническому
+var countryAliases = map[string]string{
+	"россия": CountryRussia,
+	"рф":     CountryRussia,
+	"российская федерация": CountryRussia,
+	"russia": CountryRussia,
+}
+
+// regionWords - слова, по которым часть адреса распознается как регион,
+// и их сокращения
+var regionWords = map[string]string{
+	"область":    "обл.",
+	"обл":        "обл.",
+	"республика": "респ.",
+	"респ":       "респ.",
+	"край":       "край",
+	"ао":         "АО",
+}
+
+// streetTypes - типы улиц и их сокращения
+var streetTypes = map[string]string{
+	"улица":      "ул.",
+	"ул":         "ул.",
+	"проспект":   "пр-т",
+	"просп":      "пр-т",
+	"пр-т":       "пр-т",
+	"переулок":   "пер.",
+	"пер":        "пер.",
+	"бульвар":    "б-р",
+	"бул":        "б-р",
+	"б-р":        "б-р",
+	"шоссе":      "ш.",
+	"ш":          "ш.",
+	"площадь":    "пл.",
+	"пл":         "пл.",
+	"набережная": "наб.",
+	"наб":        "наб.",
+	"проезд":     "проезд",
+	"пр-д":       "проезд",
+	"тупик":      "туп.",
+	"туп":        "туп.",
+	"микрорайон": "мкр",
+	"мкр":        "мкр",
+	"аллея":      "аллея",
+}
+
+// buildingWords - части номера дома после основного номера и их сокращения
+var buildingWords = map[string]string{
+	"корпус":   "корп.",
+	"корп":     "корп.",
+	"строение": "стр.",
+	"стр":      "стр.",
+	"литера":   "лит.",
+	"лит":      "лит.",
+}
+
+// слова, которые стоят перед названием города, номером дома и квартиры
+// и отбрасываются при нормализации
+var (
+	cityPrefixes      = map[string]bool{"г": true, "город": true}
+	housePrefixes     = map[string]bool{"д": true, "дом": true}
+	apartmentPrefixes = map[string]bool{"кв": true, "квартира": true}
+)
+
+// функция Normalize приводит части адреса к каноническому виду:
+// убирает лишние пробелы, сокращает типы улиц и регионов,
+// отбрасывает "г.", "д." и "кв." перед городом, домом и квартирой
+// и приводит написание страны к одному виду ("РФ" -> "Россия").
+// Адрес, уже приведенный к каноническому виду, не меняется
+// Параметры
+// a - адрес
+func Normalize(a models.Address) models.Address {
+	a.PostalCode = strings.Join(strings.Fields(a.PostalCode), "")
+
+	a.Country = strings.Join(strings.Fields(a.Country), " ")
+	if canonical, ok := countryAliases[strings.ToLower(a.Country)]; ok {
+		a.Country = canonical
+	}
+
+	region := words(a.Region)
+	for i, w := range region {
+		if canonical, ok := regionWords[key(w)]; ok {
+			region[i] = canonical
+		}
+	}
+	a.Region = strings.Join(region, " ")
+
+	a.City = strings.Join(trimPrefix(words(a.City), cityPrefixes), " ")
+	a.Street = normalizeStreet(words(a.Street))
+	a.House = normalizeHouse(trimPrefix(words(a.House), housePrefixes))
+	a.Apartment = strings.Join(trimPrefix(words(a.Apartment), apartmentPrefixes), " ")
+
+	return a
+}
+
+// функция normalizeStreet ставит сокращенный тип улицы перед названием:
+// "Лесная улица" и "улица Лесная" становятся "ул. Лесная"
+func normalizeStreet(street []string) string {
+	if len(street) > 1 {
+		if canonical, ok := streetTypes[key(street[0])]; ok {
+			street[0] = canonical
+		} else if canonical, ok := streetTypes[key(street[len(street)-1])]; ok {
+			street = append([]string{canonical}, street[:len(street)-1]...)
+		}
+	}
+
+	return strings.Join(street, " ")
+}
+
+// функция normalizeHouse сокращает слова "корпус", "строение" и "литера" в номере дома
+func normalizeHouse(house []string) string {
+	for i, w := range house {
+		if canonical, ok := buildingWords[key(w)]; ok {
+			house[i] = canonical
+		}
+	}
+
+	return strings.Join(house, " ")
+}
+
+// функция trimPrefix отбрасывает первое слово, если оно есть в prefixes
+// и за ним следует еще хотя бы одно слово
+func trimPrefix(ws []string, prefixes map[string]bool) []string {
+	if len(ws) > 1 && prefixes[key(ws[0])] {
+		return ws[1:]
+	}
+
+	return ws
+}
+
+// функция words разбивает часть адреса на слова по пробелам.
+// Сокращение, записанное слитно со следующим словом ("ул.Лесная", "д.5"),
+// разделяется на два слова
+func words(s string) []string {
+	fields := strings.Fields(s)
+	res := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if i := strings.Index(f, "."); i > 0 && i < len(f)-1 && hasLetter(f[:i]) {
+			res = append(res, f[:i+1], f[i+1:])
+			continue
+		}
+		res = append(res, f)
+	}
+
+	return res
+}
+
+// функция key возвращает слово в нижнем регистре без точки в конце,
+// по нему слово ищется в таблицах сокращений
+func key(w string) string {
+	return strings.TrimSuffix(strings.ToLower(w), ".")
+}
+
+// функция hasLetter проверяет, что в строке есть буква
+func hasLetter(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
+
+// функция startsWithDigit проверяет, что строка начинается с цифры
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
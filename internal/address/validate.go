@@ -0,0 +1,141 @@
+package address
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// названия частей адреса в ошибках проверки
+const (
+	FieldPostalCode = "address.postal_code"
+	FieldCountry    = "address.country"
+	FieldRegion     = "address.region"
+	FieldCity       = "address.city"
+	FieldStreet     = "address.street"
+	FieldHouse      = "address.house"
+	FieldApartment  = "address.apartment"
+)
+
+// ограничения на адрес
+const (
+	postalCodeLen = 6   // длина почтового индекса России
+	maxPartLen    = 256 // наибольшая длина части адреса в символах
+)
+
+// Rule - правило проверки адреса: возвращает errors.ValidationError
+// с названием нарушенной части или nil
+type Rule func(a models.Address) error
+
+// определяем структурный тип Validator - проверка адреса по набору правил.
+// Значение неизменяемо: With возвращает новый Validator
+type Validator struct {
+	rules []Rule // правила в порядке проверки
+}
+
+// функция NewValidator возвращает Validator с правилами rules
+func NewValidator(rules ...Rule) Validator {
+	return Validator{rules: rules}
+}
+
+// функция DefaultValidator возвращает проверку, которую по умолчанию использует сервис посылок:
+// обязательны город, улица и дом, части не длиннее maxPartLen символов,
+// индекс адреса в России состоит из 6 цифр
+func DefaultValidator() Validator {
+	return NewValidator(
+		Required(FieldCity), Required(FieldStreet), Required(FieldHouse),
+		MaxLength(maxPartLen),
+		RussianPostalCode,
+	)
+}
+
+// Метод With типа Validator возвращает проверку с дополнительными правилами rules
+func (v Validator) With(rules ...Rule) Validator {
+	return Validator{rules: append(append([]Rule(nil), v.rules...), rules...)}
+}
+
+// Метод Validate типа Validator проверяет адрес и возвращает ошибку первого нарушенного правила.
+// Пустой адрес отклоняется всегда, независимо от правил
+// Параметры
+// a - адрес, как правило уже приведенный Normalize
+func (v Validator) Validate(a models.Address) error {
+	if a == (models.Address{}) {
+		return errors.Validation("address", "адрес не может быть пустым")
+	}
+
+	for _, rule := range v.rules {
+		if err := rule(a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// функция Required возвращает правило, по которому часть адреса field обязательна
+// Параметры
+// field - название части, одна из констант Field...
+func Required(field string) Rule {
+	return func(a models.Address) error {
+		if fieldValue(a, field) == "" {
+			return errors.Validation(field, "часть адреса обязательна")
+		}
+		return nil
+	}
+}
+
+// функция MaxLength возвращает правило, ограничивающее длину каждой части адреса
+// Параметры
+// n - наибольшая длина части в символах
+func MaxLength(n int) Rule {
+	return func(a models.Address) error {
+		for _, field := range fields {
+			if utf8.RuneCountInString(fieldValue(a, field)) > n {
+				return errors.Validation(field, fmt.Sprintf("часть адреса длиннее %d символов", n))
+			}
+		}
+		return nil
+	}
+}
+
+// RussianPostalCode - правило, по которому индекс адреса в России (или без указания страны),
+// если он указан, состоит из 6 цифр
+func RussianPostalCode(a models.Address) error {
+	if a.PostalCode == "" || (a.Country != "" && a.Country != CountryRussia) {
+		return nil
+	}
+
+	if !isPostalCode(a.PostalCode) {
+		return errors.Validation(FieldPostalCode,
+			fmt.Sprintf("индекс %q должен состоять из %d цифр", a.PostalCode, postalCodeLen))
+	}
+
+	return nil
+}
+
+// fields - названия частей адреса в порядке от индекса к квартире
+var fields = []string{FieldPostalCode, FieldCountry, FieldRegion, FieldCity, FieldStreet, FieldHouse, FieldApartment}
+
+// функция fieldValue возвращает значение части адреса по ее названию
+func fieldValue(a models.Address, field string) string {
+	switch field {
+	case FieldPostalCode:
+		return a.PostalCode
+	case FieldCountry:
+		return a.Country
+	case FieldRegion:
+		return a.Region
+	case FieldCity:
+		return a.City
+	case FieldStreet:
+		return a.Street
+	case FieldHouse:
+		return a.House
+	case FieldApartment:
+		return a.Apartment
+	default:
+		panic("неизвестная часть адреса " + field)
+	}
+}
@@ -0,0 +1,312 @@
+package cli
+
+import (
+	"context"
+	stderrors "errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/pricing"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+)
+
+// в пакете реализована утилита командной строки tracker:
+// каждая подкоманда вызывает один метод ParcelService и выводит результат
+// в виде таблицы, JSON или текста, а вид ошибки определяет код выхода.
+// Журнал сервиса пишется в stderr: по умолчанию только предупреждения и ошибки,
+// с флагом --verbose - и сведения об операциях
+
+// коды выхода утилиты
+const (
+	ExitOK       = 0 // команда выполнена успешно
+	ExitError    = 1 // внутренняя ошибка: БД недоступна, сбой запроса и т.п.
+	ExitUsage    = 2 // неверные аргументы или данные не прошли проверку
+	ExitNotFound = 3 // посылка или клиент не найдены
+	ExitConflict = 4 // операция недопустима в текущем статусе посылки
+)
+
+// форматы вывода результата
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputText  = "text" // посылки и события предложениями, см. пакет render
+)
+
+// usage - справка по утилите
+const usage = `Использование: tracker <команда> [флаги] [аргументы]
+
+Команды:
+  register --client ID --recipient-name ИМЯ --weight ГРАММЫ [флаги адреса, сторон и посылки]
+           [--idempotency-key КЛЮЧ]      зарегистрировать посылку; повтор с тем же ключом
+                                         возвращает посылку, зарегистрированную первым запросом
+  quote --weight ГРАММЫ [флаги адреса, сторон и посылки]
+                                         рассчитать стоимость доставки до регистрации
+  get НОМЕР                              показать посылку
+  track КОД                              показать посылку по коду отслеживания, например RR123456785RU
+  list [флаги выборки]                   показать посылки постранично
+  next-status НОМЕР                      перевести посылку в следующий статус
+  status НОМЕР --to СТАТУС               перевести посылку в указанный статус
+  history НОМЕР                          показать историю статусов посылки
+  set-address НОМЕР [флаги адреса]       изменить адрес посылки
+  delete НОМЕР                           удалить зарегистрированную посылку; ее можно
+                                         восстановить до окончательного удаления
+  restore НОМЕР                          восстановить удаленную посылку
+  purge [--retention 720h]               окончательно удалить посылки, удаленные раньше
+                                         срока хранения (по умолчанию 720h - 30 дней)
+  owner НОМЕР                            показать клиента, которому принадлежит посылка
+  export [--format csv|ndjson] [--file ФАЙЛ] [--client ID] [--status СТАТУС,...]
+         [--from ВРЕМЯ] [--until ВРЕМЯ] [--month ГГГГ-ММ]
+                                         выгрузить посылки в файл или stdout,
+                                         --month - созданные в календарном месяце
+  import ФАЙЛ [--format csv|ndjson] [--dry-run] [--report ФАЙЛ]
+                                         зарегистрировать посылки из файла, --dry-run - только
+                                         проверить; ошибки записей - в отчет или stderr
+  serve [--addr :8080]                   запустить HTTP API
+
+  client-add --name ИМЯ [--phone ТЕЛЕФОН] [--email ПОЧТА]   создать клиента
+  client-get ID                          показать клиента
+  client-list [--name ПОДСТРОКА] [--all] [--after ID] [--limit N]
+                                         показать клиентов, --all - включая деактивированных
+  client-update ID [--name ИМЯ] [--phone ТЕЛЕФОН] [--email ПОЧТА]
+                                         изменить переданные данные клиента
+  client-deactivate ID                   деактивировать клиента
+
+Флаги адреса register и set-address:
+  --address АДРЕС              адрес одной строкой: "180000, Псковская обл., г. Псков, ул. Лесная, д. 5, кв. 12"
+  --postal-code, --country, --region, --city, --street, --house, --apartment
+                               части адреса, заменяют разобранные из --address.
+                               Обязательны город, улица и дом.
+                               У register это адрес получателя
+
+Флаги сторон register и quote:
+  --recipient-name ИМЯ, --recipient-phone ТЕЛЕФОН   получатель, имя обязательно
+  --sender-name ИМЯ            отправитель, по умолчанию имя клиента
+  --sender-phone ТЕЛЕФОН, --sender-address АДРЕС    телефон и адрес отправителя
+  --return-address АДРЕС       адрес возврата, по умолчанию адрес отправителя
+
+Флаги посылки register и quote:
+  --service-level standard|express   уровень обслуживания (по умолчанию standard)
+  --weight ГРАММЫ              вес, обязателен; наибольший вес зависит от уровня обслуживания
+  --length, --width, --height СМ     габариты, указываются все три или ни одного
+  --contents ОПИСАНИЕ          описание вложения
+  --declared-value КОПЕЙКИ     объявленная ценность
+
+Флаги выборки list:
+  --client ID                  посылки клиента
+  --status СТАТУС,...          посылки в одном из статусов
+  --from, --until ВРЕМЯ        созданные в периоде [from, until), формат RFC 3339
+  --address ПОДСТРОКА          адрес содержит подстроку
+  --recipient-phone ТЕЛЕФОН    посылки, у которых указанный получатель
+  --order-by number|created_at --desc   порядок сортировки
+  --limit N                    размер страницы (по умолчанию 100, не более 1000)
+  --cursor КУРСОР              следующая страница, курсор выводится после предыдущей
+  --include-deleted            включая удаленные посылки, которые еще можно восстановить
+
+Файлы export и import:
+  формат по умолчанию выбирается по расширению: .ndjson и .jsonl - NDJSON, иначе CSV.
+  Колонки CSV: number, tracking_code, client, status, service_level, weight, length, width,
+  height, contents, declared_value, cost, sender_name, sender_phone, sender_address,
+  recipient_name, recipient_phone, recipient_address, return_address, created_at,
+  updated_at, sent_at, delivered_at. Суммы - в копейках, время - RFC 3339 (UTC).
+  При загрузке number, tracking_code, status, cost и время пропускаются: посылки
+  регистрируются заново. Строка NDJSON - посылка в формате JSON, как в выводе --output json
+
+Общие флаги:
+  --db DSN           путь к файлу SQLite или строка подключения postgres://... (по умолчанию tracker.db)
+  --tariffs ФАЙЛ     таблица тарифов в формате JSON (по умолчанию встроенная)
+  --output ФОРМАТ    формат вывода: table, json или text (по умолчанию table)
+  --verbose          выводить журнал операций в stderr
+
+Коды выхода: 0 - успех, 1 - внутренняя ошибка, 2 - неверные аргументы,
+3 - посылка или клиент не найдены, 4 - операция недопустима в текущем статусе посылки
+`
+
+// определяем структурный тип env - окружение, в котором выполняется подкоманда
+type env struct {
+	service serv.ParcelService // сервис посылок поверх открытой БД
+	clients serv.ClientService // сервис клиентов поверх открытой БД
+	logger  *slog.Logger       // журнал, общий для сервисов и HTTP API
+	out     printer            // вывод результата в выбранном формате
+	stderr  io.Writer          // поток для служебных сообщений
+}
+
+// определяем структурный тип command - описание подкоманды
+type command struct {
+	// flags объявляет флаги подкоманды в дополнение к общим
+	flags func(fs *flag.FlagSet)
+	// args - число обязательных позиционных аргументов
+	args int
+	// run выполняет подкоманду
+	run func(ctx context.Context, e env, args []string) error
+}
+
+// функция Run разбирает аргументы командной строки, выполняет подкоманду
+// и возвращает код выхода
+// Параметры
+// ctx - контекст, отмена которого прерывает выполнение команды
+// args - аргументы командной строки без имени программы
+// stdout - поток для результата команды
+// stderr - поток для сообщений об ошибках и справки
+func Run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stderr, usage)
+		if len(args) == 0 {
+			return ExitUsage
+		}
+		return ExitOK
+	}
+
+	name := args[0]
+	cmd, ok := commands()[name]
+	if !ok {
+		fmt.Fprintf(stderr, "неизвестная команда %q\n\n%s", name, usage)
+		return ExitUsage
+	}
+
+	// общие флаги и флаги подкоманды разбираются одним набором
+	fs := flag.NewFlagSet("tracker "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dsn := fs.String("db", "tracker.db", "путь к файлу SQLite или строка подключения postgres://...")
+	output := fs.String("output", OutputTable, "формат вывода: table, json или text")
+	verbose := fs.Bool("verbose", false, "выводить журнал операций в stderr")
+	tariffsPath := fs.String("tariffs", "", "таблица тарифов в формате JSON")
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	if len(positional) != cmd.args {
+		fmt.Fprintf(stderr, "команда %s ожидает аргументов: %d, передано: %d\n", name, cmd.args, len(positional))
+		return ExitUsage
+	}
+
+	out, err := newPrinter(*output, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	tariffs := pricing.DefaultTable()
+	if *tariffsPath != "" {
+		if tariffs, err = pricing.LoadFile(*tariffsPath); err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitUsage
+		}
+	}
+
+	// подключаемся к БД и приводим схему к актуальной версии
+	db, err := store.Open(ctx, *dsn)
+	if err != nil {
+		fmt.Fprintf(stderr, "Возникла ошибка при подключении к базе данных: %v\n", err)
+		return ExitError
+	}
+
+	defer db.Close()
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+
+	e := env{
+		service: serv.NewParcelService(db.Parcels(), db.Clients(), serv.WithLogger(logger), serv.WithTariffs(tariffs)),
+		clients: serv.NewClientService(db.Clients(), serv.WithLogger(logger)),
+		logger:  logger,
+		out:     out,
+		stderr:  stderr,
+	}
+
+	if err := cmd.run(ctx, e, positional); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCode(err)
+	}
+
+	return ExitOK
+}
+
+// функция parseInterspersed разбирает флаги, которые могут стоять
+// как до, так и после позиционных аргументов (tracker get 42 --output json),
+// и возвращает позиционные аргументы
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		// первый оставшийся аргумент - позиционный, после него снова могут идти флаги
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// функция exitCode возвращает код выхода для ошибки команды
+func exitCode(err error) int {
+	switch {
+	case stderrors.Is(err, errors.ErrValidation), stderrors.Is(err, errUsage):
+		return ExitUsage
+	case stderrors.Is(err, errors.ErrNotFound):
+		return ExitNotFound
+	case stderrors.Is(err, errors.ErrInvalidState):
+		return ExitConflict
+	default:
+		return ExitError
+	}
+}
+
+// errUsage - ошибка неверных аргументов команды
+var errUsage = stderrors.New("неверные аргументы")
+
+// функция parseTime разбирает необязательное значение флага с датой и временем в формате RFC 3339
+func parseTime(flagName string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s ожидает дату и время в формате RFC 3339, получено %q", errUsage, flagName, value)
+	}
+
+	return t, nil
+}
+
+// функция parseNumber разбирает номер посылки из позиционного аргумента
+func parseNumber(arg string) (int, error) {
+	number, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("%w: номер посылки должен быть положительным целым числом, получено %q", errUsage, arg)
+	}
+
+	return number, nil
+}
+
+// функция parseID разбирает идентификатор клиента из позиционного аргумента
+func parseID(arg string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: идентификатор клиента должен быть положительным целым числом, получено %q", errUsage, arg)
+	}
+
+	return id, nil
+}
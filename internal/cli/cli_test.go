@@ -0,0 +1,347 @@
+package cli
+
+import (
+	// импортируем пакеты standard library
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/pricing"
+)
+
+// run выполняет команду утилиты на БД dsn и возвращает код выхода и вывод
+func run(t *testing.T, dsn string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), append(args, "--db", dsn), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+// TestCommands проверяет основной сценарий работы с посылкой через утилиту
+func TestCommands(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "tracker.db")
+
+	// клиент, которому принадлежат посылки
+	code, out, errOut := run(t, dsn, "client-add", "--name", "Иван Петров", "--phone", "+7 999 123-45-67", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	var client models.Client
+	require.NoError(t, json.Unmarshal([]byte(out), &client))
+	assert.Equal(t, 1, client.ID)
+	assert.Equal(t, "+79991234567", client.Phone)
+
+	// регистрация
+	code, out, errOut = run(t, dsn, "register", "--client", "1",
+		"--recipient-name", "Анна Смирнова", "--recipient-phone", "+7 999 765-43-21", "--address", "Псков, ул. Лесная, д. 1",
+		"--sender-address", "Псков, ул. Садовая, д. 2",
+		"--weight", "2500", "--length", "40", "--width", "30", "--height", "20", "--contents", "книги", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	var parcel models.Parcel
+	require.NoError(t, json.Unmarshal([]byte(out), &parcel))
+	assert.Equal(t, 1, parcel.Number)
+	assert.Equal(t, constants.ParcelStatusRegistered, parcel.Status)
+	assert.Equal(t, models.Party{Name: "Иван Петров", Address: models.Address{City: "Псков", Street: "ул. Садовая", House: "2"}},
+		parcel.Sender)
+	assert.Equal(t, "+79997654321", parcel.Recipient.Phone)
+	assert.Equal(t, constants.ServiceLevelStandard, parcel.ServiceLevel)
+	assert.Equal(t, 2500, parcel.Weight)
+	assert.Equal(t, 4800, parcel.VolumetricWeight())
+	assert.Equal(t, int64(30000), parcel.Cost)
+
+	// флаги можно указывать после позиционных аргументов
+	code, out, errOut = run(t, dsn, "get", "1", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	var stored models.Parcel
+	require.NoError(t, json.Unmarshal([]byte(out), &stored))
+	assert.Equal(t, parcel, stored)
+
+	// поиск по коду отслеживания
+	code, out, errOut = run(t, dsn, "track", parcel.TrackingCode, "--output", "text")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, out, "Посылка № 1 ")
+	assert.Contains(t, out, "код отслеживания "+parcel.TrackingCode)
+
+	// изменение адреса, табличный вывод
+	code, out, errOut = run(t, dsn, "set-address", "1", "--address", "Псков, Новая улица, дом 7", "--apartment", "3")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, out, "НОМЕР")
+	assert.Contains(t, out, "Псков, ул. Новая, д. 7, кв. 3")
+
+	// смена статуса
+	code, _, errOut = run(t, dsn, "next-status", "1")
+	require.Equal(t, ExitOK, code, errOut)
+	code, out, errOut = run(t, dsn, "status", "1", "--to", "lost", "--actor", "operator-7")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, out, constants.ParcelStatusLost)
+
+	// владелец посылки
+	code, out, errOut = run(t, dsn, "owner", "1")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, out, "Иван Петров")
+
+	// история
+	code, out, errOut = run(t, dsn, "history", "1", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	var events []models.StatusEvent
+	require.NoError(t, json.Unmarshal([]byte(out), &events))
+	require.Len(t, events, 2)
+	assert.Equal(t, "operator-7", events[1].Actor)
+
+	// список посылок клиента
+	code, out, errOut = run(t, dsn, "list", "--client", "1", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	var page models.ParcelPage
+	require.NoError(t, json.Unmarshal([]byte(out), &page))
+	assert.Len(t, page.Parcels, 1)
+	assert.Empty(t, page.NextCursor)
+
+	// список по статусу
+	code, out, errOut = run(t, dsn, "list", "--status", "registered,sent", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	require.NoError(t, json.Unmarshal([]byte(out), &page))
+	assert.Empty(t, page.Parcels)
+
+	// текстовый вывод посылок клиента
+	code, out, errOut = run(t, dsn, "list", "--client", "1", "--output", "text")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, out, "Посылки клиента 1:")
+	assert.Contains(t, out, "Посылка № 1 для Анна Смирнова на адрес Псков, ул. Новая, д. 7, кв. 3")
+
+	// посылки получателя
+	code, out, errOut = run(t, dsn, "list", "--recipient-phone", "+79997654321", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	require.NoError(t, json.Unmarshal([]byte(out), &page))
+	assert.Len(t, page.Parcels, 1)
+
+	// удаление зарегистрированной посылки, журнал операций в stderr
+	code, _, errOut = run(t, dsn, "register", "--client", "1", "--recipient-name", "Анна Смирнова", "--weight", "1000", "--address", "Псков, ул. Лесная, д. 1", "--verbose")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, errOut, "посылка зарегистрирована")
+	assert.Contains(t, errOut, "parcel=2")
+	code, _, errOut = run(t, dsn, "delete", "2")
+	require.Equal(t, ExitOK, code, errOut)
+
+	// удаленная посылка видна только с --include-deleted и восстанавливается
+	code, out, errOut = run(t, dsn, "list", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	require.NoError(t, json.Unmarshal([]byte(out), &page))
+	assert.Len(t, page.Parcels, 1)
+	code, out, errOut = run(t, dsn, "list", "--include-deleted")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, out, "registered (удалена)")
+	code, out, errOut = run(t, dsn, "restore", "2", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	require.NoError(t, json.Unmarshal([]byte(out), &parcel))
+	assert.Equal(t, 2, parcel.Number)
+	assert.Nil(t, parcel.DeletedAt)
+
+	// окончательное удаление учитывает срок хранения
+	code, _, errOut = run(t, dsn, "delete", "2")
+	require.Equal(t, ExitOK, code, errOut)
+	code, out, errOut = run(t, dsn, "purge")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Equal(t, "Окончательно удалено посылок: 0\n", out)
+	code, out, errOut = run(t, dsn, "purge", "--retention", "0s", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.JSONEq(t, `{"purged": 1}`, out)
+	code, _, errOut = run(t, dsn, "restore", "2")
+	require.Equal(t, ExitNotFound, code, errOut)
+
+	// повтор регистрации с ключом идемпотентности не создает новую посылку
+	for i := 0; i < 2; i++ {
+		code, out, errOut = run(t, dsn, "register", "--client", "1", "--recipient-name", "Анна Смирнова", "--weight", "1000",
+			"--address", "Псков, ул. Лесная, д. 1", "--idempotency-key", "order-1", "--output", "json")
+		require.Equal(t, ExitOK, code, errOut)
+		require.NoError(t, json.Unmarshal([]byte(out), &parcel))
+		assert.Equal(t, 3, parcel.Number)
+	}
+	code, _, errOut = run(t, dsn, "register", "--client", "1", "--recipient-name", "Анна Смирнова", "--weight", "2000",
+		"--address", "Псков, ул. Лесная, д. 1", "--idempotency-key", "order-1")
+	assert.Equal(t, ExitUsage, code, errOut)
+
+	// изменение и деактивация клиента
+	code, out, errOut = run(t, dsn, "client-update", "1", "--email", "ivan@example.com", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	require.NoError(t, json.Unmarshal([]byte(out), &client))
+	assert.Equal(t, "Иван Петров", client.Name)
+	assert.Equal(t, "ivan@example.com", client.Email)
+
+	code, _, errOut = run(t, dsn, "client-deactivate", "1")
+	require.Equal(t, ExitOK, code, errOut)
+	code, out, errOut = run(t, dsn, "client-list", "--all", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	var clients []models.Client
+	require.NoError(t, json.Unmarshal([]byte(out), &clients))
+	require.Len(t, clients, 1)
+	assert.False(t, clients[0].Active)
+}
+
+// TestQuote проверяет расчет стоимости доставки через утилиту
+func TestQuote(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "tracker.db")
+
+	code, out, errOut := run(t, dsn, "quote", "--weight", "2500", "--address", "Псков, ул. Лесная, д. 1", "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	var quote pricing.Quote
+	require.NoError(t, json.Unmarshal([]byte(out), &quote))
+	assert.Equal(t, pricing.ZoneLocal, quote.Zone)
+	assert.Equal(t, int64(30000), quote.Price)
+
+	code, out, errOut = run(t, dsn, "quote", "--weight", "2500", "--address", "Псков, ул. Лесная, д. 1", "--output", "text")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Equal(t, "Доставка standard в зону local, оплачиваемый вес 2500 г: 300.00 руб.\n", out)
+
+	// тарифы из файла
+	tariffs := filepath.Join(t.TempDir(), "tariffs.json")
+	require.NoError(t, os.WriteFile(tariffs, []byte(`{
+		"origin": {"city": "Псков"},
+		"levels": {"standard": {"local": [{"max_weight": 31500, "price": 12345}]}}
+	}`), 0o600))
+	code, out, errOut = run(t, dsn, "quote", "--weight", "2500", "--address", "Псков, ул. Лесная, д. 1", "--tariffs", tariffs)
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, out, "СТОИМОСТЬ")
+	assert.Contains(t, out, "123.45")
+
+	// некорректный файл тарифов
+	require.NoError(t, os.WriteFile(tariffs, []byte(`{"levels": {}}`), 0o600))
+	code, _, errOut = run(t, dsn, "quote", "--weight", "2500", "--address", "Псков, ул. Лесная, д. 1", "--tariffs", tariffs)
+	assert.Equal(t, ExitUsage, code)
+	assert.NotEmpty(t, errOut)
+
+	// направление без тарифа
+	code, _, errOut = run(t, dsn, "quote", "--weight", "2500", "--service-level", "express", "--country", "Казахстан", "--address", "Алматы, пр-т Абая, д. 1")
+	assert.Equal(t, ExitUsage, code)
+	assert.NotEmpty(t, errOut)
+}
+
+// TestExportImport проверяет выгрузку посылок за месяц и загрузку файла в другую БД
+func TestExportImport(t *testing.T) {
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "tracker.db")
+
+	code, _, errOut := run(t, dsn, "client-add", "--name", "Иван Петров")
+	require.Equal(t, ExitOK, code, errOut)
+	for _, house := range []string{"1", "2"} {
+		code, _, errOut = run(t, dsn, "register", "--client", "1", "--recipient-name", "Анна Смирнова",
+			"--address", "Псков, ул. Лесная, д. "+house, "--weight", "1000")
+		require.Equal(t, ExitOK, code, errOut)
+	}
+
+	// выгрузка в stdout: посылки созданы в текущем месяце, а не в прошлом году
+	month := time.Now().UTC().Format("2006-01")
+	code, out, errOut := run(t, dsn, "export", "--month", month)
+	require.Equal(t, ExitOK, code, errOut)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "number,tracking_code,client,"))
+
+	code, out, errOut = run(t, dsn, "export", "--month", time.Now().UTC().AddDate(-1, 0, 0).Format("2006-01"))
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+
+	// выгрузка в файл NDJSON
+	file := filepath.Join(dir, "parcels.ndjson")
+	code, _, errOut = run(t, dsn, "export", "--file", file)
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Contains(t, errOut, "Выгружено посылок: 2")
+
+	// пробная загрузка в другую БД не регистрирует посылки
+	other := filepath.Join(dir, "other.db")
+	code, _, errOut = run(t, other, "client-add", "--name", "Иван Петров")
+	require.Equal(t, ExitOK, code, errOut)
+	code, out, errOut = run(t, other, "import", file, "--dry-run")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.Equal(t, "Прочитано записей: 2, прошло проверку: 2, с ошибками: 0\n", out)
+	code, out, _ = run(t, other, "list", "--output", "json")
+	require.Equal(t, ExitOK, code)
+	assert.JSONEq(t, `{"parcels": []}`, out)
+
+	code, out, errOut = run(t, other, "import", file, "--output", "json")
+	require.Equal(t, ExitOK, code, errOut)
+	assert.JSONEq(t, `{"records": 2, "imported": 2, "failed": 0}`, out)
+
+	// записи с ошибками попадают в отчет, код выхода сообщает о них
+	bad := filepath.Join(dir, "bad.csv")
+	report := filepath.Join(dir, "report.csv")
+	require.NoError(t, os.WriteFile(bad, []byte("client,recipient_name,recipient_address,weight\n"+
+		"1,Анна Смирнова,\"Псков, ул. Лесная, д. 3\",1000\n"+
+		"1,Анна Смирнова,\"Псков, ул. Лесная, д. 4\",0\n"), 0o600))
+	code, out, _ = run(t, other, "import", bad, "--report", report)
+	assert.Equal(t, ExitUsage, code)
+	assert.Contains(t, out, "зарегистрировано: 1, с ошибками: 1")
+	data, err := os.ReadFile(report)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "line,field,error\n3,weight,"), string(data))
+
+	// некорректные аргументы
+	code, _, _ = run(t, dsn, "export", "--month", "2026-13")
+	assert.Equal(t, ExitUsage, code)
+	code, _, _ = run(t, dsn, "export", "--month", month, "--from", "2026-01-01T00:00:00Z")
+	assert.Equal(t, ExitUsage, code)
+	code, _, _ = run(t, dsn, "export", "--format", "xml")
+	assert.Equal(t, ExitUsage, code)
+	code, _, _ = run(t, dsn, "import", filepath.Join(dir, "missing.csv"))
+	assert.Equal(t, ExitError, code)
+}
+
+// TestExitCodes проверяет коды выхода при ошибках
+func TestExitCodes(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "tracker.db")
+
+	code, _, _ := run(t, dsn, "client-add", "--name", "test client")
+	require.Equal(t, ExitOK, code)
+	code, _, _ = run(t, dsn, "register", "--client", "1", "--recipient-name", "Анна Смирнова", "--weight", "1000", "--address", "Псков, ул. Лесная, д. 1")
+	require.Equal(t, ExitOK, code)
+	code, _, _ = run(t, dsn, "next-status", "1")
+	require.Equal(t, ExitOK, code)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"неизвестная команда", []string{"unknown"}, ExitUsage},
+		{"неизвестный флаг", []string{"get", "1", "--force"}, ExitUsage},
+		{"нет аргумента", []string{"get"}, ExitUsage},
+		{"номер не число", []string{"get", "abc"}, ExitUsage},
+		{"неизвестный формат", []string{"get", "1", "--output", "xml"}, ExitUsage},
+		{"пустой адрес", []string{"register", "--client", "1", "--recipient-name", "test", "--weight", "1000"}, ExitUsage},
+		{"адрес без дома", []string{"register", "--client", "1", "--recipient-name", "test", "--weight", "1000", "--city", "Псков", "--street", "ул. Лесная"}, ExitUsage},
+		{"нет получателя", []string{"register", "--client", "1", "--weight", "1000", "--address", "Псков, ул. Лесная, д. 1"}, ExitUsage},
+		{"нет веса", []string{"register", "--client", "1", "--recipient-name", "test", "--address", "Псков, ул. Лесная, д. 1"}, ExitUsage},
+		{"неизвестный уровень", []string{"register", "--client", "1", "--recipient-name", "test", "--weight", "1000", "--service-level", "overnight", "--address", "Псков, ул. Лесная, д. 1"}, ExitUsage},
+		{"неизвестный статус", []string{"list", "--status", "unknown"}, ExitUsage},
+		{"некорректная дата", []string{"list", "--from", "вчера"}, ExitUsage},
+		{"нет клиента посылки", []string{"register", "--client", "2", "--recipient-name", "test", "--weight", "1000", "--address", "Псков, ул. Лесная, д. 1"}, ExitUsage},
+		{"клиент без имени", []string{"client-add", "--phone", "+79991234567"}, ExitUsage},
+		{"идентификатор не число", []string{"client-get", "abc"}, ExitUsage},
+		{"нет посылки", []string{"get", "42"}, ExitNotFound},
+		{"опечатка в коде отслеживания", []string{"track", "RR123456795RU"}, ExitUsage},
+		{"неизвестный код отслеживания", []string{"track", "RR123456785RU"}, ExitNotFound},
+		{"нет клиента", []string{"client-get", "42"}, ExitNotFound},
+		{"удаление отправленной", []string{"delete", "1"}, ExitConflict},
+		{"восстановление неудаленной", []string{"restore", "1"}, ExitConflict},
+		{"отрицательный срок хранения", []string{"purge", "--retention", "-1h"}, ExitUsage},
+		{"недопустимый переход", []string{"status", "1", "--to", "cancelled"}, ExitConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, errOut := run(t, dsn, tt.args...)
+			assert.Equal(t, tt.code, code, errOut)
+			assert.NotEmpty(t, errOut)
+		})
+	}
+}
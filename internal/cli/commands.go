@@ -0,0 +1,594 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/address"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/transfer"
+)
+
+// функция commands возвращает подкоманды утилиты по имени.
+// Значения флагов подкоманд хранятся в переменных замыкания,
+// поэтому набор команд создается заново при каждом запуске Run
+func commands() map[string]command {
+	var (
+		client   int                  // --client
+		line     string               // --address
+		parts    models.Address       // --postal-code, --city и другие части адреса
+		to       string               // --to
+		actor    string               // --actor
+		location string               // --location
+		comment  string               // --comment
+		addr     string               // --addr
+		statuses string               // --status
+		from     string               // --from
+		until    string               // --until
+		orderBy  string               // --order-by
+		desc     bool                 // --desc
+		limit    int                  // --limit
+		cursor   string               // --cursor
+		name     string               // --name
+		phone    string               // --phone
+		email    string               // --email
+		all      bool                 // --all
+		after    int                  // --after
+		sender   models.Party         // --sender-name, --sender-phone
+		senderAt string               // --sender-address
+		receiver models.Party         // --recipient-name, --recipient-phone
+		returnTo string               // --return-address
+		physical serv.RegisterRequest // --service-level, --weight, --length и другие характеристики посылки
+		key      string               // --idempotency-key
+		format   string               // --format
+		file     string               // --file
+		month    string               // --month
+		dryRun   bool                 // --dry-run
+		report   string               // --report
+		deleted  bool                 // --include-deleted
+		keep     time.Duration        // --retention
+	)
+
+	// флаги данных клиента
+	clientFlags := func(fs *flag.FlagSet) {
+		fs.StringVar(&name, "name", "", "имя клиента")
+		fs.StringVar(&phone, "phone", "", "телефон клиента")
+		fs.StringVar(&email, "email", "", "адрес электронной почты клиента")
+	}
+
+	// флаги адреса доставки: адрес одной строкой и его части,
+	// части, заданные отдельными флагами, заменяют разобранные из строки
+	addressFlags := func(fs *flag.FlagSet) {
+		fs.StringVar(&line, "address", "", "адрес доставки одной строкой")
+		fs.StringVar(&parts.PostalCode, "postal-code", "", "почтовый индекс")
+		fs.StringVar(&parts.Country, "country", "", "страна")
+		fs.StringVar(&parts.Region, "region", "", "регион")
+		fs.StringVar(&parts.City, "city", "", "город или населенный пункт")
+		fs.StringVar(&parts.Street, "street", "", "улица")
+		fs.StringVar(&parts.House, "house", "", "дом")
+		fs.StringVar(&parts.Apartment, "apartment", "", "квартира")
+	}
+
+	// deliveryAddress собирает адрес доставки из флагов addressFlags
+	deliveryAddress := func() models.Address {
+		a := address.Parse(line)
+		for _, p := range []struct{ dst, src *string }{
+			{&a.PostalCode, &parts.PostalCode}, {&a.Country, &parts.Country}, {&a.Region, &parts.Region},
+			{&a.City, &parts.City}, {&a.Street, &parts.Street}, {&a.House, &parts.House},
+			{&a.Apartment, &parts.Apartment},
+		} {
+			if *p.src != "" {
+				*p.dst = *p.src
+			}
+		}
+		return a
+	}
+
+	// флаги посылки для register и quote: получатель и адрес доставки,
+	// отправитель, адрес возврата и характеристики посылки
+	parcelFlags := func(fs *flag.FlagSet) {
+		fs.StringVar(&receiver.Name, "recipient-name", "", "имя получателя")
+		fs.StringVar(&receiver.Phone, "recipient-phone", "", "телефон получателя")
+		addressFlags(fs)
+		fs.StringVar(&sender.Name, "sender-name", "", "имя отправителя, по умолчанию имя клиента")
+		fs.StringVar(&sender.Phone, "sender-phone", "", "телефон отправителя")
+		fs.StringVar(&senderAt, "sender-address", "", "адрес отправителя одной строкой")
+		fs.StringVar(&returnTo, "return-address", "", "адрес возврата одной строкой, по умолчанию адрес отправителя")
+		fs.StringVar(&physical.ServiceLevel, "service-level", constants.ServiceLevelStandard, "уровень обслуживания: standard или express")
+		fs.IntVar(&physical.Weight, "weight", 0, "вес в граммах")
+		fs.IntVar(&physical.Dimensions.Length, "length", 0, "длина в сантиметрах")
+		fs.IntVar(&physical.Dimensions.Width, "width", 0, "ширина в сантиметрах")
+		fs.IntVar(&physical.Dimensions.Height, "height", 0, "высота в сантиметрах")
+		fs.StringVar(&physical.Contents, "contents", "", "описание вложения")
+		fs.Int64Var(&physical.DeclaredValue, "declared-value", 0, "объявленная ценность в копейках")
+	}
+
+	// registerRequest собирает запрос на регистрацию посылки из флагов parcelFlags и --client
+	registerRequest := func() serv.RegisterRequest {
+		req := physical
+		req.Client, req.Sender, req.Recipient = client, sender, receiver
+		req.Sender.Address = address.Parse(senderAt)
+		req.Recipient.Address = deliveryAddress()
+		if returnTo != "" {
+			a := address.Parse(returnTo)
+			req.ReturnAddress = &a
+		}
+		return req
+	}
+
+	// флаги, описывающие событие смены статуса
+	eventFlags := func(fs *flag.FlagSet) {
+		fs.StringVar(&actor, "actor", "", "кто меняет статус")
+		fs.StringVar(&location, "location", "", "где произошло событие")
+		fs.StringVar(&comment, "comment", "", "комментарий к событию")
+	}
+
+	// eventOptions собирает опции события из заполненных флагов
+	eventOptions := func() []serv.EventOption {
+		var opts []serv.EventOption
+		if actor != "" {
+			opts = append(opts, serv.WithActor(actor))
+		}
+		if location != "" {
+			opts = append(opts, serv.WithLocation(location))
+		}
+		if comment != "" {
+			opts = append(opts, serv.WithComment(comment))
+		}
+		return opts
+	}
+
+	return map[string]command{
+		"register": {
+			flags: func(fs *flag.FlagSet) {
+				fs.IntVar(&client, "client", 0, "идентификатор клиента")
+				fs.StringVar(&key, "idempotency-key", "", "ключ идемпотентности: повтор с тем же ключом не создает новую посылку")
+				parcelFlags(fs)
+			},
+			run: func(ctx context.Context, e env, args []string) error {
+				req := registerRequest()
+				req.IdempotencyKey = key
+				parcel, err := e.service.Register(ctx, req)
+				if err != nil {
+					return err
+				}
+				return e.out.parcel(parcel)
+			},
+		},
+		"quote": {
+			flags: parcelFlags,
+			run: func(ctx context.Context, e env, args []string) error {
+				quote, err := e.service.Quote(ctx, registerRequest())
+				if err != nil {
+					return err
+				}
+				return e.out.quote(quote)
+			},
+		},
+		"get": {
+			args: 1,
+			run: func(ctx context.Context, e env, args []string) error {
+				number, err := parseNumber(args[0])
+				if err != nil {
+					return err
+				}
+
+				parcel, err := e.service.Get(ctx, number)
+				if err != nil {
+					return err
+				}
+				return e.out.parcel(parcel)
+			},
+		},
+		"track": {
+			args: 1,
+			run: func(ctx context.Context, e env, args []string) error {
+				parcel, err := e.service.GetByTrackingCode(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				return e.out.parcel(parcel)
+			},
+		},
+		"list": {
+			flags: func(fs *flag.FlagSet) {
+				fs.IntVar(&client, "client", 0, "идентификатор клиента")
+				fs.StringVar(&statuses, "status", "", "статусы через запятую")
+				fs.StringVar(&from, "from", "", "созданы не раньше (RFC 3339)")
+				fs.StringVar(&until, "until", "", "созданы раньше (RFC 3339)")
+				fs.StringVar(&line, "address", "", "подстрока адреса")
+				fs.StringVar(&receiver.Phone, "recipient-phone", "", "телефон получателя")
+				fs.StringVar(&orderBy, "order-by", models.OrderByNumber, "сортировка: number или created_at")
+				fs.BoolVar(&desc, "desc", false, "сортировка по убыванию")
+				fs.IntVar(&limit, "limit", 0, "размер страницы")
+				fs.StringVar(&cursor, "cursor", "", "курсор следующей страницы")
+				fs.BoolVar(&deleted, "include-deleted", false, "включая удаленные посылки")
+			},
+			run: func(ctx context.Context, e env, args []string) error {
+				f := models.ParcelFilter{
+					Client:          client,
+					AddressContains: line,
+					RecipientPhone:  receiver.Phone,
+					OrderBy:         orderBy,
+					Desc:            desc,
+					Limit:           limit,
+					Cursor:          cursor,
+					IncludeDeleted:  deleted,
+				}
+				if statuses != "" {
+					f.Statuses = strings.Split(statuses, ",")
+				}
+
+				var err error
+				if f.CreatedFrom, err = parseTime("--from", from); err != nil {
+					return err
+				}
+				if f.CreatedTo, err = parseTime("--until", until); err != nil {
+					return err
+				}
+
+				page, err := e.service.List(ctx, f)
+				if err != nil {
+					return err
+				}
+
+				// подсказку о следующей странице выводим отдельно от результата
+				if page.NextCursor != "" && e.out.format != OutputJSON {
+					defer fmt.Fprintf(e.stderr, "Следующая страница: --cursor %s\n", page.NextCursor)
+				}
+				return e.out.page(page, client)
+			},
+		},
+		"export": {
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&format, "format", "", "формат файла: csv или ndjson, по умолчанию по расширению --file или csv")
+				fs.StringVar(&file, "file", "", "файл выгрузки, по умолчанию stdout")
+				fs.IntVar(&client, "client", 0, "идентификатор клиента")
+				fs.StringVar(&statuses, "status", "", "статусы через запятую")
+				fs.StringVar(&from, "from", "", "созданы не раньше (RFC 3339)")
+				fs.StringVar(&until, "until", "", "созданы раньше (RFC 3339)")
+				fs.StringVar(&month, "month", "", "созданы в календарном месяце ГГГГ-ММ (UTC)")
+			},
+			run: func(ctx context.Context, e env, args []string) error {
+				f := models.ParcelFilter{Client: client}
+				if statuses != "" {
+					f.Statuses = strings.Split(statuses, ",")
+				}
+
+				var err error
+				if f.CreatedFrom, err = parseTime("--from", from); err != nil {
+					return err
+				}
+				if f.CreatedTo, err = parseTime("--until", until); err != nil {
+					return err
+				}
+				if month != "" {
+					if from != "" || until != "" {
+						return fmt.Errorf("%w: --month нельзя указывать вместе с --from и --until", errUsage)
+					}
+					if f.CreatedFrom, f.CreatedTo, err = parseMonth(month); err != nil {
+						return err
+					}
+				}
+
+				if format == "" {
+					format = transfer.FormatOf(file)
+				}
+				if err := transfer.CheckFormat(format); err != nil {
+					return err
+				}
+
+				w := e.out.w
+				if file != "" {
+					out, err := os.Create(file)
+					if err != nil {
+						return err
+					}
+					defer out.Close()
+					w = out
+				}
+
+				n, err := transfer.Export(ctx, e.service, f, format, w)
+				if err != nil {
+					return err
+				}
+				if file != "" {
+					fmt.Fprintf(e.stderr, "Выгружено посылок: %d\n", n)
+				}
+				return nil
+			},
+		},
+		"import": {
+			args: 1,
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&format, "format", "", "формат файла: csv или ndjson, по умолчанию по расширению")
+				fs.BoolVar(&dryRun, "dry-run", false, "только проверить записи, не регистрируя посылки")
+				fs.StringVar(&report, "report", "", "файл отчета об ошибках в формате CSV, по умолчанию stderr")
+			},
+			run: func(ctx context.Context, e env, args []string) error {
+				if format == "" {
+					format = transfer.FormatOf(args[0])
+				}
+
+				in, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer in.Close()
+
+				var reportTo io.Writer = e.stderr
+				if report != "" {
+					out, err := os.Create(report)
+					if err != nil {
+						return err
+					}
+					defer out.Close()
+					reportTo = out
+				}
+
+				res, err := transfer.Import(ctx, e.service, in, transfer.ImportOptions{
+					Format: format, DryRun: dryRun, Report: reportTo,
+				})
+				if err != nil {
+					return err
+				}
+				if err := e.out.importResult(res, dryRun); err != nil {
+					return err
+				}
+
+				// код выхода сообщает сценариям, что часть записей не загружена
+				if res.Failed > 0 {
+					return errors.Validation("records", fmt.Sprintf("записей с ошибками: %d", res.Failed))
+				}
+				return nil
+			},
+		},
+		"next-status": {
+			args:  1,
+			flags: eventFlags,
+			run: func(ctx context.Context, e env, args []string) error {
+				number, err := parseNumber(args[0])
+				if err != nil {
+					return err
+				}
+
+				parcel, err := e.service.NextStatus(ctx, number, eventOptions()...)
+				if err != nil {
+					return err
+				}
+				return e.out.parcel(parcel)
+			},
+		},
+		"status": {
+			args: 1,
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&to, "to", "", "новый статус")
+				eventFlags(fs)
+			},
+			run: func(ctx context.Context, e env, args []string) error {
+				number, err := parseNumber(args[0])
+				if err != nil {
+					return err
+				}
+
+				parcel, err := e.service.Transition(ctx, number, to, eventOptions()...)
+				if err != nil {
+					return err
+				}
+				return e.out.parcel(parcel)
+			},
+		},
+		"history": {
+			args: 1,
+			run: func(ctx context.Context, e env, args []string) error {
+				number, err := parseNumber(args[0])
+				if err != nil {
+					return err
+				}
+
+				events, err := e.service.History(ctx, number)
+				if err != nil {
+					return err
+				}
+				return e.out.events(events)
+			},
+		},
+		"set-address": {
+			args:  1,
+			flags: addressFlags,
+			run: func(ctx context.Context, e env, args []string) error {
+				number, err := parseNumber(args[0])
+				if err != nil {
+					return err
+				}
+
+				if err := e.service.ChangeAddress(ctx, number, deliveryAddress()); err != nil {
+					return err
+				}
+				return printParcel(ctx, e, number)
+			},
+		},
+		"delete": {
+			args: 1,
+			run: func(ctx context.Context, e env, args []string) error {
+				number, err := parseNumber(args[0])
+				if err != nil {
+					return err
+				}
+
+				return e.service.Delete(ctx, number)
+			},
+		},
+		"restore": {
+			args: 1,
+			run: func(ctx context.Context, e env, args []string) error {
+				number, err := parseNumber(args[0])
+				if err != nil {
+					return err
+				}
+
+				parcel, err := e.service.Restore(ctx, number)
+				if err != nil {
+					return err
+				}
+				return e.out.parcel(parcel)
+			},
+		},
+		"purge": {
+			flags: func(fs *flag.FlagSet) {
+				fs.DurationVar(&keep, "retention", serv.DefaultRetention, "срок хранения удаленных посылок")
+			},
+			run: func(ctx context.Context, e env, args []string) error {
+				n, err := e.service.Purge(ctx, keep)
+				if err != nil {
+					return err
+				}
+				return e.out.purged(n)
+			},
+		},
+		"serve": {
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&addr, "addr", ":8080", "адрес HTTP-сервера")
+			},
+			run: func(ctx context.Context, e env, args []string) error {
+				fmt.Fprintf(e.stderr, "Трекер посылок принимает запросы на %s\n", addr)
+				return serve(ctx, e.service, e.clients, e.logger, addr)
+			},
+		},
+		"owner": {
+			args: 1,
+			run: func(ctx context.Context, e env, args []string) error {
+				number, err := parseNumber(args[0])
+				if err != nil {
+					return err
+				}
+
+				client, err := e.service.Owner(ctx, number)
+				if err != nil {
+					return err
+				}
+				return e.out.client(client)
+			},
+		},
+		"client-add": {
+			flags: clientFlags,
+			run: func(ctx context.Context, e env, args []string) error {
+				client, err := e.clients.Create(ctx, name, phone, email)
+				if err != nil {
+					return err
+				}
+				return e.out.client(client)
+			},
+		},
+		"client-get": {
+			args: 1,
+			run: func(ctx context.Context, e env, args []string) error {
+				id, err := parseID(args[0])
+				if err != nil {
+					return err
+				}
+
+				client, err := e.clients.Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				return e.out.client(client)
+			},
+		},
+		"client-list": {
+			flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&name, "name", "", "подстрока имени")
+				fs.BoolVar(&all, "all", false, "включая деактивированных клиентов")
+				fs.IntVar(&after, "after", 0, "клиенты с идентификатором больше указанного")
+				fs.IntVar(&limit, "limit", 0, "размер страницы")
+			},
+			run: func(ctx context.Context, e env, args []string) error {
+				clients, err := e.clients.List(ctx, models.ClientFilter{
+					NameContains:    name,
+					IncludeInactive: all,
+					AfterID:         after,
+					Limit:           limit,
+				})
+				if err != nil {
+					return err
+				}
+				return e.out.clients(clients)
+			},
+		},
+		"client-update": {
+			args:  1,
+			flags: clientFlags,
+			run: func(ctx context.Context, e env, args []string) error {
+				id, err := parseID(args[0])
+				if err != nil {
+					return err
+				}
+
+				// незаданные флаги оставляют прежние значения
+				client, err := e.clients.Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				if name != "" {
+					client.Name = name
+				}
+				if phone != "" {
+					client.Phone = phone
+				}
+				if email != "" {
+					client.Email = email
+				}
+
+				client, err = e.clients.Update(ctx, client)
+				if err != nil {
+					return err
+				}
+				return e.out.client(client)
+			},
+		},
+		"client-deactivate": {
+			args: 1,
+			run: func(ctx context.Context, e env, args []string) error {
+				id, err := parseID(args[0])
+				if err != nil {
+					return err
+				}
+
+				client, err := e.clients.Deactivate(ctx, id)
+				if err != nil {
+					return err
+				}
+				return e.out.client(client)
+			},
+		},
+	}
+}
+
+// функция printParcel выводит актуальное состояние посылки после ее изменения
+func printParcel(ctx context.Context, e env, number int) error {
+	parcel, err := e.service.Get(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	return e.out.parcel(parcel)
+}
+
+// функция parseMonth разбирает значение флага --month в формате ГГГГ-ММ
+// и возвращает начало месяца и начало следующего месяца (UTC)
+func parseMonth(value string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01", value)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: --month ожидает месяц в формате ГГГГ-ММ, получено %q", errUsage, value)
+	}
+
+	return start, start.AddDate(0, 1, 0), nil
+}
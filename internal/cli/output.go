@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/pricing"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/render"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/transfer"
+)
+
+// определяем структурный тип printer - вывод результатов команд
+// в формате, выбранном флагом --output
+type printer struct {
+	w      io.Writer // поток вывода
+	format string    // OutputTable, OutputJSON или OutputText
+}
+
+// функция newPrinter проверяет формат вывода и создает printer
+func newPrinter(format string, w io.Writer) (printer, error) {
+	if format != OutputTable && format != OutputJSON && format != OutputText {
+		return printer{}, fmt.Errorf("неизвестный формат вывода %q: ожидается %s, %s или %s",
+			format, OutputTable, OutputJSON, OutputText)
+	}
+
+	return printer{w: w, format: format}, nil
+}
+
+// метод parcel типа printer выводит одну посылку
+func (p printer) parcel(parcel models.Parcel) error {
+	switch p.format {
+	case OutputJSON:
+		return p.json(parcel)
+	case OutputText:
+		return render.Parcel(p.w, parcel)
+	default:
+		return p.parcelTable([]models.Parcel{parcel})
+	}
+}
+
+// метод page типа printer выводит страницу списка посылок,
+// в формате JSON вместе с курсором следующей страницы.
+// В текстовом формате посылки одного клиента выводятся под заголовком с его идентификатором
+// Параметры
+// page - страница списка
+// client - клиент, по которому выбраны посылки, или 0
+func (p printer) page(page models.ParcelPage, client int) error {
+	switch {
+	case p.format == OutputJSON:
+		return p.json(page)
+	case p.format == OutputText && client > 0:
+		return render.ClientParcels(p.w, client, page.Parcels)
+	case p.format == OutputText:
+		return render.Parcels(p.w, page.Parcels)
+	default:
+		return p.parcelTable(page.Parcels)
+	}
+}
+
+// метод events типа printer выводит историю статусов посылки
+func (p printer) events(events []models.StatusEvent) error {
+	switch p.format {
+	case OutputJSON:
+		return p.json(events)
+	case OutputText:
+		return render.Events(p.w, events)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ВРЕМЯ\tИЗ СТАТУСА\tВ СТАТУС\tИСПОЛНИТЕЛЬ\tМЕСТО\tКОММЕНТАРИЙ")
+	for _, ev := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", formatTime(ev.At), ev.From, ev.To, ev.Actor, ev.Location, ev.Comment)
+	}
+
+	return tw.Flush()
+}
+
+// метод client типа printer выводит одного клиента
+func (p printer) client(client models.Client) error {
+	switch p.format {
+	case OutputJSON:
+		return p.json(client)
+	case OutputText:
+		return render.Client(p.w, client)
+	default:
+		return p.clientTable([]models.Client{client})
+	}
+}
+
+// метод clients типа printer выводит список клиентов
+func (p printer) clients(clients []models.Client) error {
+	switch p.format {
+	case OutputJSON:
+		return p.json(clients)
+	case OutputText:
+		for _, client := range clients {
+			if err := render.Client(p.w, client); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return p.clientTable(clients)
+	}
+}
+
+// метод clientTable типа printer выводит клиентов таблицей
+func (p printer) clientTable(clients []models.Client) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tИМЯ\tТЕЛЕФОН\tПОЧТА\tАКТИВЕН\tСОЗДАН")
+	for _, client := range clients {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			client.ID, client.Name, client.Phone, client.Email, yesNo(client.Active), formatTime(client.CreatedAt))
+	}
+
+	return tw.Flush()
+}
+
+// функция yesNo выводит логическое значение в таблице
+func yesNo(v bool) string {
+	if v {
+		return "да"
+	}
+
+	return "нет"
+}
+
+// метод parcelTable типа printer выводит посылки таблицей,
+// к статусу удаленной посылки добавляется отметка об удалении
+func (p printer) parcelTable(parcels []models.Parcel) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "НОМЕР\tКОД ОТСЛЕЖИВАНИЯ\tКЛИЕНТ\tСТАТУС\tСОЗДАНА\tВЕС, Г\tОБЪЕМНЫЙ ВЕС, Г\tСТОИМОСТЬ\tПОЛУЧАТЕЛЬ\tАДРЕС")
+	for _, parcel := range parcels {
+		status := parcel.Status
+		if parcel.DeletedAt != nil {
+			status += " (удалена)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			parcel.Number, parcel.TrackingCode, parcel.Client, status, formatTime(parcel.CreatedAt),
+			parcel.Weight, parcel.VolumetricWeight(), formatMoney(parcel.Cost),
+			parcel.Recipient.Name, parcel.Recipient.Address)
+	}
+
+	return tw.Flush()
+}
+
+// метод quote типа printer выводит расчет стоимости доставки
+func (p printer) quote(q pricing.Quote) error {
+	switch p.format {
+	case OutputJSON:
+		return p.json(q)
+	case OutputText:
+		return render.Quote(p.w, q)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "УРОВЕНЬ\tЗОНА\tВЕС, Г\tОБЪЕМНЫЙ ВЕС, Г\tОПЛАЧИВАЕМЫЙ ВЕС, Г\tСТОИМОСТЬ")
+	fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n",
+		q.ServiceLevel, q.Zone, q.Weight, q.VolumetricWeight, q.ChargeableWeight, formatMoney(q.Price))
+
+	return tw.Flush()
+}
+
+// метод importResult типа printer выводит итог загрузки посылок
+// Параметры
+// res - итог загрузки
+// dryRun - загрузка была пробной
+func (p printer) importResult(res transfer.ImportResult, dryRun bool) error {
+	if p.format == OutputJSON {
+		return p.json(res)
+	}
+
+	imported := "зарегистрировано"
+	if dryRun {
+		imported = "прошло проверку"
+	}
+	_, err := fmt.Fprintf(p.w, "Прочитано записей: %d, %s: %d, с ошибками: %d\n", res.Records, imported, res.Imported, res.Failed)
+
+	return err
+}
+
+// метод purged типа printer выводит число окончательно удаленных посылок
+func (p printer) purged(n int) error {
+	if p.format == OutputJSON {
+		return p.json(struct {
+			Purged int `json:"purged"`
+		}{Purged: n})
+	}
+
+	_, err := fmt.Fprintf(p.w, "Окончательно удалено посылок: %d\n", n)
+
+	return err
+}
+
+// функция formatMoney выводит сумму в копейках в рублях: "350.00"
+func formatMoney(kopecks int64) string {
+	return fmt.Sprintf("%d.%02d", kopecks/100, kopecks%100)
+}
+
+// функция formatTime выводит момент времени в таблице в формате RFC 3339 (UTC)
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// метод json типа printer выводит значение в формате JSON с отступами
+func (p printer) json(v any) error {
+	encoder := json.NewEncoder(p.w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/api"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+)
+
+// функция serve обслуживает HTTP API до отмены контекста ctx
+// (в main.go - до получения Ctrl+C или SIGTERM)
+// Параметры
+// ctx - контекст, отмена которого останавливает сервер
+// service - сервис посылок
+// clients - сервис клиентов
+// logger - журнал внутренних ошибок API
+// addr - адрес HTTP-сервера
+func serve(ctx context.Context, service serv.ParcelService, clients serv.ClientService, logger *slog.Logger, addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           api.NewHandler(service, clients, logger),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	// запускаем сервер в отдельной горутине, чтобы дождаться отмены контекста
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		// даем незавершенным запросам время на выполнение
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = server.Shutdown(shutdownCtx)
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("ошибка HTTP-сервера: %w", err)
+	}
+
+	return nil
+}
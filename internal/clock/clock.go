@@ -0,0 +1,25 @@
+package clock
+
+import "time"
+
+// в пакете определен источник текущего времени для сервиса посылок.
+// Сервис получает время только через Clock, поэтому в тестах
+// его можно заменить управляемыми часами из пакета clocktest
+
+// Clock возвращает текущий момент времени
+type Clock interface {
+	Now() time.Time
+}
+
+// определяем тип systemClock - системные часы
+type systemClock struct{}
+
+// Метод Now типа systemClock возвращает time.Now()
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// функция System возвращает системные часы
+func System() Clock {
+	return systemClock{}
+}
@@ -0,0 +1,64 @@
+package clocktest
+
+import (
+	"sync"
+	"time"
+)
+
+// в пакете определены управляемые часы для тестов: время в них
+// не идет само, а устанавливается и сдвигается тестом
+
+// Epoch - момент, с которого по умолчанию начинаются тестовые часы
+var Epoch = time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+// определяем структурный тип Fake - тестовые часы, реализующие clock.Clock.
+// Методы безопасны для вызова из нескольких горутин
+type Fake struct {
+	mu   sync.Mutex    // защищает поля ниже
+	now  time.Time     // текущий момент часов
+	step time.Duration // сдвиг часов после каждого вызова Now, 0 - часы стоят
+}
+
+// функция NewFake возвращает часы, показывающие момент now
+// Параметры
+// now - начальный момент
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// функция NewTicking возвращает часы, которые начинают с момента now
+// и сдвигаются на step после каждого вызова Now, чтобы последовательные
+// события получали разные, но предсказуемые моменты времени
+// Параметры
+// now - начальный момент
+// step - шаг часов
+func NewTicking(now time.Time, step time.Duration) *Fake {
+	return &Fake{now: now, step: step}
+}
+
+// Метод Now типа Fake возвращает текущий момент часов
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now
+	f.now = f.now.Add(f.step)
+
+	return now
+}
+
+// Метод Advance типа Fake сдвигает часы на d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+}
+
+// Метод Set типа Fake устанавливает часы на момент t
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = t
+}
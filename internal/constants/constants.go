@@ -1,10 +1,22 @@
 package constants
 
-// в пакете хранятся константы - возможные значения, которые может принимать поле Status структуры Parcel
+// в пакете хранятся константы - возможные значения, которые могут принимать поля Status и ServiceLevel структуры Parcel.
+// Допустимые переходы между статусами описаны в пакете internal/parcel/status
 
 const (
 	// объявляем константы с возможными статусами посылок
-	ParcelStatusRegistered = "registered" // посылка зарегистрирована
-	ParcelStatusSent       = "sent"       // посылка отправлена
-	ParcelStatusDelivered  = "delivered"  // посылка доставлена
+	ParcelStatusRegistered     = "registered"         // посылка зарегистрирована
+	ParcelStatusSent           = "sent"               // посылка отправлена
+	ParcelStatusInTransit      = "in_transit"         // посылка в пути
+	ParcelStatusOutForDelivery = "out_for_delivery"   // посылка передана курьеру
+	ParcelStatusDelivered      = "delivered"          // посылка доставлена
+	ParcelStatusCancelled      = "cancelled"          // регистрация посылки отменена
+	ParcelStatusReturned       = "returned_to_sender" // посылка возвращена отправителю
+	ParcelStatusLost           = "lost"               // посылка утеряна
+)
+
+const (
+	// объявляем константы с уровнями обслуживания посылок
+	ServiceLevelStandard = "standard" // стандартная доставка
+	ServiceLevelExpress  = "express"  // срочная доставка
 )
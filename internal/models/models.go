@@ -1,10 +1,190 @@
 package models
 
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
 // определяем структурый тип Parcel ("посылка")
+// теги json задают представление посылки в HTTP API
 type Parcel struct {
-	Number    int    // номер посылки, в БД это автоинкрементное поле
-	Client    int    // идентификатор клиента
-	Status    string // статус посылки
-	Address   string // адрес посылки
-	CreatedAt string // дата и время создания посылки
+	Number        int        `json:"number"`                   // номер посылки, в БД это автоинкрементное поле
+	TrackingCode  string     `json:"tracking_code,omitempty"`  // публичный код отслеживания S10, пустой у посылок, зарегистрированных до его появления
+	Client        int        `json:"client"`                   // идентификатор клиента
+	Status        string     `json:"status"`                   // статус посылки
+	Sender        Party      `json:"sender"`                   // отправитель
+	Recipient     Party      `json:"recipient"`                // получатель, его адрес - адрес доставки
+	ReturnAddress *Address   `json:"return_address,omitempty"` // адрес возврата отправителю, nil - адрес отправителя
+	ServiceLevel  string     `json:"service_level"`            // уровень обслуживания, см. constants.ServiceLevelStandard
+	Weight        int        `json:"weight"`                   // вес в граммах
+	Dimensions    Dimensions `json:"dimensions"`               // габариты, нулевые - габариты не указаны
+	Contents      string     `json:"contents,omitempty"`       // описание вложения
+	DeclaredValue int64      `json:"declared_value"`           // объявленная ценность в копейках
+	Cost          int64      `json:"cost"`                     // стоимость доставки в копейках по тарифам на момент регистрации
+	CreatedAt     time.Time  `json:"created_at"`               // дата и время создания посылки (UTC)
+	UpdatedAt     time.Time  `json:"updated_at"`               // дата и время последнего изменения посылки
+	SentAt        *time.Time `json:"sent_at,omitempty"`        // дата и время отправки, nil - посылка не отправлена
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`   // дата и время доставки, nil - посылка не доставлена
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`     // дата и время удаления, nil - посылка не удалена
+}
+
+// Метод ReturnTo типа Parcel возвращает адрес, по которому посылка возвращается отправителю:
+// ReturnAddress, если он указан, иначе адрес отправителя
+func (p Parcel) ReturnTo() Address {
+	if p.ReturnAddress != nil {
+		return *p.ReturnAddress
+	}
+
+	return p.Sender.Address
+}
+
+// Метод VolumetricWeight типа Parcel возвращает объемный вес посылки в граммах,
+// см. Dimensions.VolumetricWeight
+func (p Parcel) VolumetricWeight() int {
+	return p.Dimensions.VolumetricWeight()
+}
+
+// Метод MarshalJSON типа Parcel реализует json.Marshaler:
+// к полям посылки добавляется вычисляемый объемный вес volumetric_weight
+func (p Parcel) MarshalJSON() ([]byte, error) {
+	// тип parcel не наследует метод MarshalJSON, поэтому не вызывает его рекурсивно
+	type parcel Parcel
+
+	return json.Marshal(struct {
+		parcel
+		VolumetricWeight int `json:"volumetric_weight"`
+	}{parcel: parcel(p), VolumetricWeight: p.VolumetricWeight()})
+}
+
+// VolumetricDivisor - делитель объемного веса: число кубических сантиметров,
+// которые при перевозке приравниваются к килограмму
+const VolumetricDivisor = 5000
+
+// определяем структурный тип Dimensions ("габариты") - размеры посылки в сантиметрах
+type Dimensions struct {
+	Length int `json:"length"` // длина
+	Width  int `json:"width"`  // ширина
+	Height int `json:"height"` // высота
+}
+
+// Метод VolumetricWeight типа Dimensions возвращает объемный вес в граммах:
+// объем в кубических сантиметрах, деленный на VolumetricDivisor, с округлением вверх.
+// Для неуказанных габаритов объемный вес равен 0
+func (d Dimensions) VolumetricWeight() int {
+	volume := d.Length * d.Width * d.Height
+
+	return (volume*1000 + VolumetricDivisor - 1) / VolumetricDivisor
+}
+
+// определяем структурный тип Party ("участник отправления") - отправитель или получатель посылки.
+// В отличие от клиента, участник не хранится отдельно: это контакты, указанные при регистрации
+type Party struct {
+	Name    string  `json:"name"`            // имя или название организации
+	Phone   string  `json:"phone,omitempty"` // телефон в формате +79991234567 (необязательно)
+	Address Address `json:"address"`         // адрес
+}
+
+// определяем структурный тип Address ("адрес") - адрес по частям.
+// Части хранятся в каноническом виде, который задает address.Normalize:
+// сокращения типов улиц и регионов приведены к одному написанию ("ул.", "обл."),
+// а у города, дома и квартиры слова "г.", "д." и "кв." отброшены
+type Address struct {
+	PostalCode string `json:"postal_code,omitempty"` // почтовый индекс
+	Country    string `json:"country,omitempty"`     // страна
+	Region     string `json:"region,omitempty"`      // регион: область, край, республика
+	City       string `json:"city,omitempty"`        // город или другой населенный пункт
+	Street     string `json:"street,omitempty"`      // улица вместе с типом: "ул. Ленина", "пр-т Мира"
+	House      string `json:"house,omitempty"`       // дом, корпус, строение: "5", "5 корп. 2"
+	Apartment  string `json:"apartment,omitempty"`   // квартира или офис
+}
+
+// Метод String типа Address возвращает адрес одной строкой
+// в порядке от индекса к квартире, пустые части пропускаются:
+// "180000, Россия, Псковская обл., Псков, ул. Лесная, д. 5, кв. 12"
+func (a Address) String() string {
+	parts := make([]string, 0, 7)
+	for _, part := range []string{a.PostalCode, a.Country, a.Region, a.City, a.Street} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if a.House != "" {
+		parts = append(parts, "д. "+a.House)
+	}
+	if a.Apartment != "" {
+		parts = append(parts, "кв. "+a.Apartment)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// определяем структурный тип StatusEvent ("событие смены статуса посылки")
+type StatusEvent struct {
+	ID       int       `json:"id"`                 // идентификатор события, в БД это автоинкрементное поле
+	Parcel   int       `json:"parcel"`             // номер посылки
+	From     string    `json:"from"`               // статус до изменения
+	To       string    `json:"to"`                 // статус после изменения
+	At       time.Time `json:"at"`                 // дата и время изменения (UTC)
+	Actor    string    `json:"actor"`              // кто изменил статус: сотрудник, система, партнер
+	Location string    `json:"location,omitempty"` // где произошло событие (необязательно)
+	Comment  string    `json:"comment,omitempty"`  // комментарий (необязательно)
+}
+
+// определяем структурный тип IdempotencyKey ("ключ идемпотентности регистрации"):
+// по нему повторный запрос регистрации возвращает уже зарегистрированную посылку
+type IdempotencyKey struct {
+	Client      int       `json:"client"`      // клиент, передавший ключ; ключи разных клиентов независимы
+	Key         string    `json:"key"`         // ключ, переданный клиентом
+	Fingerprint string    `json:"fingerprint"` // отпечаток данных запроса, с которыми использован ключ
+	Parcel      int       `json:"parcel"`      // номер посылки, зарегистрированной по запросу
+	CreatedAt   time.Time `json:"created_at"`  // дата и время первого запроса (UTC)
+}
+
+// порядок сортировки списка посылок
+const (
+	OrderByNumber    = "number"     // по номеру посылки
+	OrderByCreatedAt = "created_at" // по дате создания, при равенстве дат - по номеру
+)
+
+// определяем структурный тип ParcelFilter ("условия выборки посылок")
+// нулевое значение поля означает отсутствие условия
+type ParcelFilter struct {
+	Client          int       // идентификатор клиента
+	Statuses        []string  // допустимые статусы посылки
+	CreatedFrom     time.Time // посылка создана не раньше этого момента
+	CreatedTo       time.Time // посылка создана раньше этого момента
+	AddressContains string    // подстрока адреса доставки (с учетом регистра)
+	RecipientPhone  string    // телефон получателя в формате +79991234567
+	OrderBy         string    // OrderByNumber (по умолчанию) или OrderByCreatedAt
+	Desc            bool      // сортировка по убыванию
+	Limit           int       // размер страницы, 0 - размер по умолчанию
+	Cursor          string    // курсор страницы из ParcelPage.NextCursor, пустой - первая страница
+	IncludeDeleted  bool      // включать удаленные посылки, которые еще не удалены окончательно
+}
+
+// определяем структурный тип ParcelPage ("страница списка посылок")
+type ParcelPage struct {
+	Parcels    []Parcel `json:"parcels"`               // посылки страницы
+	NextCursor string   `json:"next_cursor,omitempty"` // курсор следующей страницы, пустой на последней
+}
+
+// определяем структурный тип Client ("клиент") - владелец посылок
+type Client struct {
+	ID        int       `json:"id"`              // идентификатор клиента, в БД это автоинкрементное поле
+	Name      string    `json:"name"`            // имя клиента или название организации
+	Phone     string    `json:"phone,omitempty"` // телефон в формате +79991234567 (необязательно)
+	Email     string    `json:"email,omitempty"` // адрес электронной почты (необязательно)
+	Active    bool      `json:"active"`          // false - клиент деактивирован и не может регистрировать посылки
+	CreatedAt time.Time `json:"created_at"`      // дата и время создания клиента (UTC)
+	UpdatedAt time.Time `json:"updated_at"`      // дата и время последнего изменения клиента
+}
+
+// определяем структурный тип ClientFilter ("условия выборки клиентов")
+// нулевое значение поля означает отсутствие условия
+type ClientFilter struct {
+	NameContains    string // подстрока имени (с учетом регистра)
+	IncludeInactive bool   // включать деактивированных клиентов
+	AfterID         int    // клиенты с идентификатором больше этого, для постраничного обхода
+	Limit           int    // наибольшее число клиентов, 0 - размер страницы по умолчанию
 }
@@ -0,0 +1,648 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/address"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+)
+
+// в пакете реализован REST API трекера посылок поверх ParcelService и ClientService.
+// Тела запросов и ответов передаются в формате JSON,
+// ошибки сервиса отображаются на коды ответа HTTP (см. writeError)
+
+// определяем структурный тип Handler - обработчик HTTP-запросов к трекеру
+type Handler struct {
+	service serv.ParcelService // сервис посылок, которому передаются запросы
+	clients serv.ClientService // сервис клиентов
+	logger  *slog.Logger       // журнал внутренних ошибок, текст которых не передается клиенту
+	mux     *http.ServeMux     // маршрутизатор запросов
+}
+
+// функция NewHandler создает обработчик и регистрирует маршруты API
+// Параметры
+// service - сервис посылок
+// clients - сервис клиентов
+// logger - журнал внутренних ошибок; если nil, используется slog.Default()
+func NewHandler(service serv.ParcelService, clients serv.ClientService, logger *slog.Logger) *Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	h := &Handler{service: service, clients: clients, logger: logger, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("POST /parcels", h.register)
+	h.mux.HandleFunc("POST /parcels/batch", h.registerBatch)
+	h.mux.HandleFunc("POST /parcels/batch/status", h.transitionBatch)
+	h.mux.HandleFunc("POST /quotes", h.quote)
+	h.mux.HandleFunc("GET /parcels", h.list)
+	h.mux.HandleFunc("GET /parcels/{number}", h.get)
+	h.mux.HandleFunc("GET /parcels/{number}/client", h.owner)
+	h.mux.HandleFunc("GET /tracking/{code}", h.track)
+	h.mux.HandleFunc("POST /clients", h.createClient)
+	h.mux.HandleFunc("GET /clients", h.listClients)
+	h.mux.HandleFunc("GET /clients/{id}", h.getClient)
+	h.mux.HandleFunc("PUT /clients/{id}", h.updateClient)
+	h.mux.HandleFunc("POST /clients/{id}/deactivate", h.deactivateClient)
+	h.mux.HandleFunc("GET /clients/{id}/parcels", h.clientParcels)
+	h.mux.HandleFunc("PATCH /parcels/{number}/address", h.changeAddress)
+	h.mux.HandleFunc("POST /parcels/{number}/next-status", h.nextStatus)
+	h.mux.HandleFunc("POST /parcels/{number}/status", h.transition)
+	h.mux.HandleFunc("GET /parcels/{number}/events", h.history)
+	h.mux.HandleFunc("DELETE /parcels/{number}", h.delete)
+	h.mux.HandleFunc("POST /parcels/{number}/restore", h.restore)
+
+	return h
+}
+
+// Метод ServeHTTP типа Handler передает запрос маршрутизатору
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// headerIdempotencyKey - заголовок запроса POST /parcels с ключом идемпотентности
+const headerIdempotencyKey = "Idempotency-Key"
+
+// registerRequest - тело запроса POST /parcels и POST /quotes
+type registerRequest struct {
+	Client        int               `json:"client"`
+	Sender        partyRequest      `json:"sender"`
+	Recipient     partyRequest      `json:"recipient"`
+	ReturnAddress *addressField     `json:"return_address"`
+	ServiceLevel  string            `json:"service_level"`
+	Weight        int               `json:"weight"`
+	Dimensions    models.Dimensions `json:"dimensions"`
+	Contents      string            `json:"contents"`
+	DeclaredValue int64             `json:"declared_value"`
+}
+
+// partyRequest - отправитель или получатель в теле запроса POST /parcels
+type partyRequest struct {
+	Name    string       `json:"name"`
+	Phone   string       `json:"phone"`
+	Address addressField `json:"address"`
+}
+
+// Метод request типа registerRequest возвращает запрос на регистрацию посылки для сервиса
+func (r registerRequest) request() serv.RegisterRequest {
+	req := serv.RegisterRequest{
+		Client:        r.Client,
+		Sender:        r.Sender.party(),
+		Recipient:     r.Recipient.party(),
+		ServiceLevel:  r.ServiceLevel,
+		Weight:        r.Weight,
+		Dimensions:    r.Dimensions,
+		Contents:      r.Contents,
+		DeclaredValue: r.DeclaredValue,
+	}
+	if r.ReturnAddress != nil {
+		req.ReturnAddress = &r.ReturnAddress.Address
+	}
+
+	return req
+}
+
+// Метод party типа partyRequest возвращает сторону посылки
+func (p partyRequest) party() models.Party {
+	return models.Party{Name: p.Name, Phone: p.Phone, Address: p.Address.Address}
+}
+
+// addressRequest - тело запроса PATCH /parcels/{number}/address
+type addressRequest struct {
+	Address addressField `json:"address"`
+}
+
+// addressField - адрес в теле запроса: объект с частями адреса (см. models.Address)
+// или, как в прежних версиях API, строка, которую разбирает address.Parse
+type addressField struct {
+	models.Address
+}
+
+// Метод UnmarshalJSON типа addressField реализует json.Unmarshaler
+func (f *addressField) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var line string
+		if err := json.Unmarshal(data, &line); err != nil {
+			return err
+		}
+		f.Address = address.Parse(line)
+		return nil
+	}
+
+	// неизвестные части адреса отклоняются так же, как неизвестные поля запроса
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(&f.Address)
+}
+
+// transitionRequest - тело запроса POST /parcels/{number}/status
+type transitionRequest struct {
+	Status   string `json:"status"`
+	Actor    string `json:"actor"`
+	Location string `json:"location"`
+	Comment  string `json:"comment"`
+}
+
+// Метод options типа transitionRequest возвращает сведения о событии для сервиса:
+// необязательные сведения передаем в сервис только если они заполнены
+func (r transitionRequest) options() []serv.EventOption {
+	var opts []serv.EventOption
+	if r.Actor != "" {
+		opts = append(opts, serv.WithActor(r.Actor))
+	}
+	if r.Location != "" {
+		opts = append(opts, serv.WithLocation(r.Location))
+	}
+	if r.Comment != "" {
+		opts = append(opts, serv.WithComment(r.Comment))
+	}
+
+	return opts
+}
+
+// batchRegisterRequest - тело запроса POST /parcels/batch
+type batchRegisterRequest struct {
+	Parcels []batchParcelRequest `json:"parcels"`
+}
+
+// batchParcelRequest - посылка в теле запроса POST /parcels/batch:
+// поля как у POST /parcels и ключ идемпотентности, который для одной посылки передается в заголовке
+type batchParcelRequest struct {
+	registerRequest
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// batchStatusRequest - тело запроса POST /parcels/batch/status
+type batchStatusRequest struct {
+	Numbers []int `json:"numbers"`
+	transitionRequest
+}
+
+// batchResponse - тело ответа на пакетный запрос, результаты в порядке посылок запроса
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// batchResult - результат пакетного запроса для одной посылки:
+// код ответа, который вернул бы запрос для одной посылки, и посылка или текст ошибки
+type batchResult struct {
+	Status int            `json:"status"`
+	Parcel *models.Parcel `json:"parcel,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// функция newBatchResponse возвращает тело ответа на пакетный запрос
+// Параметры
+// results - результаты сервиса
+// ok - код ответа для успешно обработанной посылки
+func newBatchResponse(results []serv.BatchResult, ok int) batchResponse {
+	resp := batchResponse{Results: make([]batchResult, len(results))}
+	for i, r := range results {
+		if r.Err != nil {
+			resp.Results[i] = batchResult{Status: statusCode(r.Err), Error: r.Err.Error()}
+			continue
+		}
+		parcel := r.Parcel
+		resp.Results[i] = batchResult{Status: ok, Parcel: &parcel}
+	}
+
+	return resp
+}
+
+// errorResponse - тело ответа с ошибкой
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// POST /parcels - регистрация посылки, в ответ возвращается созданная посылка.
+// Повторный запрос с тем же заголовком Idempotency-Key и тем же телом
+// возвращает посылку, зарегистрированную первым запросом
+func (h *Handler) register(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	request := req.request()
+	request.IdempotencyKey = r.Header.Get(headerIdempotencyKey)
+
+	parcel, err := h.service.Register(r.Context(), request)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/parcels/%d", parcel.Number))
+	writeJSON(w, http.StatusCreated, parcel)
+}
+
+// POST /parcels/batch - регистрация пакета посылок.
+// Ответ 200 содержит результат по каждой посылке: посылка с ошибкой в данных
+// не мешает зарегистрировать остальные. Ошибка всего запроса означает,
+// что не зарегистрирована ни одна посылка
+func (h *Handler) registerBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRegisterRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	requests := make([]serv.RegisterRequest, len(req.Parcels))
+	for i, p := range req.Parcels {
+		requests[i] = p.request()
+		requests[i].IdempotencyKey = p.IdempotencyKey
+	}
+
+	results, err := h.service.RegisterBatch(r.Context(), requests)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newBatchResponse(results, http.StatusCreated))
+}
+
+// POST /quotes - расчет стоимости доставки до регистрации посылки.
+// Тело запроса - как у POST /parcels, клиент и имена сторон не обязательны
+func (h *Handler) quote(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	quote, err := h.service.Quote(r.Context(), req.request())
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, quote)
+}
+
+// GET /parcels/{number} - получение посылки
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	number, err := pathInt(r, "number")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	parcel, err := h.service.Get(r.Context(), number)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parcel)
+}
+
+// GET /tracking/{code} - получение посылки по коду отслеживания
+func (h *Handler) track(w http.ResponseWriter, r *http.Request) {
+	parcel, err := h.service.GetByTrackingCode(r.Context(), r.PathValue("code"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parcel)
+}
+
+// GET /clients/{id}/parcels - посылки клиента
+func (h *Handler) clientParcels(w http.ResponseWriter, r *http.Request) {
+	client, err := pathInt(r, "id")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	parcels, err := h.service.ClientParcels(r.Context(), client)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parcels)
+}
+
+// GET /parcels - страница списка посылок.
+// Параметры запроса: client, status (можно указать несколько раз или через запятую),
+// created_from и created_to (RFC 3339), address (подстрока адреса), recipient_phone,
+// order_by (number или created_at), desc (true или false), limit, cursor,
+// include_deleted (true - включать удаленные посылки)
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	page, err := h.service.List(r.Context(), f)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// PATCH /parcels/{number}/address - изменение адреса, в ответ возвращается посылка
+func (h *Handler) changeAddress(w http.ResponseWriter, r *http.Request) {
+	number, err := pathInt(r, "number")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	var req addressRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.ChangeAddress(r.Context(), number, req.Address.Address); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeParcel(w, r, number)
+}
+
+// POST /parcels/{number}/next-status - перевод посылки в следующий статус маршрута
+func (h *Handler) nextStatus(w http.ResponseWriter, r *http.Request) {
+	number, err := pathInt(r, "number")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	parcel, err := h.service.NextStatus(r.Context(), number)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parcel)
+}
+
+// POST /parcels/{number}/status - перевод посылки в произвольный допустимый статус
+func (h *Handler) transition(w http.ResponseWriter, r *http.Request) {
+	number, err := pathInt(r, "number")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	var req transitionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	parcel, err := h.service.Transition(r.Context(), number, req.Status, req.options()...)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parcel)
+}
+
+// POST /parcels/batch/status - перевод пакета посылок в один статус.
+// Ответ 200 содержит результат по каждой посылке, как у POST /parcels/batch
+func (h *Handler) transitionBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchStatusRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	results, err := h.service.TransitionBatch(r.Context(), req.Numbers, req.Status, req.options()...)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newBatchResponse(results, http.StatusOK))
+}
+
+// GET /parcels/{number}/events - история смены статусов посылки
+func (h *Handler) history(w http.ResponseWriter, r *http.Request) {
+	number, err := pathInt(r, "number")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	events, err := h.service.History(r.Context(), number)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+// DELETE /parcels/{number} - удаление посылки, тело ответа пустое.
+// Удаленную посылку можно восстановить (POST /parcels/{number}/restore)
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	number, err := pathInt(r, "number")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), number); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /parcels/{number}/restore - восстановление удаленной посылки, в ответ возвращается посылка
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request) {
+	number, err := pathInt(r, "number")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	parcel, err := h.service.Restore(r.Context(), number)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parcel)
+}
+
+// метод writeParcel типа Handler отвечает актуальным состоянием посылки
+// после ее изменения
+func (h *Handler) writeParcel(w http.ResponseWriter, r *http.Request, number int) {
+	parcel, err := h.service.Get(r.Context(), number)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parcel)
+}
+
+// функция pathInt читает целочисленный параметр пути,
+// некорректное значение считается ошибкой проверки данных
+func pathInt(r *http.Request, name string) (int, error) {
+	value, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return 0, errors.Validation(name, "ожидается целое число")
+	}
+
+	return value, nil
+}
+
+// функция parseFilter разбирает параметры запроса списка посылок,
+// некорректное значение считается ошибкой проверки данных
+func parseFilter(query url.Values) (models.ParcelFilter, error) {
+	f := models.ParcelFilter{
+		AddressContains: query.Get("address"),
+		RecipientPhone:  query.Get("recipient_phone"),
+		OrderBy:         query.Get("order_by"),
+		Cursor:          query.Get("cursor"),
+	}
+
+	for _, value := range query["status"] {
+		for _, st := range strings.Split(value, ",") {
+			if st = strings.TrimSpace(st); st != "" {
+				f.Statuses = append(f.Statuses, st)
+			}
+		}
+	}
+
+	var err error
+	if f.Client, err = queryInt(query, "client"); err != nil {
+		return f, err
+	}
+	if f.Limit, err = queryInt(query, "limit"); err != nil {
+		return f, err
+	}
+	if f.CreatedFrom, err = queryTime(query, "created_from"); err != nil {
+		return f, err
+	}
+	if f.CreatedTo, err = queryTime(query, "created_to"); err != nil {
+		return f, err
+	}
+
+	if value := query.Get("desc"); value != "" {
+		if f.Desc, err = strconv.ParseBool(value); err != nil {
+			return f, errors.Validation("desc", "ожидается true или false")
+		}
+	}
+
+	if value := query.Get("include_deleted"); value != "" {
+		if f.IncludeDeleted, err = strconv.ParseBool(value); err != nil {
+			return f, errors.Validation("include_deleted", "ожидается true или false")
+		}
+	}
+
+	return f, nil
+}
+
+// функция queryInt читает необязательный целочисленный параметр запроса,
+// отсутствующий параметр равен 0
+func queryInt(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Validation(name, "ожидается целое число")
+	}
+
+	return n, nil
+}
+
+// функция queryTime читает необязательный параметр запроса с моментом времени в формате RFC 3339,
+// отсутствующий параметр равен нулевому времени
+func queryTime(query url.Values, name string) (time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.Validation(name, "ожидается дата и время в формате RFC 3339")
+	}
+
+	return t, nil
+}
+
+// функция decodeJSON разбирает тело запроса в v,
+// неизвестные поля и некорректный JSON считаются ошибкой проверки данных
+func decodeJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return errors.Validation("body", err.Error())
+	}
+
+	return nil
+}
+
+// функция statusCode возвращает код ответа HTTP для ошибки сервиса:
+// 400 - некорректные данные, 404 - объект не найден,
+// 409 - операция недопустима в текущем статусе, 500 - прочие ошибки
+func statusCode(err error) int {
+	switch {
+	case stderrors.Is(err, errors.ErrValidation):
+		return http.StatusBadRequest
+	case stderrors.Is(err, errors.ErrNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, errors.ErrInvalidState):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// метод writeError типа Handler отвечает на запрос ошибкой в формате JSON.
+// Текст внутренних ошибок не раскрывается клиенту, а записывается в журнал
+// вместе с методом и путем запроса
+// Параметры
+// w - ответ
+// r - запрос, на который произошла ошибка
+// err - ошибка
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	code := statusCode(err)
+
+	message := err.Error()
+	if code == http.StatusInternalServerError {
+		h.logger.ErrorContext(r.Context(), "внутренняя ошибка при обработке запроса",
+			slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("error", err))
+		message = http.StatusText(code)
+	}
+
+	writeJSON(w, code, errorResponse{Error: message})
+}
+
+// функция writeJSON отвечает на запрос значением v в формате JSON
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
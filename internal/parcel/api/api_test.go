@@ -0,0 +1,398 @@
+package api
+
+import (
+	// импортируем пакеты standard library
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/pricing"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+)
+
+// newTestServer возвращает обработчик API поверх хранилища в памяти
+// с активным клиентом 1
+func newTestServer(t *testing.T) *Handler {
+	m := store.NewMemoryStore()
+	clients := serv.NewClientService(m.Clients())
+	_, err := clients.Create(context.Background(), "test client", "", "")
+	require.NoError(t, err)
+
+	return NewHandler(serv.NewParcelService(m, m.Clients()), clients, nil)
+}
+
+// do выполняет запрос к обработчику и возвращает записанный ответ
+func do(t *testing.T, h http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// decode разбирает JSON-ответ в значение типа T
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var v T
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &v), rec.Body.String())
+
+	return v
+}
+
+// TestParcelLifecycle проверяет основной сценарий работы с посылкой через API
+func TestParcelLifecycle(t *testing.T) {
+	h := newTestServer(t)
+
+	// регистрация
+	rec := do(t, h, http.MethodPost, "/parcels", `{
+		"client": 1,
+		"sender": {"name": "ООО Ромашка", "phone": "+7 999 765-43-21"},
+		"recipient": {"name": "Иванов", "phone": "+79991234567", "address": "Псков, ул. Лесная, д. 1"},
+		"return_address": {"city": "Псков", "street": "ул. Садовая", "house": "3"},
+		"service_level": "express",
+		"weight": 2500,
+		"dimensions": {"length": 40, "width": 30, "height": 20},
+		"contents": "книги",
+		"declared_value": 150000
+	}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	parcel := decode[models.Parcel](t, rec)
+	assert.NotEmpty(t, parcel.Number)
+	assert.Equal(t, constants.ParcelStatusRegistered, parcel.Status)
+	assert.Equal(t, models.Party{Name: "ООО Ромашка", Phone: "+79997654321"}, parcel.Sender)
+	assert.Equal(t, "Иванов", parcel.Recipient.Name)
+	assert.Equal(t, &models.Address{City: "Псков", Street: "ул. Садовая", House: "3"}, parcel.ReturnAddress)
+	assert.Equal(t, constants.ServiceLevelExpress, parcel.ServiceLevel)
+	assert.Equal(t, models.Dimensions{Length: 40, Width: 30, Height: 20}, parcel.Dimensions)
+	assert.Contains(t, rec.Body.String(), `"volumetric_weight":4800`)
+	assert.Equal(t, int64(50000), parcel.Cost)
+	assert.Equal(t, "/parcels/1", rec.Header().Get("Location"))
+
+	// получение
+	rec = do(t, h, http.MethodGet, "/parcels/1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, parcel, decode[models.Parcel](t, rec))
+
+	// получение по коду отслеживания
+	require.NotEmpty(t, parcel.TrackingCode)
+	rec = do(t, h, http.MethodGet, "/tracking/"+strings.ToLower(parcel.TrackingCode), "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, parcel, decode[models.Parcel](t, rec))
+
+	// изменение адреса
+	rec = do(t, h, http.MethodPatch, "/parcels/1/address", `{"address": {"city": "Псков", "street": "Новая улица", "house": "дом 7"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, models.Address{City: "Псков", Street: "ул. Новая", House: "7"}, decode[models.Parcel](t, rec).Recipient.Address)
+
+	// следующий статус
+	rec = do(t, h, http.MethodPost, "/parcels/1/next-status", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, constants.ParcelStatusSent, decode[models.Parcel](t, rec).Status)
+
+	// перевод в произвольный статус
+	rec = do(t, h, http.MethodPost, "/parcels/1/status", `{"status": "lost", "actor": "operator-7"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, constants.ParcelStatusLost, decode[models.Parcel](t, rec).Status)
+
+	// история
+	rec = do(t, h, http.MethodGet, "/parcels/1/events", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	events := decode[[]models.StatusEvent](t, rec)
+	require.Len(t, events, 2)
+	assert.Equal(t, "operator-7", events[1].Actor)
+
+	// владелец посылки
+	rec = do(t, h, http.MethodGet, "/parcels/1/client", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 1, decode[models.Client](t, rec).ID)
+
+	// посылки клиента
+	rec = do(t, h, http.MethodGet, "/clients/1/parcels", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, decode[[]models.Parcel](t, rec), 1)
+
+	// список посылок с условиями выборки
+	rec = do(t, h, http.MethodGet, "/parcels?client=1&status=registered,lost&address=Новая&limit=10", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	page := decode[models.ParcelPage](t, rec)
+	require.Len(t, page.Parcels, 1)
+	assert.Empty(t, page.NextCursor)
+
+	// посылки получателя
+	rec = do(t, h, http.MethodGet, "/parcels?recipient_phone=%2B79991234567", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, decode[models.ParcelPage](t, rec).Parcels, 1)
+
+	// удаление зарегистрированной посылки
+	rec = do(t, h, http.MethodPost, "/parcels", `{"client": 1, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная, д. 1"}, "weight": 1000}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	rec = do(t, h, http.MethodDelete, "/parcels/2", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(t, h, http.MethodGet, "/parcels/2", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// удаленная посылка видна в списке только с include_deleted
+	rec = do(t, h, http.MethodGet, "/parcels?client=1", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, decode[models.ParcelPage](t, rec).Parcels, 1)
+	rec = do(t, h, http.MethodGet, "/parcels?client=1&include_deleted=true", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	page = decode[models.ParcelPage](t, rec)
+	require.Len(t, page.Parcels, 2)
+	assert.NotNil(t, page.Parcels[1].DeletedAt)
+	rec = do(t, h, http.MethodGet, "/parcels?include_deleted=maybe", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// восстановление удаленной посылки
+	rec = do(t, h, http.MethodPost, "/parcels/2/restore", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	restored := decode[models.Parcel](t, rec)
+	assert.Nil(t, restored.DeletedAt)
+	assert.NotContains(t, rec.Body.String(), "deleted_at")
+	rec = do(t, h, http.MethodGet, "/parcels/2", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = do(t, h, http.MethodPost, "/parcels/2/restore", "")
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+}
+
+// TestQuote проверяет расчет стоимости доставки через API
+func TestQuote(t *testing.T) {
+	h := newTestServer(t)
+
+	rec := do(t, h, http.MethodPost, "/quotes", `{"recipient": {"address": "Псков, ул. Лесная, д. 1"}, "weight": 4000}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	quote := decode[pricing.Quote](t, rec)
+	assert.Equal(t, pricing.ZoneLocal, quote.Zone)
+	assert.Equal(t, int64(30000), quote.Price)
+
+	rec = do(t, h, http.MethodPost, "/quotes", `{"recipient": {"address": "Псков, ул. Лесная, д. 1"}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	rec = do(t, h, http.MethodPost, "/quotes", `{"service_level": "express", "weight": 1000,
+		"recipient": {"address": {"country": "Казахстан", "city": "Алматы", "street": "пр-т Абая", "house": "1"}}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	assert.Contains(t, decode[errorResponse](t, rec).Error, "recipient.address")
+}
+
+// TestIdempotentRegister проверяет повторную регистрацию с заголовком Idempotency-Key
+func TestIdempotentRegister(t *testing.T) {
+	h := newTestServer(t)
+
+	register := func(key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/parcels", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	body := `{"client": 1, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная, д. 1"}, "weight": 1000}`
+
+	rec := register("order-1", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	first := decode[models.Parcel](t, rec)
+
+	rec = register("order-1", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, first, decode[models.Parcel](t, rec))
+	assert.Equal(t, "/parcels/1", rec.Header().Get("Location"))
+
+	rec = register("order-1", `{"client": 1, "recipient": {"name": "Петров", "address": "Псков, ул. Лесная, д. 1"}, "weight": 1000}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = register("", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, 2, decode[models.Parcel](t, rec).Number)
+}
+
+// TestBatch проверяет пакетную регистрацию и пакетный перевод посылок в статус
+func TestBatch(t *testing.T) {
+	h := newTestServer(t)
+
+	rec := do(t, h, http.MethodPost, "/parcels/batch", `{"parcels": [
+		{"client": 1, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная, д. 1"}, "weight": 1000},
+		{"client": 1, "recipient": {"name": "Петров"}, "weight": 1000},
+		{"client": 1, "recipient": {"name": "Сидоров", "address": "Псков, ул. Новая, д. 7"}, "weight": 500,
+		 "idempotency_key": "order-1"}
+	]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	resp := decode[batchResponse](t, rec)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, http.StatusCreated, resp.Results[0].Status)
+	require.NotNil(t, resp.Results[0].Parcel)
+	assert.Equal(t, 1, resp.Results[0].Parcel.Number)
+	assert.Equal(t, http.StatusBadRequest, resp.Results[1].Status)
+	assert.Nil(t, resp.Results[1].Parcel)
+	assert.NotEmpty(t, resp.Results[1].Error)
+	assert.Equal(t, http.StatusCreated, resp.Results[2].Status)
+	require.NotNil(t, resp.Results[2].Parcel)
+	assert.Equal(t, 2, resp.Results[2].Parcel.Number)
+
+	rec = do(t, h, http.MethodPost, "/parcels/batch/status",
+		`{"numbers": [1, 2, 3], "status": "cancelled", "actor": "склад"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	resp = decode[batchResponse](t, rec)
+	require.Len(t, resp.Results, 3)
+	for _, r := range resp.Results[:2] {
+		assert.Equal(t, http.StatusOK, r.Status)
+		require.NotNil(t, r.Parcel)
+		assert.Equal(t, "cancelled", r.Parcel.Status)
+	}
+	assert.Equal(t, http.StatusNotFound, resp.Results[2].Status)
+
+	// отмененную посылку нельзя отправить
+	rec = do(t, h, http.MethodPost, "/parcels/batch/status", `{"numbers": [1], "status": "sent"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusConflict, decode[batchResponse](t, rec).Results[0].Status)
+
+	// ошибки всего запроса
+	rec = do(t, h, http.MethodPost, "/parcels/batch", `{"parcels": []}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	rec = do(t, h, http.MethodPost, "/parcels/batch/status", `{"numbers": [1], "status": "unknown"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
+
+// TestClientLifecycle проверяет работу с клиентами через API
+func TestClientLifecycle(t *testing.T) {
+	h := newTestServer(t)
+
+	// создание
+	rec := do(t, h, http.MethodPost, "/clients", `{"name": "Иван Петров", "phone": "+7 999 123-45-67"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	client := decode[models.Client](t, rec)
+	assert.Equal(t, "+79991234567", client.Phone)
+	assert.True(t, client.Active)
+	assert.Equal(t, "/clients/2", rec.Header().Get("Location"))
+
+	// получение
+	rec = do(t, h, http.MethodGet, "/clients/2", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, client, decode[models.Client](t, rec))
+
+	// изменение
+	rec = do(t, h, http.MethodPut, "/clients/2", `{"name": "Иван Петров", "email": "ivan@example.com"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	client = decode[models.Client](t, rec)
+	assert.Equal(t, "ivan@example.com", client.Email)
+	assert.Empty(t, client.Phone)
+
+	// список с подстрокой имени
+	rec = do(t, h, http.MethodGet, "/clients?name=Иван", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []models.Client{client}, decode[[]models.Client](t, rec))
+
+	// после деактивации клиент выводится только с all=true, а посылки ему не регистрируются
+	rec = do(t, h, http.MethodPost, "/clients/2/deactivate", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.False(t, decode[models.Client](t, rec).Active)
+
+	rec = do(t, h, http.MethodGet, "/clients?limit=10", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, decode[[]models.Client](t, rec), 1)
+	rec = do(t, h, http.MethodGet, "/clients?all=true", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, decode[[]models.Client](t, rec), 2)
+
+	rec = do(t, h, http.MethodPost, "/parcels", `{"client": 2, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная, д. 1"}, "weight": 1000}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
+
+// TestErrorStatusCodes проверяет отображение ошибок сервиса на коды ответа
+func TestErrorStatusCodes(t *testing.T) {
+	h := newTestServer(t)
+
+	rec := do(t, h, http.MethodPost, "/parcels", `{"client": 1, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная, д. 1"}, "weight": 1000}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	rec = do(t, h, http.MethodPost, "/parcels/1/next-status", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		code   int
+	}{
+		{"некорректный JSON", http.MethodPost, "/parcels", `{"client":`, http.StatusBadRequest},
+		{"неизвестное поле", http.MethodPost, "/parcels", `{"client": 1, "addr": "test"}`, http.StatusBadRequest},
+		{"пустой адрес", http.MethodPost, "/parcels", `{"client": 1, "recipient": {"name": "Иванов", "address": ""}, "weight": 1000}`, http.StatusBadRequest},
+		{"адрес без дома", http.MethodPost, "/parcels", `{"client": 1, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная"}, "weight": 1000}`, http.StatusBadRequest},
+		{"неизвестная часть адреса", http.MethodPost, "/parcels", `{"client": 1, "recipient": {"name": "Иванов", "address": {"town": "Псков"}}, "weight": 1000}`, http.StatusBadRequest},
+		{"нет получателя", http.MethodPost, "/parcels", `{"client": 1, "recipient": {"address": "Псков, ул. Лесная, д. 1"}, "weight": 1000}`, http.StatusBadRequest},
+		{"нет веса", http.MethodPost, "/parcels", `{"client": 1, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная, д. 1"}}`, http.StatusBadRequest},
+		{"вес больше допустимого", http.MethodPost, "/parcels", `{"client": 1, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная, д. 1"}, "service_level": "express", "weight": 25000}`, http.StatusBadRequest},
+		{"нет клиента посылки", http.MethodPost, "/parcels", `{"client": 2, "recipient": {"name": "Иванов", "address": "Псков, ул. Лесная, д. 1"}, "weight": 1000}`, http.StatusBadRequest},
+		{"пустое имя клиента", http.MethodPost, "/clients", `{"name": " "}`, http.StatusBadRequest},
+		{"некорректный телефон", http.MethodPut, "/clients/1", `{"name": "test", "phone": "123"}`, http.StatusBadRequest},
+		{"нет клиента", http.MethodGet, "/clients/42", "", http.StatusNotFound},
+		{"деактивация без клиента", http.MethodPost, "/clients/42/deactivate", "", http.StatusNotFound},
+		{"номер не число", http.MethodGet, "/parcels/abc", "", http.StatusBadRequest},
+		{"нет посылки", http.MethodGet, "/parcels/42", "", http.StatusNotFound},
+		{"опечатка в коде отслеживания", http.MethodGet, "/tracking/RR123456795RU", "", http.StatusBadRequest},
+		{"неизвестный код отслеживания", http.MethodGet, "/tracking/RR123456785RU", "", http.StatusNotFound},
+		{"неизвестный статус в списке", http.MethodGet, "/parcels?status=unknown", "", http.StatusBadRequest},
+		{"некорректная дата в списке", http.MethodGet, "/parcels?created_from=yesterday", "", http.StatusBadRequest},
+		{"некорректный телефон в списке", http.MethodGet, "/parcels?recipient_phone=abc", "", http.StatusBadRequest},
+		{"некорректный курсор", http.MethodGet, "/parcels?cursor=abc", "", http.StatusBadRequest},
+		{"удаление отправленной", http.MethodDelete, "/parcels/1", "", http.StatusConflict},
+		{"адрес отправленной", http.MethodPatch, "/parcels/1/address", `{"address": "Псков, ул. Лесная, д. 2"}`, http.StatusConflict},
+		{"недопустимый переход", http.MethodPost, "/parcels/1/status", `{"status": "cancelled"}`, http.StatusConflict},
+		{"неизвестный статус", http.MethodPost, "/parcels/1/status", `{"status": "unknown"}`, http.StatusBadRequest},
+		{"неизвестный метод", http.MethodPut, "/parcels/1", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, tt.method, tt.target, tt.body)
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+
+			// ошибки, сформированные API, возвращаются в формате JSON
+			if rec.Code != http.StatusMethodNotAllowed {
+				assert.NotEmpty(t, decode[errorResponse](t, rec).Error)
+			}
+		})
+	}
+}
+
+// определяем структурный тип failingRepository - хранилище, чтение посылки из которого
+// завершается ошибкой, как при недоступной БД
+type failingRepository struct {
+	store.ParcelRepository
+}
+
+// Метод Get типа failingRepository всегда возвращает ошибку хранилища
+func (failingRepository) Get(context.Context, int) (models.Parcel, error) {
+	return models.Parcel{}, stderrors.New("database is locked")
+}
+
+// TestInternalError проверяет, что текст внутренней ошибки не передается клиенту,
+// а записывается в журнал вместе с методом и путем запроса
+func TestInternalError(t *testing.T) {
+	m := store.NewMemoryStore()
+	var log bytes.Buffer
+	h := NewHandler(serv.NewParcelService(failingRepository{m}, m.Clients()), serv.NewClientService(m.Clients()),
+		slog.New(slog.NewTextHandler(&log, nil)))
+
+	rec := do(t, h, http.MethodGet, "/parcels/1", "")
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), decode[errorResponse](t, rec).Error)
+
+	assert.Contains(t, log.String(), "level=ERROR")
+	assert.Contains(t, log.String(), "method=GET")
+	assert.Contains(t, log.String(), "path=/parcels/1")
+	assert.Contains(t, log.String(), `error="database is locked"`)
+}
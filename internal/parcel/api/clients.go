@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// в файле собраны обработчики запросов к клиентам - владельцам посылок
+
+// clientRequest - тело запросов POST /clients и PUT /clients/{id}
+type clientRequest struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+}
+
+// POST /clients - создание клиента, в ответ возвращается созданный клиент
+func (h *Handler) createClient(w http.ResponseWriter, r *http.Request) {
+	var req clientRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	client, err := h.clients.Create(r.Context(), req.Name, req.Phone, req.Email)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/clients/%d", client.ID))
+	writeJSON(w, http.StatusCreated, client)
+}
+
+// GET /clients - список клиентов по возрастанию идентификатора.
+// Параметры запроса: name (подстрока имени), all (true - включая деактивированных),
+// after (идентификатор последнего клиента предыдущей страницы), limit
+func (h *Handler) listClients(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f := models.ClientFilter{NameContains: query.Get("name")}
+
+	var err error
+	if f.AfterID, err = queryInt(query, "after"); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if f.Limit, err = queryInt(query, "limit"); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if value := query.Get("all"); value != "" {
+		if f.IncludeInactive, err = strconv.ParseBool(value); err != nil {
+			h.writeError(w, r, errors.Validation("all", "ожидается true или false"))
+			return
+		}
+	}
+
+	clients, err := h.clients.List(r.Context(), f)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, clients)
+}
+
+// GET /clients/{id} - получение клиента
+func (h *Handler) getClient(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	client, err := h.clients.Get(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, client)
+}
+
+// PUT /clients/{id} - замена имени и контактов клиента, в ответ возвращается клиент
+func (h *Handler) updateClient(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	var req clientRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	client, err := h.clients.Update(r.Context(), models.Client{ID: id, Name: req.Name, Phone: req.Phone, Email: req.Email})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, client)
+}
+
+// POST /clients/{id}/deactivate - деактивация клиента, в ответ возвращается клиент
+func (h *Handler) deactivateClient(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	client, err := h.clients.Deactivate(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, client)
+}
+
+// GET /parcels/{number}/client - клиент, которому принадлежит посылка
+func (h *Handler) owner(w http.ResponseWriter, r *http.Request) {
+	number, err := pathInt(r, "number")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	client, err := h.service.Owner(r.Context(), number)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, client)
+}
@@ -1,8 +1,118 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
-// статически создаем ошибку ErrUnsuccessful,
-// которая будет возникать в ситуациях, когда происходит попытка изменить адрес доставки посылки
-// или удалить посылку, но посылки с переданным номером нет в БД или ее статус не равен `зарегистрирована`
-var ErrUnsuccessful = errors.New("операция не выполнена: посылки с данным номером нет в БД или неподходящий для операции статус посылки")
+// в пакете определены ошибки, которые возвращают хранилища и сервис посылок.
+// Вызывающая сторона различает их через errors.Is по сигнальным значениям
+// ErrNotFound, ErrInvalidState и ErrValidation
+// или получает подробности через errors.As по типам NotFoundError, StateError и ValidationError
+
+var (
+	// ErrNotFound - запрошенный объект (например, посылка с переданным номером) отсутствует в БД
+	ErrNotFound = errors.New("объект не найден")
+	// ErrInvalidState - операция недопустима в текущем статусе посылки
+	ErrInvalidState = errors.New("операция недопустима в текущем статусе")
+	// ErrValidation - переданные данные не прошли проверку
+	ErrValidation = errors.New("некорректные данные")
+)
+
+// определяем структурный тип NotFoundError - ошибка отсутствия объекта
+type NotFoundError struct {
+	Entity string // вид объекта, например "посылка"
+	ID     any    // идентификатор, по которому искали объект
+}
+
+// Метод Error возвращает текст ошибки
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("не найдено: %s № %v", e.Entity, e.ID)
+}
+
+// Метод Is позволяет сравнивать ошибку с ErrNotFound через errors.Is
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// функция NotFound возвращает ошибку отсутствия объекта
+// Параметры
+// entity - вид объекта
+// id - идентификатор объекта
+func NotFound(entity string, id any) error {
+	return &NotFoundError{Entity: entity, ID: id}
+}
+
+// определяем структурный тип StateError - ошибка недопустимой операции или перехода
+// для посылки в ее текущем статусе
+type StateError struct {
+	Number  int      // номер посылки
+	Status  string   // текущий статус посылки
+	Op      string   // операция, которую пытались выполнить
+	To      string   // статус, в который пытались перевести посылку (для смены статуса)
+	Allowed []string // статусы, в которые посылку можно перевести из текущего
+}
+
+// Метод Error возвращает текст ошибки
+func (e *StateError) Error() string {
+	if e.To == "" {
+		return fmt.Sprintf("посылка № %d в статусе %q: операция %q недопустима", e.Number, e.Status, e.Op)
+	}
+
+	allowed := "статус конечный"
+	if len(e.Allowed) > 0 {
+		allowed = "допустимые статусы: " + strings.Join(e.Allowed, ", ")
+	}
+
+	return fmt.Sprintf("посылка № %d в статусе %q: переход в статус %q недопустим (%s)",
+		e.Number, e.Status, e.To, allowed)
+}
+
+// Метод Is позволяет сравнивать ошибку с ErrInvalidState через errors.Is
+func (e *StateError) Is(target error) bool {
+	return target == ErrInvalidState
+}
+
+// функция InvalidState возвращает ошибку недопустимой операции
+// Параметры
+// number - номер посылки
+// status - текущий статус посылки
+// op - название операции
+func InvalidState(number int, status string, op string) error {
+	return &StateError{Number: number, Status: status, Op: op}
+}
+
+// функция IllegalTransition возвращает ошибку недопустимого перехода между статусами
+// Параметры
+// number - номер посылки
+// from - текущий статус посылки
+// to - запрошенный статус
+// allowed - статусы, допустимые из текущего
+func IllegalTransition(number int, from string, to string, allowed []string) error {
+	return &StateError{Number: number, Status: from, Op: "смена статуса", To: to, Allowed: allowed}
+}
+
+// определяем структурный тип ValidationError - ошибка проверки входных данных
+type ValidationError struct {
+	Field  string // поле, не прошедшее проверку
+	Reason string // причина, по которой значение отклонено
+}
+
+// Метод Error возвращает текст ошибки
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("некорректное значение поля %s: %s", e.Field, e.Reason)
+}
+
+// Метод Is позволяет сравнивать ошибку с ErrValidation через errors.Is
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// функция Validation возвращает ошибку проверки входных данных
+// Параметры
+// field - поле, не прошедшее проверку
+// reason - причина
+func Validation(field string, reason string) error {
+	return &ValidationError{Field: field, Reason: reason}
+}
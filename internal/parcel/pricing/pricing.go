@@ -0,0 +1,175 @@
+package pricing
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// в пакете рассчитывается стоимость доставки посылки по таблице тарифов.
+// Тариф выбирается по уровню обслуживания, зоне доставки (см. Zone)
+// и оплачиваемому весу - большему из фактического и объемного.
+// Таблица загружается из JSON, формат - как у встроенного файла tariffs.json:
+//
+//	{
+//	  "origin": {"city": "Псков"},
+//	  "levels": {
+//	    "standard": {
+//	      "local": [{"max_weight": 1000, "price": 20000}, {"max_weight": 31500, "price": 90000}]
+//	    }
+//	  }
+//	}
+//
+// origin - адрес приема посылок, от которого считается зона, если у отправителя нет адреса;
+// levels - ступени тарифа по уровням обслуживания и зонам: вес до max_weight граммов включительно
+// стоит price копеек. Ступени перечисляются по возрастанию веса
+
+// defaultTariffs - таблица тарифов по умолчанию
+//
+//go:embed tariffs.json
+var defaultTariffs []byte
+
+// определяем структурный тип Bracket - ступень тарифа
+type Bracket struct {
+	MaxWeight int   `json:"max_weight"` // наибольший оплачиваемый вес ступени в граммах
+	Price     int64 `json:"price"`      // стоимость в копейках
+}
+
+// определяем структурный тип Table - таблица тарифов
+type Table struct {
+	Origin models.Address                  `json:"origin"` // адрес приема посылок
+	Levels map[string]map[string][]Bracket `json:"levels"` // ступени по уровням обслуживания и зонам
+}
+
+// определяем структурный тип Quote - расчет стоимости доставки
+type Quote struct {
+	ServiceLevel     string `json:"service_level"`     // уровень обслуживания
+	Zone             string `json:"zone"`              // зона доставки
+	Weight           int    `json:"weight"`            // фактический вес в граммах
+	VolumetricWeight int    `json:"volumetric_weight"` // объемный вес в граммах
+	ChargeableWeight int    `json:"chargeable_weight"` // оплачиваемый вес: больший из фактического и объемного
+	Price            int64  `json:"price"`             // стоимость в копейках
+}
+
+// функция Load читает таблицу тарифов в формате JSON и проверяет ее:
+// неизвестные поля и зоны, пустые и неупорядоченные ступени считаются ошибкой
+// Параметры
+// r - источник JSON
+func Load(r io.Reader) (Table, error) {
+	var t Table
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&t); err != nil {
+		return Table{}, fmt.Errorf("некорректная таблица тарифов: %w", err)
+	}
+
+	if err := t.validate(); err != nil {
+		return Table{}, fmt.Errorf("некорректная таблица тарифов: %w", err)
+	}
+
+	return t, nil
+}
+
+// функция LoadFile читает таблицу тарифов из файла, см. Load
+// Параметры
+// path - путь к файлу JSON
+func LoadFile(path string) (Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Table{}, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// функция DefaultTable возвращает встроенную таблицу тарифов из tariffs.json.
+// Каждый вызов возвращает новую таблицу, поэтому ее можно изменять
+func DefaultTable() Table {
+	t, err := Load(bytes.NewReader(defaultTariffs))
+	if err != nil {
+		// встроенный файл проверяется тестами пакета
+		panic(err)
+	}
+
+	return t
+}
+
+// метод validate типа Table проверяет уровни, зоны и ступени тарифов
+func (t Table) validate() error {
+	if len(t.Levels) == 0 {
+		return fmt.Errorf("не задан ни один уровень обслуживания")
+	}
+
+	for level, byZone := range t.Levels {
+		for zone, brackets := range byZone {
+			if !zones[zone] {
+				return fmt.Errorf("уровень %q: неизвестная зона %q", level, zone)
+			}
+			if len(brackets) == 0 {
+				return fmt.Errorf("уровень %q, зона %q: нет ступеней тарифа", level, zone)
+			}
+
+			prev := 0
+			for _, b := range brackets {
+				if b.MaxWeight <= prev {
+					return fmt.Errorf("уровень %q, зона %q: ступени должны идти по возрастанию веса", level, zone)
+				}
+				if b.Price < 0 {
+					return fmt.Errorf("уровень %q, зона %q: отрицательная стоимость", level, zone)
+				}
+				prev = b.MaxWeight
+			}
+		}
+	}
+
+	return nil
+}
+
+// Метод Quote типа Table рассчитывает стоимость доставки посылки p
+// по ее уровню обслуживания, весу, габаритам, адресам отправителя и получателя.
+// Если у отправителя нет адреса, зона считается от адреса приема Origin.
+// Уровень, зона или вес, для которых в таблице нет тарифа,
+// отклоняются с ошибкой errors.ValidationError
+// Параметры
+// p - посылка с проверенными характеристиками и адресами
+func (t Table) Quote(p models.Parcel) (Quote, error) {
+	q := Quote{
+		ServiceLevel:     p.ServiceLevel,
+		Weight:           p.Weight,
+		VolumetricWeight: p.VolumetricWeight(),
+	}
+	q.ChargeableWeight = max(q.Weight, q.VolumetricWeight)
+
+	from := p.Sender.Address
+	if from == (models.Address{}) {
+		from = t.Origin
+	}
+	q.Zone = Zone(from, p.Recipient.Address)
+
+	byZone, ok := t.Levels[p.ServiceLevel]
+	if !ok {
+		return q, errors.Validation("service_level", fmt.Sprintf("нет тарифов для уровня обслуживания %q", p.ServiceLevel))
+	}
+	brackets, ok := byZone[q.Zone]
+	if !ok {
+		return q, errors.Validation("recipient.address",
+			fmt.Sprintf("нет тарифов уровня обслуживания %q для зоны %q", p.ServiceLevel, q.Zone))
+	}
+
+	for _, b := range brackets {
+		if q.ChargeableWeight <= b.MaxWeight {
+			q.Price = b.Price
+			return q, nil
+		}
+	}
+
+	return q, errors.Validation("weight",
+		fmt.Sprintf("нет тарифа для оплачиваемого веса %d г в зоне %q", q.ChargeableWeight, q.Zone))
+}
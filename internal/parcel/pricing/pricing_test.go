@@ -0,0 +1,133 @@
+package pricing
+
+import (
+	// импортируем пакеты standard library
+	"strings"
+	"testing"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// адреса в тестах расчета
+var (
+	pskov    = models.Address{Region: "Псковская обл.", City: "Псков", Street: "ул. Лесная", House: "1"}
+	ostrov   = models.Address{Region: "Псковская обл.", City: "Остров", Street: "ул. Ленина", House: "2"}
+	moscow   = models.Address{City: "Москва", Street: "ул. Тверская", House: "1"}
+	almaty   = models.Address{Country: "Казахстан", City: "Алматы", Street: "пр-т Абая", House: "1"}
+	noRegion = models.Address{City: "Псков", Street: "ул. Новая", House: "7"}
+)
+
+// TestZone проверяет определение зоны доставки
+func TestZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to models.Address
+		want     string
+	}{
+		{"один город", pskov, pskov, ZoneLocal},
+		{"город без региона", pskov, noRegion, ZoneLocal},
+		{"регистр не важен", pskov, models.Address{City: "ПСКОВ"}, ZoneLocal},
+		{"один регион", pskov, ostrov, ZoneRegional},
+		{"регион не указан", pskov, moscow, ZoneNational},
+		{"разные регионы", pskov, models.Address{Region: "Ленинградская обл.", City: "Псков"}, ZoneNational},
+		{"Россия по умолчанию", moscow, models.Address{Country: "Россия", City: "Москва"}, ZoneLocal},
+		{"другая страна", pskov, almaty, ZoneInternational},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Zone(tt.from, tt.to))
+		})
+	}
+}
+
+// TestQuote проверяет расчет стоимости по встроенной таблице тарифов
+func TestQuote(t *testing.T) {
+	table := DefaultTable()
+
+	parcel := models.Parcel{
+		ServiceLevel: constants.ServiceLevelStandard,
+		Weight:       800,
+		Recipient:    models.Party{Address: noRegion},
+	}
+
+	// отправитель без адреса: зона считается от адреса приема
+	q, err := table.Quote(parcel)
+	require.NoError(t, err)
+	assert.Equal(t, Quote{ServiceLevel: constants.ServiceLevelStandard, Zone: ZoneLocal,
+		Weight: 800, ChargeableWeight: 800, Price: 20000}, q)
+
+	// объемный вес больше фактического: 40*30*20 см - 4800 г, ступень до 5 кг
+	parcel.Dimensions = models.Dimensions{Length: 40, Width: 30, Height: 20}
+	parcel.Sender.Address = ostrov
+	parcel.Recipient.Address = pskov
+	q, err = table.Quote(parcel)
+	require.NoError(t, err)
+	assert.Equal(t, ZoneRegional, q.Zone)
+	assert.Equal(t, 4800, q.ChargeableWeight)
+	assert.Equal(t, int64(40000), q.Price)
+
+	// граница ступени включается в нее
+	parcel.Dimensions = models.Dimensions{}
+	parcel.Weight = 5000
+	q, err = table.Quote(parcel)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40000), q.Price)
+
+	// срочная доставка в другую страну и вес больше последней ступени не тарифицируются
+	var validationErr *errors.ValidationError
+	parcel.ServiceLevel = constants.ServiceLevelExpress
+	parcel.Recipient.Address = almaty
+	_, err = table.Quote(parcel)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "recipient.address", validationErr.Field)
+
+	parcel.Recipient.Address = pskov
+	parcel.Weight = 20001
+	_, err = table.Quote(parcel)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "weight", validationErr.Field)
+
+	parcel.ServiceLevel = "overnight"
+	_, err = table.Quote(parcel)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "service_level", validationErr.Field)
+}
+
+// TestLoad проверяет чтение и проверку таблицы тарифов
+func TestLoad(t *testing.T) {
+	table, err := Load(strings.NewReader(`{
+		"origin": {"city": "Москва"},
+		"levels": {"standard": {"national": [{"max_weight": 1000, "price": 100}, {"max_weight": 2000, "price": 150}]}}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Москва", table.Origin.City)
+	assert.Len(t, table.Levels[constants.ServiceLevelStandard][ZoneNational], 2)
+
+	for name, data := range map[string]string{
+		"некорректный JSON":  `{"levels":`,
+		"неизвестное поле":   `{"levels": {"standard": {"local": [{"max_weight": 1, "price": 1}]}}, "currency": "RUB"}`,
+		"нет уровней":        `{"origin": {"city": "Москва"}}`,
+		"неизвестная зона":   `{"levels": {"standard": {"moon": [{"max_weight": 1, "price": 1}]}}}`,
+		"нет ступеней":       `{"levels": {"standard": {"local": []}}}`,
+		"порядок ступеней":   `{"levels": {"standard": {"local": [{"max_weight": 2, "price": 1}, {"max_weight": 1, "price": 1}]}}}`,
+		"нулевой вес":        `{"levels": {"standard": {"local": [{"max_weight": 0, "price": 1}]}}}`,
+		"отрицательная цена": `{"levels": {"standard": {"local": [{"max_weight": 1, "price": -1}]}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(data))
+			assert.Error(t, err)
+		})
+	}
+
+	// встроенная таблица корректна, и ее изменение не влияет на следующие вызовы
+	table = DefaultTable()
+	delete(table.Levels, constants.ServiceLevelExpress)
+	assert.Contains(t, DefaultTable().Levels, constants.ServiceLevelExpress)
+}
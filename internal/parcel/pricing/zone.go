@@ -0,0 +1,50 @@
+package pricing
+
+import (
+	"strings"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/address"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+)
+
+// зоны доставки в порядке увеличения расстояния
+const (
+	ZoneLocal         = "local"         // в пределах населенного пункта
+	ZoneRegional      = "regional"      // в пределах региона
+	ZoneNational      = "national"      // в пределах страны
+	ZoneInternational = "international" // в другую страну
+)
+
+// zones - известные зоны доставки
+var zones = map[string]bool{ZoneLocal: true, ZoneRegional: true, ZoneNational: true, ZoneInternational: true}
+
+// функция Zone определяет зону доставки из адреса from в адрес to.
+// Адрес без страны считается адресом в России, части сравниваются без учета регистра.
+// Посылка остается в пределах населенного пункта, если совпадают города,
+// а регионы совпадают или хотя бы у одного адреса не указаны
+// Параметры
+// from - адрес отправления, как правило уже приведенный address.Normalize
+// to - адрес доставки
+func Zone(from, to models.Address) string {
+	switch {
+	case !strings.EqualFold(country(from), country(to)):
+		return ZoneInternational
+	case from.Region != "" && to.Region != "" && !strings.EqualFold(from.Region, to.Region):
+		return ZoneNational
+	case from.City != "" && strings.EqualFold(from.City, to.City):
+		return ZoneLocal
+	case from.Region != "" && to.Region != "":
+		return ZoneRegional
+	default:
+		return ZoneNational
+	}
+}
+
+// функция country возвращает страну адреса, по умолчанию Россию
+func country(a models.Address) string {
+	if a.Country == "" {
+		return address.CountryRussia
+	}
+
+	return a.Country
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/pricing"
+)
+
+// в пакете собрано текстовое представление посылок и клиентов для человека:
+// по предложению на посылку, событие, клиента или расчет стоимости. Сервис посылок возвращает только данные,
+// а выводит их утилита командной строки с форматом --output text
+
+// Функция Parcel выводит посылку одним предложением,
+// код отслеживания и момент удаления добавляются, если они есть у посылки
+// Параметры
+// w - поток вывода
+// p - посылка
+func Parcel(w io.Writer, p models.Parcel) error {
+	line := fmt.Sprintf("Посылка № %d для %s на адрес %s от клиента с идентификатором %d зарегистрирована %s, статус %s",
+		p.Number, p.Recipient.Name, p.Recipient.Address, p.Client, formatTime(p.CreatedAt), p.Status)
+	if p.TrackingCode != "" {
+		line += ", код отслеживания " + p.TrackingCode
+	}
+	if p.DeletedAt != nil {
+		line += ", удалена " + formatTime(*p.DeletedAt)
+	}
+
+	_, err := fmt.Fprintln(w, line)
+
+	return err
+}
+
+// Функция Quote выводит расчет стоимости доставки одним предложением
+// Параметры
+// w - поток вывода
+// q - расчет стоимости
+func Quote(w io.Writer, q pricing.Quote) error {
+	_, err := fmt.Fprintf(w, "Доставка %s в зону %s, оплачиваемый вес %d г: %s руб.\n",
+		q.ServiceLevel, q.Zone, q.ChargeableWeight, formatMoney(q.Price))
+
+	return err
+}
+
+// Функция Parcels выводит посылки по одной на строке
+// Параметры
+// w - поток вывода
+// parcels - посылки
+func Parcels(w io.Writer, parcels []models.Parcel) error {
+	for _, p := range parcels {
+		if err := Parcel(w, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Функция ClientParcels выводит посылки клиента под заголовком с его идентификатором
+// Параметры
+// w - поток вывода
+// client - идентификатор клиента
+// parcels - посылки клиента
+func ClientParcels(w io.Writer, client int, parcels []models.Parcel) error {
+	if _, err := fmt.Fprintf(w, "Посылки клиента %d:\n", client); err != nil {
+		return err
+	}
+
+	if err := Parcels(w, parcels); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+
+	return err
+}
+
+// Функция Events выводит историю смены статусов посылки, по событию на строке
+// Параметры
+// w - поток вывода
+// events - события в хронологическом порядке
+func Events(w io.Writer, events []models.StatusEvent) error {
+	for _, ev := range events {
+		line := fmt.Sprintf("%s: у посылки № %d новый статус %s (был %s), исполнитель %s",
+			formatTime(ev.At), ev.Parcel, ev.To, ev.From, ev.Actor)
+		if ev.Location != "" {
+			line += ", место: " + ev.Location
+		}
+		if ev.Comment != "" {
+			line += ", комментарий: " + ev.Comment
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Функция Client выводит клиента одним предложением
+// Параметры
+// w - поток вывода
+// c - клиент
+func Client(w io.Writer, c models.Client) error {
+	line := fmt.Sprintf("Клиент № %d %q", c.ID, c.Name)
+	if c.Phone != "" {
+		line += ", телефон " + c.Phone
+	}
+	if c.Email != "" {
+		line += ", почта " + c.Email
+	}
+	if !c.Active {
+		line += ", деактивирован"
+	}
+
+	_, err := fmt.Fprintln(w, line)
+
+	return err
+}
+
+// функция formatMoney выводит сумму в копейках в рублях: "350.00"
+func formatMoney(kopecks int64) string {
+	return fmt.Sprintf("%d.%02d", kopecks/100, kopecks%100)
+}
+
+// функция formatTime выводит момент времени в формате RFC 3339 (UTC)
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
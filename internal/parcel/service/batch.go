@@ -0,0 +1,336 @@
+package parcel_service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+)
+
+// в файле собраны пакетные операции для складов, которые передают манифест из сотен посылок.
+// Посылки пакета сохраняются в хранилище одной транзакцией (см. store.ParcelRepository.AddBatch
+// и SetStatusBatch), а результат возвращается по каждой посылке отдельно:
+// посылка, не прошедшая проверку, не мешает сохранить остальные
+
+// наибольшее число посылок в одном пакете
+const maxBatchSize = 1000
+
+// поле запроса с посылками пакета, указывается в ошибках проверки
+const fieldBatch = "parcels"
+
+// определяем структурный тип BatchResult - результат пакетной операции для одной посылки
+type BatchResult struct {
+	Parcel models.Parcel // посылка после операции, заполнена, если Err равна nil
+	Err    error         // ошибка проверки или errors.StateError для этой посылки
+}
+
+// функция checkBatchSize проверяет число посылок в пакете
+func checkBatchSize(n int) error {
+	if n == 0 {
+		return errors.Validation(fieldBatch, "пакет не содержит посылок")
+	}
+	if n > maxBatchSize {
+		return errors.Validation(fieldBatch, fmt.Sprintf("в пакете больше %d посылок", maxBatchSize))
+	}
+
+	return nil
+}
+
+// функция itemError проверяет, относится ли ошибка к одной посылке пакета:
+// ошибки проверки, отсутствия посылки и недопустимого статуса записываются в результат посылки,
+// остальные (например, ошибки БД) прерывают весь пакет
+func itemError(err error) bool {
+	return stderrors.Is(err, errors.ErrValidation) ||
+		stderrors.Is(err, errors.ErrNotFound) ||
+		stderrors.Is(err, errors.ErrInvalidState)
+}
+
+// Метод RegisterBatch типа ParcelService
+// регистрирует посылки пакета и возвращает результаты в порядке reqs.
+// Каждый запрос проверяется как в Register, ошибка проверки записывается в его результат,
+// а посылки, прошедшие проверку, сохраняются одной транзакцией.
+// Запросы пакета с одним ключом идемпотентности клиента регистрируют одну посылку,
+// если их данные совпадают, иначе второй запрос отклоняется с ошибкой errors.ValidationError.
+// Ошибка вызова (пустой или слишком большой пакет, ошибка хранилища) означает,
+// что не зарегистрирована ни одна посылка
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// reqs - запросы на регистрацию
+func (s ParcelService) RegisterBatch(ctx context.Context, reqs []RegisterRequest) ([]BatchResult, error) {
+	b, err := s.prepareBatch(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers, err := s.addBatch(ctx, b.pending)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, number := range numbers {
+		b.pending[j].parcel.Number = number
+		s.logRegistered(ctx, b.pending[j].parcel)
+	}
+
+	return b.results(), nil
+}
+
+// Метод CheckBatch типа ParcelService
+// проверяет запросы пакета так же, как RegisterBatch, но не сохраняет посылки:
+// в результатах - посылки без номера, какими они были бы зарегистрированы, или ошибки проверки.
+// Используется для пробного импорта
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// reqs - запросы на регистрацию
+func (s ParcelService) CheckBatch(ctx context.Context, reqs []RegisterRequest) ([]BatchResult, error) {
+	b, err := s.prepareBatch(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.results(), nil
+}
+
+// определяем структурный тип batch - проверенный пакет запросов на регистрацию
+type batch struct {
+	items   []BatchResult  // ошибки проверки и повторы, найденные по ключу в хранилище
+	pending []registration // посылки для сохранения
+	indexes []int          // позиции запросов посылок pending в пакете
+	aliases map[int]int    // повторы ключа внутри пакета и позиции их первых запросов
+}
+
+// метод results типа batch возвращает результаты пакета:
+// к ошибкам и повторам добавляются посылки pending, а повторы ключа внутри пакета
+// получают посылку первого запроса
+func (b batch) results() []BatchResult {
+	for j, r := range b.pending {
+		b.items[b.indexes[j]].Parcel = r.parcel
+	}
+	for i, first := range b.aliases {
+		b.items[i].Parcel = b.items[first].Parcel
+	}
+
+	return b.items
+}
+
+// метод prepareBatch типа ParcelService
+// проверяет запросы пакета (см. prepare) и отбирает посылки для сохранения
+// Параметры
+// ctx - контекст запроса
+// reqs - запросы на регистрацию
+func (s ParcelService) prepareBatch(ctx context.Context, reqs []RegisterRequest) (batch, error) {
+	if err := checkBatchSize(len(reqs)); err != nil {
+		return batch{}, err
+	}
+
+	var (
+		b     = batch{items: make([]BatchResult, len(reqs)), aliases: make(map[int]int)}
+		taken = make(map[string]bool)        // коды отслеживания, выданные посылкам пакета
+		keys  = make(map[idempotencyKey]int) // посылка pending, первой указавшая ключ
+	)
+	for i, req := range reqs {
+		r, err := s.prepare(ctx, req, taken)
+		if err != nil {
+			if !itemError(err) {
+				return batch{}, err
+			}
+			b.items[i].Err = err
+			continue
+		}
+		if r.replayed {
+			b.items[i].Parcel = r.parcel
+			continue
+		}
+
+		if r.key != "" {
+			k := idempotencyKey{client: r.parcel.Client, key: r.key}
+			if first, ok := keys[k]; ok {
+				if b.pending[first].fp != r.fp {
+					b.items[i].Err = errors.Validation(fieldIdempotencyKey,
+						fmt.Sprintf("ключ уже использован в пакете с другими данными запроса (позиция %d)", b.indexes[first]+1))
+				} else {
+					b.aliases[i] = b.indexes[first]
+				}
+				continue
+			}
+			keys[k] = len(b.pending)
+		}
+		b.pending = append(b.pending, r)
+		b.indexes = append(b.indexes, i)
+	}
+
+	return b, nil
+}
+
+// определяем структурный тип idempotencyKey - ключ идемпотентности в пределах клиента
+type idempotencyKey struct {
+	client int    // идентификатор клиента
+	key    string // ключ идемпотентности
+}
+
+// метод addBatch типа ParcelService
+// сохраняет посылки пакета и их ключи идемпотентности в одной транзакции
+// и возвращает номера посылок в порядке pending.
+// Если код отслеживания одной из посылок успела занять другая посылка (store.ErrTrackingCodeTaken),
+// всем посылкам пакета выдаются новые коды и пакет сохраняется заново, как в add
+// Параметры
+// ctx - контекст запроса
+// pending - проверенные запросы на регистрацию; при повторе коды посылок заменяются новыми
+func (s ParcelService) addBatch(ctx context.Context, pending []registration) ([]int, error) {
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		parcels := make([]models.Parcel, len(pending))
+		for i, r := range pending {
+			parcels[i] = r.parcel
+		}
+
+		var numbers []int
+		err := s.store.WithTx(ctx, func(tx store.ParcelRepository) error {
+			var err error
+			if numbers, err = tx.AddBatch(ctx, parcels); err != nil {
+				return err
+			}
+
+			for i, r := range pending {
+				if err := addIdempotencyKey(ctx, tx, r, numbers[i]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if !stderrors.Is(err, store.ErrTrackingCodeTaken) || attempt == maxTrackingAttempts {
+			return numbers, err
+		}
+
+		s.logger.WarnContext(ctx, "код отслеживания занят, посылкам пакета выдаются новые",
+			slog.Int("parcels", len(pending)))
+		taken := make(map[string]bool)
+		for i := range pending {
+			if pending[i].parcel.TrackingCode, err = s.trackingCode(ctx, taken); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Метод TransitionBatch типа ParcelService
+// переводит посылки пакета в статус to и возвращает результаты в порядке numbers.
+// Отсутствующая посылка, посылка, для которой переход недопустим (см. Transition),
+// и повтор номера в пакете записываются в результат посылки, остальные посылки
+// переводятся в статус to одной транзакцией с общим временем события: статусы меняет
+// один подготовленный запрос store.ParcelRepository.SetStatusBatch, а события записывает AddEvents.
+// Статус каждой посылки меняется условно, только если он равен проверенному:
+// если параллельный запрос изменил его после проверки, посылка получает errors.StateError
+// с текущим статусом, а остальные посылки пакета переводятся.
+// Неизвестный статус и ошибка хранилища возвращаются как ошибка вызова:
+// в этом случае статус не меняется ни у одной посылки
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// numbers - номера посылок
+// to - новый статус
+// opts - сведения для записи в историю, общие для всех посылок пакета
+func (s ParcelService) TransitionBatch(ctx context.Context, numbers []int, to string, opts ...EventOption) ([]BatchResult, error) {
+	if !s.statuses.Known(to) {
+		return nil, errors.Validation("status", fmt.Sprintf("неизвестный статус %q", to))
+	}
+	if err := checkBatchSize(len(numbers)); err != nil {
+		return nil, err
+	}
+
+	var (
+		results []BatchResult
+		events  []models.StatusEvent // события посылок, переведенных в статус to, по позициям пакета
+	)
+	err := s.store.WithTx(ctx, func(tx store.ParcelRepository) error {
+		results = make([]BatchResult, len(numbers))
+		events = make([]models.StatusEvent, len(numbers))
+
+		var (
+			seen    = make(map[int]bool, len(numbers))
+			indexes []int // позиции посылок, которые можно перевести в статус to
+		)
+		for i, number := range numbers {
+			if seen[number] {
+				results[i].Err = errors.Validation(fieldBatch, fmt.Sprintf("посылка № %d указана в пакете повторно", number))
+				continue
+			}
+			seen[number] = true
+
+			parcel, err := tx.Get(ctx, number)
+			if err == nil {
+				err = s.checkTransition(parcel, to)
+			}
+			if err != nil {
+				if !itemError(err) {
+					return err
+				}
+				results[i].Err = err
+				continue
+			}
+
+			results[i].Parcel = parcel
+			indexes = append(indexes, i)
+		}
+
+		if len(indexes) == 0 {
+			return nil
+		}
+
+		// время изменения посылок совпадает со временем событий в истории
+		at := s.now()
+		changes := make([]store.StatusChange, len(indexes))
+		for j, i := range indexes {
+			changes[j] = store.StatusChange{Number: numbers[i], From: results[i].Parcel.Status}
+		}
+		failures, err := tx.SetStatusBatch(ctx, changes, to, at)
+		if err != nil {
+			return err
+		}
+
+		var changed []models.StatusEvent
+		for j, i := range indexes {
+			if failures[j] != nil {
+				results[i] = BatchResult{Err: failures[j]}
+				continue
+			}
+			events[i] = newEvent(results[i].Parcel, to, at, opts)
+			changed = append(changed, events[i])
+		}
+		if err := tx.AddEvents(ctx, changed); err != nil {
+			return err
+		}
+
+		// время отправки и доставки заполняет хранилище, поэтому посылки перечитываем
+		for _, i := range indexes {
+			if results[i].Err != nil {
+				continue
+			}
+			updated, err := tx.Get(ctx, numbers[i])
+			if err != nil {
+				return err
+			}
+			results[i].Parcel = updated
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range results {
+		if r.Err == nil {
+			s.logStatusChange(ctx, r.Parcel, events[i])
+		}
+	}
+
+	return results, nil
+}
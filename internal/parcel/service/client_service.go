@@ -0,0 +1,178 @@
+package parcel_service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+)
+
+// ограничения на данные клиента
+const (
+	maxClientNameLen = 256 // наибольшая длина имени в символах
+	minPhoneDigits   = 10  // наименьшее число цифр телефона
+	maxPhoneDigits   = 15  // наибольшее число цифр телефона (E.164)
+)
+
+// создаем структурный тип ClientService - операции с клиентами, владельцами посылок
+type ClientService struct {
+	options                        // часы и журнал сервиса
+	store   store.ClientRepository // хранилище клиентов
+}
+
+// Функция NewClientService возвращает новый экземпляр типа ClientService
+// Параметры
+// store - хранилище клиентов, реализующее интерфейс ClientRepository
+// opts - необязательные настройки сервиса
+func NewClientService(store store.ClientRepository, opts ...Option) ClientService {
+	return ClientService{options: newOptions(opts), store: store}
+}
+
+// Метод Create типа ClientService
+// создает активного клиента и возвращает его.
+// Имя обязательно, телефон и адрес электронной почты - нет,
+// некорректные значения отклоняются с ошибкой errors.ValidationError
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// name - имя клиента или название организации
+// phone - телефон, допускаются пробелы, дефисы и скобки между цифрами
+// email - адрес электронной почты
+func (s ClientService) Create(ctx context.Context, name string, phone string, email string) (models.Client, error) {
+	c, err := normalizeClient(models.Client{Name: name, Phone: phone, Email: email})
+	if err != nil {
+		return models.Client{}, err
+	}
+
+	c.Active = true
+	c.CreatedAt = s.now()
+	c.UpdatedAt = c.CreatedAt
+
+	id, err := s.store.Create(ctx, c)
+	if err != nil {
+		return models.Client{}, err
+	}
+	c.ID = id
+
+	s.logger.InfoContext(ctx, "клиент создан", slog.Int("client", c.ID))
+
+	return c, nil
+}
+
+// Метод Get типа ClientService
+// возвращает клиента по идентификатору или ошибку errors.NotFoundError
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// id - идентификатор клиента
+func (s ClientService) Get(ctx context.Context, id int) (models.Client, error) {
+	return s.store.Get(ctx, id)
+}
+
+// Метод Update типа ClientService
+// заменяет имя, телефон и адрес электронной почты клиента c.ID
+// и возвращает клиента после изменения. Данные проверяются так же, как в Create
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// c - идентификатор клиента и новые данные
+func (s ClientService) Update(ctx context.Context, c models.Client) (models.Client, error) {
+	c, err := normalizeClient(c)
+	if err != nil {
+		return models.Client{}, err
+	}
+
+	c.UpdatedAt = s.now()
+	if err := s.store.Update(ctx, c); err != nil {
+		return models.Client{}, err
+	}
+
+	s.logger.InfoContext(ctx, "клиент изменен", slog.Int("client", c.ID))
+
+	return s.store.Get(ctx, c.ID)
+}
+
+// Метод List типа ClientService
+// возвращает клиентов по возрастанию идентификатора,
+// следующая страница запрашивается с AfterID, равным идентификатору последнего клиента
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// f - условия выборки
+func (s ClientService) List(ctx context.Context, f models.ClientFilter) ([]models.Client, error) {
+	return s.store.List(ctx, f)
+}
+
+// Метод Deactivate типа ClientService
+// деактивирует клиента: его посылки сохраняются, но новые не регистрируются.
+// Возвращает клиента после изменения или ошибку errors.NotFoundError
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// id - идентификатор клиента
+func (s ClientService) Deactivate(ctx context.Context, id int) (models.Client, error) {
+	if err := s.store.Deactivate(ctx, id, s.now()); err != nil {
+		return models.Client{}, err
+	}
+
+	s.logger.InfoContext(ctx, "клиент деактивирован", slog.Int("client", id))
+
+	return s.store.Get(ctx, id)
+}
+
+// функция normalizeClient проверяет имя и контакты клиента
+// и приводит их к виду, в котором они хранятся:
+// без пробелов по краям, телефон - только цифры с ведущим +
+func normalizeClient(c models.Client) (models.Client, error) {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return c, errors.Validation("name", "имя клиента не может быть пустым")
+	}
+	if utf8.RuneCountInString(c.Name) > maxClientNameLen {
+		return c, errors.Validation("name", fmt.Sprintf("имя клиента длиннее %d символов", maxClientNameLen))
+	}
+
+	phone, err := normalizePhone(c.Phone)
+	if err != nil {
+		return c, err
+	}
+	c.Phone = phone
+
+	c.Email = strings.TrimSpace(c.Email)
+	if c.Email != "" {
+		addr, err := mail.ParseAddress(c.Email)
+		if err != nil || addr.Address != c.Email {
+			return c, errors.Validation("email", fmt.Sprintf("некорректный адрес электронной почты %q", c.Email))
+		}
+	}
+
+	return c, nil
+}
+
+// функция normalizePhone убирает из телефона пробелы, дефисы и скобки
+// и проверяет, что остались от minPhoneDigits до maxPhoneDigits цифр.
+// Пустой телефон допустим
+func normalizePhone(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return "", nil
+	}
+
+	digits := strings.TrimPrefix(phone, "+")
+	digits = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(digits)
+
+	valid := len(digits) >= minPhoneDigits && len(digits) <= maxPhoneDigits
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			valid = false
+			break
+		}
+	}
+	if !valid {
+		return "", errors.Validation("phone",
+			fmt.Sprintf("телефон %q должен содержать от %d до %d цифр", phone, minPhoneDigits, maxPhoneDigits))
+	}
+
+	return "+" + digits, nil
+}
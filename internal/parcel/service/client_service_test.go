@@ -0,0 +1,99 @@
+package parcel_service
+
+import (
+	// импортируем пакеты standard library
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/clock/clocktest"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+)
+
+// newTestClientService возвращает сервис клиентов, работающий с хранилищем в памяти,
+// часы сервиса сдвигаются на секунду при каждом обращении
+func newTestClientService() ClientService {
+	return NewClientService(store.NewMemoryStore().Clients(), WithClock(clocktest.NewTicking(clocktest.Epoch, time.Second)))
+}
+
+// TestClientLifecycle проверяет создание, изменение и деактивацию клиента
+func TestClientLifecycle(t *testing.T) {
+	ctx := context.Background()
+	service := newTestClientService()
+
+	client, err := service.Create(ctx, "  Иван Петров ", "+7 (999) 123-45-67", "ivan@example.com")
+	require.NoError(t, err)
+	require.NotEmpty(t, client.ID)
+	assert.Equal(t, "Иван Петров", client.Name)
+	assert.Equal(t, "+79991234567", client.Phone)
+	assert.True(t, client.Active)
+	assert.Equal(t, clocktest.Epoch, client.CreatedAt)
+
+	stored, err := service.Get(ctx, client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, client, stored)
+
+	client.Email = ""
+	client.Phone = "8 999 765 43 21"
+	updated, err := service.Update(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, "+89997654321", updated.Phone)
+	assert.Empty(t, updated.Email)
+	assert.Equal(t, client.CreatedAt, updated.CreatedAt)
+	assert.True(t, updated.UpdatedAt.After(updated.CreatedAt))
+
+	deactivated, err := service.Deactivate(ctx, client.ID)
+	require.NoError(t, err)
+	assert.False(t, deactivated.Active)
+
+	// деактивированный клиент выводится только по запросу
+	clients, err := service.List(ctx, models.ClientFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, clients)
+	clients, err = service.List(ctx, models.ClientFilter{IncludeInactive: true})
+	require.NoError(t, err)
+	assert.Len(t, clients, 1)
+
+	_, err = service.Deactivate(ctx, client.ID+1)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// TestClientValidation проверяет отклонение некорректных данных клиента
+func TestClientValidation(t *testing.T) {
+	ctx := context.Background()
+	service := newTestClientService()
+
+	tests := []struct {
+		name  string
+		phone string
+		email string
+		field string
+	}{
+		{name: " ", field: "name"},
+		{name: strings.Repeat("я", maxClientNameLen+1), field: "name"},
+		{name: "test", phone: "12345", field: "phone"},
+		{name: "test", phone: "+7 999 abc 45 67", field: "phone"},
+		{name: "test", email: "not an email", field: "email"},
+		{name: "test", email: "Иван <ivan@example.com>", field: "email"},
+	}
+
+	for _, tt := range tests {
+		_, err := service.Create(ctx, tt.name, tt.phone, tt.email)
+		var validationErr *errors.ValidationError
+		if assert.ErrorAs(t, err, &validationErr, "%+v", tt) {
+			assert.Equal(t, tt.field, validationErr.Field)
+		}
+	}
+
+	clients, err := service.List(ctx, models.ClientFilter{IncludeInactive: true})
+	require.NoError(t, err)
+	assert.Empty(t, clients)
+}
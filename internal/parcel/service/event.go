@@ -0,0 +1,53 @@
+package parcel_service
+
+import (
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+)
+
+// DefaultActor - исполнитель, которым подписываются события без явно указанного исполнителя
+const DefaultActor = "system"
+
+// EventOption дополняет событие смены статуса сведениями,
+// которые передает вызывающая сторона (см. WithActor, WithLocation, WithComment)
+type EventOption func(ev *models.StatusEvent)
+
+// функция WithActor указывает, кто изменил статус посылки
+// Параметры
+// actor - идентификатор сотрудника, партнера или системы
+func WithActor(actor string) EventOption {
+	return func(ev *models.StatusEvent) { ev.Actor = actor }
+}
+
+// функция WithLocation указывает, где произошло событие
+// Параметры
+// location - место, например сортировочный центр
+func WithLocation(location string) EventOption {
+	return func(ev *models.StatusEvent) { ev.Location = location }
+}
+
+// функция WithComment добавляет к событию комментарий
+// Параметры
+// comment - произвольный текст
+func WithComment(comment string) EventOption {
+	return func(ev *models.StatusEvent) { ev.Comment = comment }
+}
+
+// функция newEvent создает событие перевода посылки parcel в статус to в момент at
+// и применяет к нему опции вызывающей стороны
+func newEvent(parcel models.Parcel, to string, at time.Time, opts []EventOption) models.StatusEvent {
+	ev := models.StatusEvent{
+		Parcel: parcel.Number,
+		From:   parcel.Status,
+		To:     to,
+		At:     at,
+		Actor:  DefaultActor,
+	}
+
+	for _, opt := range opts {
+		opt(&ev)
+	}
+
+	return ev
+}
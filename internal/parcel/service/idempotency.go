@@ -0,0 +1,143 @@
+package parcel_service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+)
+
+// в файле собрана идемпотентная регистрация посылок. Клиент, который повторяет запрос
+// после таймаута, передает тот же ключ идемпотентности: Register находит посылку,
+// зарегистрированную по ключу, и возвращает ее вместо новой.
+// Повтор отличается от другого запроса с тем же ключом по отпечатку данных запроса
+
+// наибольшая длина ключа идемпотентности в символах
+const maxIdempotencyKeyLen = 128
+
+// поле запроса с ключом идемпотентности, указывается в ошибках проверки
+const fieldIdempotencyKey = "idempotency_key"
+
+// функция checkIdempotencyKey проверяет ключ идемпотентности:
+// ключ из печатных символов без пробелов по краям, пустой ключ означает запрос без ключа
+func checkIdempotencyKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if utf8.RuneCountInString(key) > maxIdempotencyKeyLen {
+		return key, errors.Validation(fieldIdempotencyKey, fmt.Sprintf("ключ длиннее %d символов", maxIdempotencyKeyLen))
+	}
+	if strings.IndexFunc(key, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return key, errors.Validation(fieldIdempotencyKey, "ключ содержит непечатные символы")
+	}
+
+	return key, nil
+}
+
+// функция fingerprint возвращает отпечаток запроса на регистрацию - SHA-256 от его данных
+// в формате JSON. Запрос передается после проверки и приведения к каноническому виду,
+// поэтому запросы, различающиеся только записью телефона или адреса, совпадают.
+// Сам ключ в отпечаток не входит
+func fingerprint(req RegisterRequest) (string, error) {
+	req.IdempotencyKey = ""
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// метод replay типа ParcelService
+// ищет посылку, зарегистрированную клиентом по ключу идемпотентности.
+// Возвращает found = false, если ключ еще не использован. Если ключ использован
+// с другими данными запроса, возвращает found = true и ошибку errors.ValidationError.
+// Ключ удаленной посылки остается занятым до ее окончательного удаления (Purge):
+// повтор запроса возвращает errors.NotFoundError, а не регистрирует посылку заново
+// Параметры
+// ctx - контекст запроса
+// client - идентификатор клиента
+// key - ключ идемпотентности
+// fp - отпечаток данных текущего запроса
+func (s ParcelService) replay(ctx context.Context, client int, key string, fp string) (parcel models.Parcel, found bool, err error) {
+	k, err := s.store.GetIdempotencyKey(ctx, client, key)
+	if stderrors.Is(err, errors.ErrNotFound) {
+		return models.Parcel{}, false, nil
+	}
+	if err != nil {
+		return models.Parcel{}, false, err
+	}
+
+	if k.Fingerprint != fp {
+		return models.Parcel{}, true, errors.Validation(fieldIdempotencyKey,
+			fmt.Sprintf("ключ уже использован для посылки № %d с другими данными запроса", k.Parcel))
+	}
+
+	parcel, err = s.store.Get(ctx, k.Parcel)
+	if err != nil {
+		return models.Parcel{}, true, err
+	}
+
+	s.logger.InfoContext(ctx, "повторный запрос регистрации, возвращена зарегистрированная посылка",
+		slog.Int("parcel", parcel.Number), slog.Int("client", client), slog.String("idempotency_key", key))
+
+	return parcel, true, nil
+}
+
+// метод add типа ParcelService
+// сохраняет новую посылку и, если задан ключ идемпотентности, ключ в одной транзакции:
+// посылка без ключа не остается в хранилище, если ключ сохранить не удалось.
+// Если код отслеживания успела занять другая посылка (store.ErrTrackingCodeTaken),
+// посылке выдается новый код и добавление повторяется, всего не более maxTrackingAttempts раз
+// Параметры
+// ctx - контекст запроса
+// r - проверенный запрос на регистрацию; при повторе код в r.parcel заменяется новым
+func (s ParcelService) add(ctx context.Context, r *registration) (int, error) {
+	for attempt := 1; ; attempt++ {
+		var id int
+		err := s.store.WithTx(ctx, func(tx store.ParcelRepository) error {
+			var err error
+			if id, err = tx.Add(ctx, r.parcel); err != nil {
+				return err
+			}
+
+			return addIdempotencyKey(ctx, tx, *r, id)
+		})
+		if !stderrors.Is(err, store.ErrTrackingCodeTaken) || attempt == maxTrackingAttempts {
+			return id, err
+		}
+
+		s.logger.WarnContext(ctx, "код отслеживания занят, посылке выдается новый",
+			slog.String("tracking_code", r.parcel.TrackingCode), slog.Int("client", r.parcel.Client))
+		if r.parcel.TrackingCode, err = s.trackingCode(ctx, nil); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// функция addIdempotencyKey сохраняет ключ идемпотентности запроса r,
+// по которому зарегистрирована посылка с номером number. Запрос без ключа пропускается
+// Параметры
+// ctx - контекст запроса
+// tx - хранилище, работающее в транзакции
+// r - проверенный запрос на регистрацию
+// number - номер посылки
+func addIdempotencyKey(ctx context.Context, tx store.ParcelRepository, r registration, number int) error {
+	if r.key == "" {
+		return nil
+	}
+
+	return tx.AddIdempotencyKey(ctx, models.IdempotencyKey{
+		Client: r.parcel.Client, Key: r.key, Fingerprint: r.fp, Parcel: number, CreatedAt: r.parcel.CreatedAt,
+	})
+}
@@ -0,0 +1,101 @@
+package parcel_service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// в файле собрана проверка физических характеристик посылки при регистрации:
+// вес обязателен, габариты необязательны, но если указаны - то все три.
+// Наибольшие вес и габариты зависят от уровня обслуживания (см. Limits)
+
+// наибольшая длина описания вложения в символах
+const maxContentsLen = 256
+
+// определяем структурный тип Limits - ограничения на посылку одного уровня обслуживания
+type Limits struct {
+	MaxWeight  int // наибольший вес в граммах
+	MaxSide    int // наибольшая длина стороны в сантиметрах
+	MaxSideSum int // наибольшая сумма длины, ширины и высоты в сантиметрах
+}
+
+// функция DefaultLimits возвращает ограничения, которые по умолчанию использует сервис посылок:
+// стандартная доставка - до 31,5 кг и 150 см по стороне, срочная - до 20 кг и 100 см.
+// Каждый вызов возвращает новую таблицу, ее можно изменить и передать в WithLimits
+func DefaultLimits() map[string]Limits {
+	return map[string]Limits{
+		constants.ServiceLevelStandard: {MaxWeight: 31500, MaxSide: 150, MaxSideSum: 300},
+		constants.ServiceLevelExpress:  {MaxWeight: 20000, MaxSide: 100, MaxSideSum: 200},
+	}
+}
+
+// метод checkPhysical типа options проверяет уровень обслуживания, вес, габариты,
+// описание вложения и объявленную ценность посылки
+// и возвращает запрос с уровнем обслуживания по умолчанию и описанием без пробелов по краям
+// Параметры
+// req - запрос на регистрацию посылки
+func (o options) checkPhysical(req RegisterRequest) (RegisterRequest, error) {
+	if req.ServiceLevel == "" {
+		req.ServiceLevel = constants.ServiceLevelStandard
+	}
+	limits, ok := o.limits[req.ServiceLevel]
+	if !ok {
+		return req, errors.Validation("service_level", fmt.Sprintf("неизвестный уровень обслуживания %q", req.ServiceLevel))
+	}
+
+	if req.Weight <= 0 {
+		return req, errors.Validation("weight", "вес должен быть положительным")
+	}
+	if req.Weight > limits.MaxWeight {
+		return req, errors.Validation("weight",
+			fmt.Sprintf("вес больше %d г для уровня обслуживания %q", limits.MaxWeight, req.ServiceLevel))
+	}
+
+	if err := checkDimensions(req.Dimensions, limits, req.ServiceLevel); err != nil {
+		return req, err
+	}
+
+	req.Contents = strings.TrimSpace(req.Contents)
+	if utf8.RuneCountInString(req.Contents) > maxContentsLen {
+		return req, errors.Validation("contents", fmt.Sprintf("описание вложения длиннее %d символов", maxContentsLen))
+	}
+
+	if req.DeclaredValue < 0 {
+		return req, errors.Validation("declared_value", "объявленная ценность не может быть отрицательной")
+	}
+
+	return req, nil
+}
+
+// функция checkDimensions проверяет габариты посылки по ограничениям уровня обслуживания level.
+// Нулевые габариты означают, что размеры не указаны, и допустимы
+func checkDimensions(d models.Dimensions, limits Limits, level string) error {
+	if d == (models.Dimensions{}) {
+		return nil
+	}
+
+	sides := []int{d.Length, d.Width, d.Height}
+	sum := 0
+	for _, side := range sides {
+		if side <= 0 {
+			return errors.Validation("dimensions", "длина, ширина и высота должны быть положительными")
+		}
+		if side > limits.MaxSide {
+			return errors.Validation("dimensions",
+				fmt.Sprintf("сторона больше %d см для уровня обслуживания %q", limits.MaxSide, level))
+		}
+		sum += side
+	}
+
+	if sum > limits.MaxSideSum {
+		return errors.Validation("dimensions",
+			fmt.Sprintf("сумма сторон больше %d см для уровня обслуживания %q", limits.MaxSideSum, level))
+	}
+
+	return nil
+}
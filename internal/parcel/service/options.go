@@ -0,0 +1,109 @@
+package parcel_service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/address"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/clock"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/pricing"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/tracking"
+)
+
+// определяем структурный тип options - общие настройки сервисов пакета
+type options struct {
+	clock     clock.Clock        // источник текущего времени для отметок времени
+	logger    *slog.Logger       // журнал операций, изменивших данные
+	addresses address.Validator  // проверка адресов доставки
+	limits    map[string]Limits  // ограничения на посылку по уровням обслуживания
+	tariffs   pricing.Table      // тарифы, по которым рассчитывается стоимость доставки
+	tracking  tracking.Generator // генератор кодов отслеживания новых посылок
+}
+
+// Option настраивает ParcelService или ClientService при создании
+type Option func(*options)
+
+// функция WithClock задает источник текущего времени сервиса,
+// по умолчанию используются системные часы
+// Параметры
+// c - часы, например clocktest.Fake в тестах
+func WithClock(c clock.Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+// функция WithLogger задает журнал, в который сервис записывает операции,
+// изменившие данные: например, номер посылки, клиента, прежний и новый статус.
+// По умолчанию используется slog.Default()
+// Параметры
+// logger - журнал
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// функция WithAddressValidator задает проверку адресов доставки,
+// по умолчанию используется address.DefaultValidator()
+// Параметры
+// v - проверка, например address.DefaultValidator().With(address.Required(address.FieldPostalCode))
+func WithAddressValidator(v address.Validator) Option {
+	return func(o *options) { o.addresses = v }
+}
+
+// функция WithLimits задает ограничения на вес и габариты посылки по уровням обслуживания,
+// по умолчанию используется DefaultLimits(). Уровни, которых нет в limits, отклоняются при регистрации
+// Параметры
+// limits - ограничения по уровням обслуживания, например constants.ServiceLevelExpress
+func WithLimits(limits map[string]Limits) Option {
+	return func(o *options) { o.limits = limits }
+}
+
+// функция WithTariffs задает таблицу тарифов, по которой рассчитывается стоимость доставки,
+// по умолчанию используется pricing.DefaultTable()
+// Параметры
+// t - таблица тарифов, например загруженная pricing.LoadFile
+func WithTariffs(t pricing.Table) Option {
+	return func(o *options) { o.tariffs = t }
+}
+
+// функция WithTrackingGenerator задает генератор кодов отслеживания новых посылок,
+// по умолчанию коды со случайными номерами tracking.Random(tracking.DefaultService, tracking.DefaultCountry)
+// Параметры
+// g - генератор, например tracking.Sequence в тестах
+func WithTrackingGenerator(g tracking.Generator) Option {
+	return func(o *options) { o.tracking = g }
+}
+
+// функция newOptions применяет opts к настройкам по умолчанию
+func newOptions(opts []Option) options {
+	o := options{
+		clock:     clock.System(),
+		logger:    slog.Default(),
+		addresses: address.DefaultValidator(),
+		limits:    DefaultLimits(),
+		tariffs:   pricing.DefaultTable(),
+		tracking:  tracking.Random(tracking.DefaultService, tracking.DefaultCountry),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// метод normalizeAddress типа options приводит адрес к каноническому виду
+// и проверяет его, некорректный адрес отклоняется с ошибкой errors.ValidationError
+func (o options) normalizeAddress(a models.Address) (models.Address, error) {
+	a = address.Normalize(a)
+	if err := o.addresses.Validate(a); err != nil {
+		return a, err
+	}
+
+	return a, nil
+}
+
+// метод now типа options возвращает текущий момент часов сервиса в UTC
+// с точностью до миллисекунд: с такой точностью моменты времени хранятся в БД,
+// поэтому объект, возвращенный сервисом, совпадает с объектом, прочитанным из хранилища
+func (o options) now() time.Time {
+	return o.clock.Now().UTC().Truncate(time.Millisecond)
+}
@@ -1,126 +1,690 @@
 package parcel_service
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
 	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/pricing"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/status"
 	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/tracking"
 )
 
+// maxTrackingAttempts - число попыток выдать посылке свободный код отслеживания
+const maxTrackingAttempts = 5
+
+// DefaultRetention - срок хранения удаленных посылок по умолчанию, см. ParcelService.Purge
+const DefaultRetention = 30 * 24 * time.Hour
+
 // создаем структурный тип ParcelService
 type ParcelService struct {
-	store store.ParcelStore // поле store содержит структуру типа ParcelStore
+	options                         // часы и журнал сервиса
+	store    store.ParcelRepository // поле store содержит хранилище посылок (SQLite или память)
+	clients  store.ClientRepository // хранилище клиентов, которым принадлежат посылки
+	statuses status.Machine         // автомат статусов, определяющий допустимые переходы
 }
 
 // Функция NewParcelService возвращает новый экземпляр типа ParcelService
 // Параметры
-// store - экземпляр типа ParcelStore
-func NewParcelService(store store.ParcelStore) ParcelService {
-	return ParcelService{store: store}
+// store - хранилище посылок, реализующее интерфейс ParcelRepository
+// clients - хранилище клиентов, по которому Register проверяет владельца посылки
+// opts - необязательные настройки сервиса
+func NewParcelService(store store.ParcelRepository, clients store.ClientRepository, opts ...Option) ParcelService {
+	return ParcelService{options: newOptions(opts), store: store, clients: clients, statuses: status.Default()}
+}
+
+// определяем структурный тип RegisterRequest - данные для регистрации посылки
+type RegisterRequest struct {
+	Client        int             // идентификатор активного клиента, зарегистрировавшего посылку
+	Sender        models.Party    // отправитель, пустое имя заменяется именем клиента
+	Recipient     models.Party    // получатель, его адрес - адрес доставки
+	ReturnAddress *models.Address // адрес возврата, nil - возврат по адресу отправителя
+
+	ServiceLevel  string            // уровень обслуживания, пустой - constants.ServiceLevelStandard
+	Weight        int               // вес в граммах, обязателен
+	Dimensions    models.Dimensions // габариты в сантиметрах, нулевые - не указаны
+	Contents      string            // описание вложения
+	DeclaredValue int64             // объявленная ценность в копейках
+
+	// IdempotencyKey - необязательный ключ идемпотентности: повторный запрос клиента
+	// с тем же ключом и теми же данными возвращает посылку, зарегистрированную первым запросом
+	IdempotencyKey string
 }
 
 // Метод Register типа ParcelService
 // возвращает экземпляр типа Parcel и ошибку,
-// а также выводит в консоль сообщение о создании новой посылки
+// а также записывает в журнал сообщение о создании новой посылки.
+// Имя и адрес получателя обязательны, адрес отправителя и адрес возврата проверяются, если указаны.
+// Вес и габариты ограничены по уровню обслуживания (см. WithLimits).
+// Стоимость доставки рассчитывается по тарифам (см. WithTariffs) и сохраняется в посылке:
+// последующие изменения тарифов ее не меняют.
+// Посылке выдается уникальный код отслеживания (см. WithTrackingGenerator).
+// Запрос с ключом идемпотентности, который клиент уже использовал, не создает новую посылку:
+// при совпадении данных возвращается посылка, зарегистрированная по ключу (в текущем состоянии),
+// при расхождении - ошибка errors.ValidationError.
+// Адрес, не прошедший проверку сервиса (см. WithAddressValidator), некорректный телефон,
+// вес или габариты, а также неизвестный или деактивированный клиент
+// отклоняются с ошибкой errors.ValidationError
 // Параметры
-// client - идентификатор клиента, целое число
-// address - адрес посылки, строка
-func (s ParcelService) Register(client int, address string) (models.Parcel, error) {
-	// создаем новый экземпляр типа Parcel
-	parcel := models.Parcel{
-		Client:    client,                                // значение поля Client устанавливаем равным параметру client
-		Status:    constants.ParcelStatusRegistered,      // для всех новых посылок устанавливаем статус "посылка зарегистрирована"
-		Address:   address,                               // значение поля Address устанавливаем равным параметру address
-		CreatedAt: time.Now().UTC().Format(time.RFC3339), // для заполнения поля CreatedAt получаем актуальное время
+// ctx - контекст запроса, передается в хранилище
+// req - клиент, отправитель, получатель, адрес возврата и характеристики посылки;
+// адреса сохраняются в каноническом виде (см. address.Normalize)
+func (s ParcelService) Register(ctx context.Context, req RegisterRequest) (models.Parcel, error) {
+	r, err := s.prepare(ctx, req, nil)
+	if err != nil || r.replayed {
+		return r.parcel, err
 	}
 
 	// получаем id новой посылки после добавления ее в базу данных
-	id, err := s.store.Add(parcel)
+	id, err := s.add(ctx, &r)
 	if err != nil {
-		return parcel, err // в случае, если ошибка не равна nil, возвращаем экземпляр посылки и ошибку
+		// параллельный запрос с тем же ключом мог зарегистрировать посылку первым
+		if r.key != "" {
+			if replayed, found, replayErr := s.replay(ctx, r.parcel.Client, r.key, r.fp); found {
+				return replayed, replayErr
+			}
+		}
+		return r.parcel, err // в случае, если ошибка не равна nil, возвращаем экземпляр посылки и ошибку
 	}
 
 	//  заполняем поле Number у посылки parcel значением переменной id
+	parcel := r.parcel
 	parcel.Number = id
 
-	fmt.Printf("Новая посылка № %d на адрес %s от клиента с идентификатором %d зарегистрирована %s\n",
-		parcel.Number, parcel.Address, parcel.Client, parcel.CreatedAt)
+	s.logRegistered(ctx, parcel)
 
 	return parcel, nil
 }
 
-// Метод PrintClientParcels типа ParcelService
-// выводит в консоль все посылки интересующего клиента
-// возвращает ошибку (по умолчанию - nil)
+// определяем структурный тип registration - проверенный запрос на регистрацию посылки
+type registration struct {
+	parcel   models.Parcel // новая посылка без номера или, при повторе, посылка, зарегистрированная по ключу
+	key      string        // ключ идемпотентности или пустая строка
+	fp       string        // отпечаток данных запроса, см. fingerprint
+	replayed bool          // запрос повторяет уже выполненный, новую посылку сохранять не нужно
+}
+
+// метод prepare типа ParcelService
+// проверяет запрос на регистрацию и строит по нему новую посылку, см. Register.
+// Ошибки проверки возвращаются как errors.ValidationError, остальные - ошибки хранилища
 // Параметры
-// client - идентификатор интересующего клиента (целое число)
-func (s ParcelService) PrintClientParcels(client int) error {
-	// получаем все посылки интересующего клиента
-	parcels, err := s.store.GetByClient(client)
+// ctx - контекст запроса
+// req - запрос на регистрацию
+// taken - коды отслеживания, уже выданные в текущей пакетной регистрации, или nil
+func (s ParcelService) prepare(ctx context.Context, req RegisterRequest, taken map[string]bool) (registration, error) {
+	// проверяем входные данные до обращения к хранилищу
+	if req.Client <= 0 {
+		return registration{}, errors.Validation("client", "идентификатор клиента должен быть положительным")
+	}
+	req, err := s.checkPhysical(req)
 	if err != nil {
-		return err
+		return registration{}, err
+	}
+	recipient, err := s.normalizeParty(fieldRecipient, req.Recipient, true)
+	if err != nil {
+		return registration{}, err
+	}
+	sender, err := s.normalizeParty(fieldSender, req.Sender, false)
+	if err != nil {
+		return registration{}, err
+	}
+	var returnAddress *models.Address
+	if req.ReturnAddress != nil && *req.ReturnAddress != (models.Address{}) {
+		a, err := s.normalizeAddress(*req.ReturnAddress)
+		if err != nil {
+			return registration{}, prefixField(fieldReturnAddress, err)
+		}
+		returnAddress = &a
+	}
+	key, err := checkIdempotencyKey(req.IdempotencyKey)
+	if err != nil {
+		return registration{}, err
+	}
+	req.Sender, req.Recipient, req.ReturnAddress = sender, recipient, returnAddress
+	fp, err := fingerprint(req)
+	if err != nil {
+		return registration{}, err
+	}
+	// повтор возвращает посылку до проверки клиента и расчета стоимости:
+	// клиента могли деактивировать, а тарифы - изменить после первого запроса
+	if key != "" {
+		if parcel, found, err := s.replay(ctx, req.Client, key, fp); found || err != nil {
+			return registration{parcel: parcel, replayed: found}, err
+		}
+	}
+	quote, err := s.tariffs.Quote(models.Parcel{
+		ServiceLevel: req.ServiceLevel, Weight: req.Weight, Dimensions: req.Dimensions,
+		Sender: sender, Recipient: recipient,
+	})
+	if err != nil {
+		return registration{}, err
+	}
+	client, err := s.checkClient(ctx, req.Client)
+	if err != nil {
+		return registration{}, err
+	}
+	if sender.Name == "" {
+		sender.Name = client.Name
+	}
+	code, err := s.trackingCode(ctx, taken)
+	if err != nil {
+		return registration{}, err
 	}
 
-	// выводим посылки интересующего клиента в консоль
-	fmt.Printf("Посылки клиента %d:\n", client)
-	for _, parcel := range parcels {
-		fmt.Printf("Посылка № %d на адрес %s от клиента с идентификатором %d зарегистрирована %s, статус %s\n",
-			parcel.Number, parcel.Address, parcel.Client, parcel.CreatedAt, parcel.Status)
+	// создаем новый экземпляр типа Parcel
+	createdAt := s.now()
+	parcel := models.Parcel{
+		Client:        req.Client,                       // значение поля Client устанавливаем равным идентификатору клиента
+		Status:        constants.ParcelStatusRegistered, // для всех новых посылок устанавливаем статус "посылка зарегистрирована"
+		TrackingCode:  code,                             // публичный код отслеживания
+		Sender:        sender,                           // отправитель, по умолчанию от имени клиента
+		Recipient:     recipient,                        // получатель и адрес доставки
+		ReturnAddress: returnAddress,                    // адрес возврата, если он отличается от адреса отправителя
+		ServiceLevel:  req.ServiceLevel,                 // уровень обслуживания, по умолчанию стандартный
+		Weight:        req.Weight,                       // вес проверен по ограничениям уровня обслуживания
+		Dimensions:    req.Dimensions,                   // габариты, как и вес, проверены по уровню обслуживания
+		Contents:      req.Contents,                     // описание вложения
+		DeclaredValue: req.DeclaredValue,                // объявленная ценность
+		Cost:          quote.Price,                      // стоимость по действующим тарифам
+		CreatedAt:     createdAt,                        // для заполнения поля CreatedAt получаем актуальное время
+		UpdatedAt:     createdAt,                        // новая посылка еще не изменялась
 	}
-	fmt.Println()
 
-	return nil
+	return registration{parcel: parcel, key: key, fp: fp}, nil
+}
+
+// метод logRegistered типа ParcelService записывает в журнал регистрацию посылки
+func (s ParcelService) logRegistered(ctx context.Context, parcel models.Parcel) {
+	s.logger.InfoContext(ctx, "посылка зарегистрирована",
+		slog.Int("parcel", parcel.Number), slog.String("tracking_code", parcel.TrackingCode),
+		slog.Int("client", parcel.Client))
+}
+
+// метод trackingCode типа ParcelService
+// возвращает код отслеживания для новой посылки, которого еще нет в хранилище.
+// Совпадение случайных кодов маловероятно, поэтому занятый код просто заменяется следующим.
+// Проверка не защищает от параллельной регистрации, получившей тот же код, и от совпадения
+// с кодом удаленной посылки, которую не видит GetByTrackingCode: такую посылку отклоняет
+// уникальный индекс, и add и addBatch повторяют добавление с новым кодом
+// Параметры
+// ctx - контекст запроса
+// taken - коды, уже выданные в текущей пакетной регистрации, но еще не сохраненные, или nil;
+// выданный код добавляется в taken
+func (s ParcelService) trackingCode(ctx context.Context, taken map[string]bool) (string, error) {
+	for i := 0; i < maxTrackingAttempts; i++ {
+		code, err := s.tracking.Next()
+		if err != nil {
+			return "", err
+		}
+		if taken[code] {
+			continue
+		}
+
+		_, err = s.store.GetByTrackingCode(ctx, code)
+		if stderrors.Is(err, errors.ErrNotFound) {
+			if taken != nil {
+				taken[code] = true
+			}
+			return code, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("не удалось выдать свободный код отслеживания за %d попыток", maxTrackingAttempts)
+}
+
+// Метод Quote типа ParcelService
+// рассчитывает стоимость доставки посылки до ее регистрации.
+// Используются только уровень обслуживания, вес, габариты и адреса отправителя и получателя:
+// они проверяются так же, как в Register, остальные поля запроса не проверяются
+// и клиент может быть не указан
+// Параметры
+// ctx - контекст запроса
+// req - запрос на регистрацию посылки
+func (s ParcelService) Quote(ctx context.Context, req RegisterRequest) (pricing.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return pricing.Quote{}, err
+	}
+
+	req, err := s.checkPhysical(req)
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+	to, err := s.normalizeAddress(req.Recipient.Address)
+	if err != nil {
+		return pricing.Quote{}, prefixField(fieldRecipient, err)
+	}
+	var from models.Address
+	if req.Sender.Address != (models.Address{}) {
+		if from, err = s.normalizeAddress(req.Sender.Address); err != nil {
+			return pricing.Quote{}, prefixField(fieldSender, err)
+		}
+	}
+
+	return s.tariffs.Quote(models.Parcel{
+		ServiceLevel: req.ServiceLevel, Weight: req.Weight, Dimensions: req.Dimensions,
+		Sender: models.Party{Address: from}, Recipient: models.Party{Address: to},
+	})
+}
+
+// Метод Get типа ParcelService
+// возвращает посылку по номеру или ошибку errors.NotFoundError
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// number - номер посылки
+func (s ParcelService) Get(ctx context.Context, number int) (models.Parcel, error) {
+	return s.store.Get(ctx, number)
+}
+
+// Метод GetByTrackingCode типа ParcelService
+// возвращает посылку по коду отслеживания. Код приводится к каноническому виду
+// (см. tracking.Normalize) и проверяется до обращения к хранилищу: код с неверным форматом
+// или контрольной цифрой отклоняется с ошибкой errors.ValidationError,
+// а для неизвестного кода возвращается errors.NotFoundError
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// code - код отслеживания, например RR123456785RU
+func (s ParcelService) GetByTrackingCode(ctx context.Context, code string) (models.Parcel, error) {
+	code = tracking.Normalize(code)
+	if err := tracking.Validate(code); err != nil {
+		return models.Parcel{}, err
+	}
+
+	return s.store.GetByTrackingCode(ctx, code)
+}
+
+// Метод Owner типа ParcelService
+// возвращает клиента, которому принадлежит посылка,
+// или ошибку errors.NotFoundError для несуществующей посылки
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// number - номер посылки
+func (s ParcelService) Owner(ctx context.Context, number int) (models.Client, error) {
+	parcel, err := s.store.Get(ctx, number)
+	if err != nil {
+		return models.Client{}, err
+	}
+
+	return s.clients.Get(ctx, parcel.Client)
+}
+
+// Метод ClientParcels типа ParcelService
+// возвращает все посылки интересующего клиента
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// client - идентификатор клиента
+func (s ParcelService) ClientParcels(ctx context.Context, client int) ([]models.Parcel, error) {
+	return s.store.GetByClient(ctx, client)
+}
+
+// Метод List типа ParcelService
+// возвращает страницу посылок, удовлетворяющих условиям f:
+// по клиенту, набору статусов, периоду создания, подстроке адреса и телефону получателя.
+// Следующая страница запрашивается с курсором из ParcelPage.NextCursor.
+// Удаленные посылки включаются в выборку, только если задано f.IncludeDeleted.
+// Неизвестные статусы и некорректные условия отклоняются с ошибкой errors.ValidationError
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// f - условия выборки, сортировка и курсор страницы
+func (s ParcelService) List(ctx context.Context, f models.ParcelFilter) (models.ParcelPage, error) {
+	f, err := s.checkFilter(f)
+	if err != nil {
+		return models.ParcelPage{}, err
+	}
+
+	return s.store.List(ctx, f)
+}
+
+// метод checkFilter типа ParcelService проверяет условия выборки посылок
+// и возвращает их с телефоном получателя в том виде, в котором он хранится
+func (s ParcelService) checkFilter(f models.ParcelFilter) (models.ParcelFilter, error) {
+	if f.Client < 0 {
+		return f, errors.Validation("client", "идентификатор клиента должен быть положительным")
+	}
+
+	for _, st := range f.Statuses {
+		if !s.statuses.Known(st) {
+			return f, errors.Validation("status", fmt.Sprintf("неизвестный статус %q", st))
+		}
+	}
+
+	phone, err := normalizePhone(f.RecipientPhone)
+	if err != nil {
+		return f, prefixField("recipient_phone", err)
+	}
+	f.RecipientPhone = phone
+
+	return f, nil
+}
+
+// Метод Iterate типа ParcelService
+// передает в fn все посылки, удовлетворяющие условиям f, в порядке f.OrderBy,
+// не загружая выборку в память целиком (см. store.ParcelRepository.Iterate).
+// Условия проверяются как в List. Используется для выгрузки посылок
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// f - условия выборки и сортировка, размер страницы и курсор не используются
+// fn - функция, которая получает посылки; ошибка fn прекращает обход и возвращается
+func (s ParcelService) Iterate(ctx context.Context, f models.ParcelFilter, fn func(models.Parcel) error) error {
+	f, err := s.checkFilter(f)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Iterate(ctx, f, fn)
 }
 
 // Метод NextStatus типа ParcelService
-// устанавливает для посылки с заданным номером следующий по порядку статус,
-// выводит в консоль сообщение об обновлении статуса посылки,
-// возвращает ошибку (по умолчанию nil)
+// устанавливает для посылки с заданным номером следующий статус основного маршрута
+// (registered -> sent -> in_transit -> out_for_delivery -> delivered)
+// и возвращает посылку в новом статусе или ошибку: errors.NotFoundError для несуществующей посылки
+// и errors.StateError, если посылка доставлена или сошла с основного маршрута
 // Параметры
+// ctx - контекст запроса, передается в хранилище
 // number - номер интересующей посылки
-func (s ParcelService) NextStatus(number int) error {
-	// получаем посылку из БД
-	parcel, err := s.store.Get(number)
+// opts - сведения для записи в историю: исполнитель, место, комментарий
+func (s ParcelService) NextStatus(ctx context.Context, number int, opts ...EventOption) (models.Parcel, error) {
+	var (
+		updated models.Parcel
+		ev      models.StatusEvent
+	)
+	err := s.store.WithTx(ctx, func(tx store.ParcelRepository) error {
+		// получаем посылку из БД
+		parcel, err := tx.Get(ctx, number)
+		if err != nil {
+			return err
+		}
+
+		// определяем следующий статус по автомату статусов
+		next, ok := s.statuses.Next(parcel.Status)
+		if !ok {
+			return errors.InvalidState(number, parcel.Status, "переход к следующему статусу")
+		}
+
+		updated, ev, err = s.changeStatus(ctx, tx, parcel, next, opts)
+		return err
+	})
 	if err != nil {
+		return models.Parcel{}, err
+	}
+
+	s.logStatusChange(ctx, updated, ev)
+
+	return updated, nil
+}
+
+// Метод Transition типа ParcelService
+// переводит посылку в статус to, если автомат статусов допускает такой переход,
+// например отменяет зарегистрированную посылку или отмечает отправленную как утерянную,
+// и возвращает посылку в новом статусе.
+// Посылку без адреса отправителя и адреса возврата нельзя вернуть отправителю
+// Возвращает errors.ValidationError для неизвестного статуса,
+// errors.NotFoundError для несуществующей посылки
+// и errors.StateError со списком допустимых статусов для недопустимого перехода
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// number - номер посылки
+// to - новый статус
+// opts - сведения для записи в историю: исполнитель, место, комментарий
+func (s ParcelService) Transition(ctx context.Context, number int, to string, opts ...EventOption) (models.Parcel, error) {
+	if !s.statuses.Known(to) {
+		return models.Parcel{}, errors.Validation("status", fmt.Sprintf("неизвестный статус %q", to))
+	}
+
+	var (
+		updated models.Parcel
+		ev      models.StatusEvent
+	)
+	err := s.store.WithTx(ctx, func(tx store.ParcelRepository) error {
+		parcel, err := tx.Get(ctx, number)
+		if err != nil {
+			return err
+		}
+
+		if err := s.checkTransition(parcel, to); err != nil {
+			return err
+		}
+
+		updated, ev, err = s.changeStatus(ctx, tx, parcel, to, opts)
 		return err
+	})
+	if err != nil {
+		return models.Parcel{}, err
 	}
 
-	// создаем локальную переменную nextStatus, куда мы будем сохранять следующий статус посылки
-	var nextStatus string
+	s.logStatusChange(ctx, updated, ev)
+
+	return updated, nil
+}
 
-	switch parcel.Status {
-	case constants.ParcelStatusRegistered:
-		nextStatus = constants.ParcelStatusSent
-	case constants.ParcelStatusSent:
-		nextStatus = constants.ParcelStatusDelivered
-	case constants.ParcelStatusDelivered: // если у посылки уже статус "Доставлена" - завершаем выполнение функции и возвращаем nil
-		return nil
+// метод checkTransition типа ParcelService
+// проверяет, что посылку можно перевести в статус to: переход допустим по маршруту статусов,
+// а для возврата отправителю известен адрес возврата.
+// Возвращает errors.StateError, если перевести посылку нельзя
+// Параметры
+// parcel - посылка в текущем состоянии
+// to - новый статус
+func (s ParcelService) checkTransition(parcel models.Parcel, to string) error {
+	if !s.statuses.CanTransition(parcel.Status, to) {
+		return errors.IllegalTransition(parcel.Number, parcel.Status, to, s.statuses.Allowed(parcel.Status))
+	}
+	if to == constants.ParcelStatusReturned && parcel.ReturnTo() == (models.Address{}) {
+		return errors.InvalidState(parcel.Number, parcel.Status, "возврат отправителю без адреса возврата")
 	}
 
-	// выводим сообщение об обновлении статуса посылки
-	fmt.Printf("У посылки № %d новый статус: %s\n", number, nextStatus)
+	return nil
+}
+
+// метод changeStatus типа ParcelService
+// переводит посылку в статус to и записывает событие в историю в транзакции tx,
+// возвращает посылку после изменения и записанное событие.
+// Статус меняется, только если он не изменился с момента чтения посылки:
+// из двух параллельных запросов, прочитавших один и тот же статус,
+// второй получит errors.StateError, и его событие не будет записано
+// Параметры
+// ctx - контекст запроса
+// tx - хранилище, работающее в транзакции
+// parcel - посылка в прочитанном состоянии
+// to - новый статус
+// opts - сведения для записи в историю
+func (s ParcelService) changeStatus(ctx context.Context, tx store.ParcelRepository, parcel models.Parcel, to string,
+	opts []EventOption) (models.Parcel, models.StatusEvent, error) {
+	// время изменения посылки совпадает со временем события в истории
+	ev := newEvent(parcel, to, s.now(), opts)
+	if err := tx.CompareAndSetStatus(ctx, parcel.Number, parcel.Status, to, ev.At); err != nil {
+		return models.Parcel{}, ev, err
+	}
 
-	// возвращаем результат вызова метода s.store.SetStatus, при помощи которого обновляем статус заказа
-	return s.store.SetStatus(number, nextStatus)
+	if err := tx.AddEvent(ctx, ev); err != nil {
+		return models.Parcel{}, ev, err
+	}
+
+	// время отправки и доставки заполняет хранилище, поэтому посылку перечитываем
+	updated, err := tx.Get(ctx, parcel.Number)
+
+	return updated, ev, err
+}
+
+// метод logStatusChange типа ParcelService записывает в журнал смену статуса посылки
+func (s ParcelService) logStatusChange(ctx context.Context, parcel models.Parcel, ev models.StatusEvent) {
+	s.logger.InfoContext(ctx, "статус посылки изменен",
+		slog.Int("parcel", parcel.Number), slog.Int("client", parcel.Client),
+		slog.String("from", ev.From), slog.String("to", ev.To), slog.String("actor", ev.Actor))
+}
+
+// Метод History типа ParcelService
+// возвращает историю смены статусов посылки в хронологическом порядке,
+// чтобы ответить, когда посылка была отправлена или доставлена
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// number - номер посылки
+func (s ParcelService) History(ctx context.Context, number int) ([]models.StatusEvent, error) {
+	// убеждаемся, что посылка существует, чтобы не путать ее отсутствие с пустой историей
+	if _, err := s.store.Get(ctx, number); err != nil {
+		return nil, err
+	}
+
+	return s.store.Events(ctx, number)
 }
 
 // Метод ChangeAddress типа ParcelService
 // изменяет адрес доставки посылки,
-// возвращает ошибку
+// возвращает ошибку: errors.ValidationError для адреса, не прошедшего проверку,
+// errors.NotFoundError и errors.StateError - как у хранилища
 // Параметры
+// ctx - контекст запроса, передается в хранилище
 // number - номер посылки, у которой необходимо изменить адрес
-// address - новый адрес
-func (s ParcelService) ChangeAddress(number int, address string) error {
-	return s.store.SetAddress(number, address) // вызываем метод s.store.SetAddress для установки нового адреса
+// address - новый адрес, сохраняется в каноническом виде
+func (s ParcelService) ChangeAddress(ctx context.Context, number int, address models.Address) error {
+	address, err := s.normalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	// вызываем метод s.store.SetAddress для установки нового адреса
+	if err := s.store.SetAddress(ctx, number, address, s.now()); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "адрес посылки изменен", slog.Int("parcel", number))
+
+	return nil
 }
 
 // Метод Delete типа ParcelService
-// удаляет посылку с заданным номером
-// возвращает ошибку
+// удаляет посылку с заданным номером. Посылка перестает быть видна в выборках
+// и при поиске, но остается в хранилище вместе с историей: ее можно восстановить (Restore),
+// пока она не удалена окончательно (Purge)
+// возвращает ошибку: errors.NotFoundError или errors.StateError, если посылку удалить нельзя
 // Параметры
+// ctx - контекст запроса, передается в хранилище
 // number - номер посылки, которую необходимо удалить
-func (s ParcelService) Delete(number int) error {
-	return s.store.Delete(number)
+func (s ParcelService) Delete(ctx context.Context, number int) error {
+	if err := s.store.Delete(ctx, number, s.now()); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "посылка удалена", slog.Int("parcel", number))
+
+	return nil
+}
+
+// Метод Restore типа ParcelService
+// восстанавливает удаленную посылку и возвращает ее,
+// возвращает ошибку: errors.NotFoundError, если посылки нет или она удалена окончательно,
+// и errors.StateError, если посылка не удалена
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// number - номер удаленной посылки
+func (s ParcelService) Restore(ctx context.Context, number int) (models.Parcel, error) {
+	if err := s.store.Restore(ctx, number, s.now()); err != nil {
+		return models.Parcel{}, err
+	}
+
+	s.logger.InfoContext(ctx, "посылка восстановлена", slog.Int("parcel", number))
+
+	return s.store.Get(ctx, number)
+}
+
+// Метод Purge типа ParcelService
+// окончательно удаляет посылки, удаленные раньше, чем retention назад,
+// вместе с историей и ключами идемпотентности и возвращает их число.
+// Восстановить такие посылки уже нельзя
+// Параметры
+// ctx - контекст запроса, передается в хранилище
+// retention - срок хранения удаленных посылок, например DefaultRetention;
+// при нулевом сроке удаляются все удаленные посылки
+func (s ParcelService) Purge(ctx context.Context, retention time.Duration) (int, error) {
+	if retention < 0 {
+		return 0, errors.Validation("retention", "срок хранения не может быть отрицательным")
+	}
+
+	before := s.now().Add(-retention)
+	n, err := s.store.Purge(ctx, before)
+	if err != nil {
+		return n, err
+	}
+
+	s.logger.InfoContext(ctx, "удаленные посылки удалены окончательно",
+		slog.Int("count", n), slog.Time("deleted_before", before))
+
+	return n, nil
+}
+
+// метод checkClient типа ParcelService проверяет, что клиент существует и активен,
+// и возвращает его: посылки неизвестных и деактивированных клиентов не регистрируются
+func (s ParcelService) checkClient(ctx context.Context, id int) (models.Client, error) {
+	c, err := s.clients.Get(ctx, id)
+	if stderrors.Is(err, errors.ErrNotFound) {
+		return c, errors.Validation("client", fmt.Sprintf("клиент %d не найден", id))
+	}
+	if err != nil {
+		return c, err
+	}
+
+	if !c.Active {
+		return c, errors.Validation("client", fmt.Sprintf("клиент %d деактивирован", id))
+	}
+
+	return c, nil
+}
+
+// названия сторон посылки в ошибках проверки
+const (
+	fieldSender        = "sender"
+	fieldRecipient     = "recipient"
+	fieldReturnAddress = "return_address"
+)
+
+// метод normalizeParty типа ParcelService проверяет сторону посылки
+// и приводит ее к виду, в котором она хранится. Поля в ошибках проверки
+// получают префикс стороны, например "recipient.address.city"
+// Параметры
+// field - название стороны, fieldSender или fieldRecipient
+// p - сторона
+// required - обязательны ли имя и адрес; у отправителя они необязательны
+func (s ParcelService) normalizeParty(field string, p models.Party, required bool) (models.Party, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	if required && p.Name == "" {
+		return p, errors.Validation(field+".name", "имя не может быть пустым")
+	}
+	if utf8.RuneCountInString(p.Name) > maxClientNameLen {
+		return p, errors.Validation(field+".name", fmt.Sprintf("имя длиннее %d символов", maxClientNameLen))
+	}
+
+	phone, err := normalizePhone(p.Phone)
+	if err != nil {
+		return p, prefixField(field, err)
+	}
+	p.Phone = phone
+
+	if !required && p.Address == (models.Address{}) {
+		return p, nil
+	}
+	if p.Address, err = s.normalizeAddress(p.Address); err != nil {
+		return p, prefixField(field, err)
+	}
+
+	return p, nil
+}
+
+// функция prefixField добавляет к полю ошибки проверки префикс prefix,
+// остальные ошибки возвращает без изменений
+func prefixField(prefix string, err error) error {
+	var validationErr *errors.ValidationError
+	if !stderrors.As(err, &validationErr) {
+		return err
+	}
+
+	return errors.Validation(prefix+"."+validationErr.Field, validationErr.Reason)
 }
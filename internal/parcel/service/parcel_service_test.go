@@ -0,0 +1,1257 @@
+package parcel_service
+
+import (
+	// импортируем пакеты standard library
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/address"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/clock/clocktest"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/pricing"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store/storetest"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/tracking"
+)
+
+// адреса доставки посылок в тестах
+var (
+	testAddress    = models.Address{City: "Псков", Street: "ул. Лесная", House: "1"}
+	newTestAddress = models.Address{City: "Псков", Street: "ул. Новая", House: "7"}
+)
+
+// функция testRequest возвращает запрос на регистрацию посылки клиента client весом 1 кг
+// для получателя "Получатель" по адресу a
+func testRequest(client int, a models.Address) RegisterRequest {
+	return RegisterRequest{Client: client, Recipient: models.Party{Name: "Получатель", Address: a}, Weight: 1000}
+}
+
+// newTestService возвращает сервис, работающий с хранилищем в памяти,
+// в котором уже есть активный клиент с идентификатором 1.
+// Часы сервиса сдвигаются на секунду при каждом обращении,
+// поэтому отметки времени в тестах предсказуемы и различаются
+func newTestService(t *testing.T) (ParcelService, *store.MemoryStore) {
+	repo := newTestStore(t)
+	return NewParcelService(repo, repo.Clients(), WithClock(clocktest.NewTicking(clocktest.Epoch, time.Second))), repo
+}
+
+// newTestStore возвращает хранилище в памяти с активным клиентом 1
+func newTestStore(t *testing.T) *store.MemoryStore {
+	repo := store.NewMemoryStore()
+	client := storetest.Client().Add(t, repo.Clients())
+	require.Equal(t, 1, client.ID)
+
+	return repo
+}
+
+// TestRegister проверяет регистрацию новой посылки
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	parcel, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	require.NotEmpty(t, parcel.Number)
+	assert.Equal(t, constants.ParcelStatusRegistered, parcel.Status)
+
+	// посылка сохранена в хранилище
+	storedParcel, err := repo.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	assert.Equal(t, parcel, storedParcel)
+}
+
+// TestNextStatus проверяет движение посылки по основному маршруту
+// `зарегистрирована` -> ... -> `доставлена`
+func TestNextStatus(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	parcel, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+
+	expected := []string{
+		constants.ParcelStatusSent,
+		constants.ParcelStatusInTransit,
+		constants.ParcelStatusOutForDelivery,
+		constants.ParcelStatusDelivered,
+	}
+	for _, status := range expected {
+		_, err = service.NextStatus(ctx, parcel.Number)
+		require.NoError(t, err)
+
+		storedParcel, err := repo.Get(ctx, parcel.Number)
+		require.NoError(t, err)
+		assert.Equal(t, status, storedParcel.Status)
+	}
+
+	// время отправки и доставки совпадает со временем событий в истории
+	storedParcel, err := repo.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	events, err := service.History(ctx, parcel.Number)
+	require.NoError(t, err)
+	require.Len(t, events, len(expected))
+	require.NotNil(t, storedParcel.SentAt)
+	require.NotNil(t, storedParcel.DeliveredAt)
+	assert.Equal(t, events[0].At, *storedParcel.SentAt)
+	assert.Equal(t, events[3].At, *storedParcel.DeliveredAt)
+	assert.Equal(t, events[3].At, storedParcel.UpdatedAt)
+	assert.False(t, storedParcel.DeliveredAt.Before(storedParcel.CreatedAt))
+
+	// у доставленной посылки следующего статуса нет
+	_, err = service.NextStatus(ctx, parcel.Number)
+	require.ErrorIs(t, err, errors.ErrInvalidState)
+
+	// для несуществующей посылки возвращается ошибка
+	_, err = service.NextStatus(ctx, parcel.Number+1)
+	require.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// TestTimestamps проверяет отметки времени посылки и событий по часам сервиса
+func TestTimestamps(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.NewFake(clocktest.Epoch)
+	repo := newTestStore(t)
+	service := NewParcelService(repo, repo.Clients(), WithClock(clock))
+
+	parcel, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	assert.Equal(t, clocktest.Epoch, parcel.CreatedAt)
+	assert.Equal(t, clocktest.Epoch, parcel.UpdatedAt)
+	assert.Nil(t, parcel.SentAt)
+	assert.Nil(t, parcel.DeliveredAt)
+
+	// изменение адреса через 10 минут
+	clock.Advance(10 * time.Minute)
+	require.NoError(t, service.ChangeAddress(ctx, parcel.Number, newTestAddress))
+	parcel, err = service.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	assert.Equal(t, clocktest.Epoch, parcel.CreatedAt)
+	assert.Equal(t, clocktest.Epoch.Add(10*time.Minute), parcel.UpdatedAt)
+
+	// отправка через 2 часа после регистрации, доставка - через сутки после отправки
+	sentAt := clocktest.Epoch.Add(2 * time.Hour)
+	clock.Set(sentAt)
+	_, err = service.NextStatus(ctx, parcel.Number)
+	require.NoError(t, err)
+	clock.Advance(24 * time.Hour)
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusLost)
+	require.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusInTransit)
+	require.NoError(t, err)
+	_, err = service.NextStatus(ctx, parcel.Number)
+	require.NoError(t, err)
+	_, err = service.NextStatus(ctx, parcel.Number)
+	require.NoError(t, err)
+	deliveredAt := sentAt.Add(25 * time.Hour)
+
+	parcel, err = service.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	require.NotNil(t, parcel.SentAt)
+	require.NotNil(t, parcel.DeliveredAt)
+	assert.Equal(t, sentAt, *parcel.SentAt)
+	assert.Equal(t, deliveredAt, *parcel.DeliveredAt)
+	assert.Equal(t, deliveredAt, parcel.UpdatedAt)
+	assert.Equal(t, 25*time.Hour, parcel.DeliveredAt.Sub(*parcel.SentAt))
+
+	events, err := service.History(ctx, parcel.Number)
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	assert.Equal(t, sentAt, events[0].At)
+	assert.Equal(t, sentAt.Add(24*time.Hour), events[1].At)
+	assert.Equal(t, deliveredAt, events[4].At)
+}
+
+// TestTransition проверяет переходы в статусы вне основного маршрута
+func TestTransition(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	parcel, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+
+	// зарегистрированную посылку нельзя сразу доставить
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusDelivered)
+	var stateErr *errors.StateError
+	require.ErrorAs(t, err, &stateErr)
+	assert.Equal(t, constants.ParcelStatusRegistered, stateErr.Status)
+	assert.Equal(t, constants.ParcelStatusDelivered, stateErr.To)
+	assert.ElementsMatch(t, []string{constants.ParcelStatusSent, constants.ParcelStatusCancelled}, stateErr.Allowed)
+
+	// но можно отменить
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusCancelled)
+	require.NoError(t, err)
+	storedParcel, err := repo.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusCancelled, storedParcel.Status)
+
+	// из конечного статуса перейти никуда нельзя
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusSent)
+	assert.ErrorIs(t, err, errors.ErrInvalidState)
+	_, err = service.NextStatus(ctx, parcel.Number)
+	assert.ErrorIs(t, err, errors.ErrInvalidState)
+
+	// отправленная посылка может потеряться и найтись
+	parcel, err = service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	_, err = service.NextStatus(ctx, parcel.Number)
+	require.NoError(t, err)
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusLost)
+	require.NoError(t, err)
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusInTransit)
+	require.NoError(t, err)
+
+	// неизвестный статус и несуществующая посылка
+	_, err = service.Transition(ctx, parcel.Number, "unknown")
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	_, err = service.Transition(ctx, parcel.Number+1, constants.ParcelStatusSent)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// TestChangeAddressAndDelete проверяет, что адрес можно изменить
+// и посылку можно удалить только в статусе `зарегистрирована`
+func TestChangeAddressAndDelete(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	registered, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	sent, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	_, err = service.NextStatus(ctx, sent.Number)
+	require.NoError(t, err)
+
+	// зарегистрированная посылка
+	require.NoError(t, service.ChangeAddress(ctx, registered.Number, newTestAddress))
+	storedParcel, err := repo.Get(ctx, registered.Number)
+	require.NoError(t, err)
+	assert.Equal(t, newTestAddress, storedParcel.Recipient.Address)
+	require.NoError(t, service.Delete(ctx, registered.Number))
+
+	// отправленная посылка
+	assert.ErrorIs(t, service.ChangeAddress(ctx, sent.Number, newTestAddress), errors.ErrInvalidState)
+	assert.ErrorIs(t, service.Delete(ctx, sent.Number), errors.ErrInvalidState)
+
+	parcels, err := repo.GetByClient(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, parcels, 1)
+	assert.Equal(t, sent.Number, parcels[0].Number)
+}
+
+// TestRestoreAndPurge проверяет восстановление удаленной посылки
+// и окончательное удаление посылок по истечении срока хранения
+func TestRestoreAndPurge(t *testing.T) {
+	ctx := context.Background()
+	clk := clocktest.NewFake(clocktest.Epoch)
+	repo := newTestStore(t)
+	service := NewParcelService(repo, repo.Clients(), WithClock(clk))
+
+	old, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	recent, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+
+	require.NoError(t, service.Delete(ctx, old.Number))
+	clk.Advance(10 * 24 * time.Hour)
+	require.NoError(t, service.Delete(ctx, recent.Number))
+
+	// удаленные посылки видны только в выборке с IncludeDeleted
+	page, err := service.List(ctx, models.ParcelFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Parcels)
+	page, err = service.List(ctx, models.ParcelFilter{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Len(t, page.Parcels, 2)
+
+	// восстановленная посылка снова доступна, повторное восстановление - ошибка состояния
+	clk.Advance(time.Hour)
+	restored, err := service.Restore(ctx, recent.Number)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, clk.Now(), restored.UpdatedAt)
+	_, err = service.Get(ctx, recent.Number)
+	require.NoError(t, err)
+	_, err = service.Restore(ctx, recent.Number)
+	require.ErrorIs(t, err, errors.ErrInvalidState)
+	require.NoError(t, service.Delete(ctx, recent.Number))
+
+	// окончательно удаляется только посылка, удаленная раньше срока хранения
+	_, err = service.Purge(ctx, -time.Hour)
+	require.ErrorIs(t, err, errors.ErrValidation)
+	n, err := service.Purge(ctx, 7*24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = service.Restore(ctx, old.Number)
+	require.ErrorIs(t, err, errors.ErrNotFound)
+
+	// при нулевом сроке удаляются все удаленные посылки
+	clk.Advance(time.Second)
+	n, err = service.Purge(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	page, err = service.List(ctx, models.ParcelFilter{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Empty(t, page.Parcels)
+}
+
+// TestCanceledContext проверяет, что отмена контекста прерывает операции сервиса
+func TestCanceledContext(t *testing.T) {
+	service, _ := newTestService(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := service.Register(ctx, testRequest(1, testAddress))
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = service.NextStatus(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = service.ClientParcels(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestLogging проверяет, что сервис пишет в журнал операции со структурными полями
+func TestLogging(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	stores := storetest.NewMemory()
+	client := storetest.Client().Add(t, stores.Clients)
+	service := NewParcelService(stores.Parcels, stores.Clients,
+		WithClock(clocktest.NewFake(clocktest.Epoch)), WithLogger(logger))
+
+	parcel, err := service.Register(ctx, testRequest(client.ID, testAddress))
+	require.NoError(t, err)
+	_, err = service.NextStatus(ctx, parcel.Number, WithActor("courier"))
+	require.NoError(t, err)
+
+	var records []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var rec map[string]any
+		require.NoError(t, dec.Decode(&rec))
+		records = append(records, rec)
+	}
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "посылка зарегистрирована", records[0]["msg"])
+	assert.EqualValues(t, parcel.Number, records[0]["parcel"])
+	assert.EqualValues(t, client.ID, records[0]["client"])
+
+	assert.Equal(t, "статус посылки изменен", records[1]["msg"])
+	assert.EqualValues(t, parcel.Number, records[1]["parcel"])
+	assert.EqualValues(t, client.ID, records[1]["client"])
+	assert.Equal(t, constants.ParcelStatusRegistered, records[1]["from"])
+	assert.Equal(t, constants.ParcelStatusSent, records[1]["to"])
+	assert.Equal(t, "courier", records[1]["actor"])
+}
+
+// TestValidation проверяет отклонение некорректных входных данных
+func TestValidation(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	_, err := service.Register(ctx, testRequest(0, testAddress))
+	var validationErr *errors.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "client", validationErr.Field)
+
+	_, err = service.Register(ctx, testRequest(1, models.Address{City: "  "}))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "recipient.address", validationErr.Field)
+
+	// некорректные посылки не попадают в хранилище
+	parcels, err := repo.GetByClient(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, parcels)
+
+	parcel, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.ChangeAddress(ctx, parcel.Number, models.Address{}), errors.ErrValidation)
+
+	_, err = service.List(ctx, models.ParcelFilter{Statuses: []string{"unknown"}})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+}
+
+// TestAddress проверяет нормализацию и проверку адреса доставки
+func TestAddress(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	// адрес сохраняется в каноническом виде
+	parcel, err := service.Register(ctx, testRequest(1, models.Address{
+		PostalCode: "180 000", City: "г. Псков", Street: "Лесная улица", House: "дом 5", Apartment: "кв. 12",
+	}))
+	require.NoError(t, err)
+	want := models.Address{PostalCode: "180000", City: "Псков", Street: "ул. Лесная", House: "5", Apartment: "12"}
+	assert.Equal(t, want, parcel.Recipient.Address)
+	storedParcel, err := repo.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	assert.Equal(t, want, storedParcel.Recipient.Address)
+
+	// неполный адрес и некорректный индекс
+	var validationErr *errors.ValidationError
+	_, err = service.Register(ctx, testRequest(1, models.Address{City: "Псков", Street: "ул. Лесная"}))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "recipient."+address.FieldHouse, validationErr.Field)
+	err = service.ChangeAddress(ctx, parcel.Number, models.Address{PostalCode: "1800", City: "Псков", Street: "ул. Лесная", House: "5"})
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, address.FieldPostalCode, validationErr.Field)
+
+	// дополнительное правило проверки
+	strict := NewParcelService(repo, repo.Clients(),
+		WithAddressValidator(address.DefaultValidator().With(address.Required(address.FieldPostalCode))))
+	_, err = strict.Register(ctx, testRequest(1, testAddress))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "recipient."+address.FieldPostalCode, validationErr.Field)
+	_, err = strict.Register(ctx, testRequest(1, want))
+	require.NoError(t, err)
+}
+
+// TestRegisterClient проверяет, что посылку можно зарегистрировать
+// только для существующего активного клиента, и получение владельца посылки
+func TestRegisterClient(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	// несуществующий клиент
+	_, err := service.Register(ctx, testRequest(2, testAddress))
+	var validationErr *errors.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "client", validationErr.Field)
+
+	// владелец посылки
+	parcel, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	owner, err := service.Owner(ctx, parcel.Number)
+	require.NoError(t, err)
+	assert.Equal(t, 1, owner.ID)
+	_, err = service.Owner(ctx, parcel.Number+1)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	// деактивированный клиент: новые посылки не регистрируются, прежние сохраняются
+	require.NoError(t, repo.Clients().Deactivate(ctx, 1, clocktest.Epoch))
+	_, err = service.Register(ctx, testRequest(1, testAddress))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "client", validationErr.Field)
+
+	parcels, err := service.ClientParcels(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, parcels, 1)
+}
+
+// TestParties проверяет отправителя, получателя и адрес возврата посылки
+func TestParties(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+	client, err := repo.Clients().Get(ctx, 1)
+	require.NoError(t, err)
+
+	// отправитель по умолчанию - клиент, телефоны приводятся к одному виду
+	req := testRequest(1, testAddress)
+	req.Recipient.Phone = "+7 (999) 123-45-67"
+	parcel, err := service.Register(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, models.Party{Name: client.Name}, parcel.Sender)
+	assert.Equal(t, "+79991234567", parcel.Recipient.Phone)
+	assert.Nil(t, parcel.ReturnAddress)
+
+	// посылку без адреса отправителя и адреса возврата нельзя вернуть
+	_, err = service.NextStatus(ctx, parcel.Number)
+	require.NoError(t, err)
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusReturned)
+	assert.ErrorIs(t, err, errors.ErrInvalidState)
+
+	// отправитель с адресом и отдельный адрес возврата
+	req.Sender = models.Party{Name: " ООО Ромашка ", Phone: "+7 999 765 43 21", Address: models.Address{
+		City: "г. Псков", Street: "Садовая улица", House: "2",
+	}}
+	req.ReturnAddress = &models.Address{City: "Псков", Street: "ул. Садовая", House: "3"}
+	returned, err := service.Register(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, models.Party{Name: "ООО Ромашка", Phone: "+79997654321",
+		Address: models.Address{City: "Псков", Street: "ул. Садовая", House: "2"}}, returned.Sender)
+	assert.Equal(t, *req.ReturnAddress, returned.ReturnTo())
+
+	_, err = service.NextStatus(ctx, returned.Number)
+	require.NoError(t, err)
+	returned, err = service.Transition(ctx, returned.Number, constants.ParcelStatusReturned)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusReturned, returned.Status)
+
+	// посылки получателя по телефону в любой записи
+	page, err := service.List(ctx, models.ParcelFilter{RecipientPhone: "+7 999 123-45-67"})
+	require.NoError(t, err)
+	require.Len(t, page.Parcels, 2)
+	assert.Equal(t, parcel.Number, page.Parcels[0].Number)
+	assert.Equal(t, returned.Number, page.Parcels[1].Number)
+
+	// некорректные стороны: поле ошибки содержит название стороны
+	tests := []struct {
+		name  string
+		edit  func(r *RegisterRequest)
+		field string
+	}{
+		{"нет имени получателя", func(r *RegisterRequest) { r.Recipient.Name = " " }, "recipient.name"},
+		{"телефон получателя", func(r *RegisterRequest) { r.Recipient.Phone = "123" }, "recipient.phone"},
+		{"нет адреса получателя", func(r *RegisterRequest) { r.Recipient.Address = models.Address{} }, "recipient.address"},
+		{"телефон отправителя", func(r *RegisterRequest) { r.Sender.Phone = "phone" }, "sender.phone"},
+		{"адрес отправителя", func(r *RegisterRequest) { r.Sender.Address = models.Address{City: "Псков"} },
+			"sender." + address.FieldStreet},
+		{"адрес возврата", func(r *RegisterRequest) { r.ReturnAddress = &models.Address{City: "Псков"} },
+			"return_address." + address.FieldStreet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRequest(1, testAddress)
+			tt.edit(&r)
+
+			var validationErr *errors.ValidationError
+			_, err := service.Register(ctx, r)
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+		})
+	}
+
+	_, err = service.List(ctx, models.ParcelFilter{RecipientPhone: "phone"})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+}
+
+// TestPhysical проверяет проверку веса, габаритов и объявленной ценности посылки
+// по ограничениям уровня обслуживания
+func TestPhysical(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	// уровень обслуживания по умолчанию - стандартный
+	req := testRequest(1, testAddress)
+	req.Dimensions = models.Dimensions{Length: 40, Width: 30, Height: 20}
+	req.Contents = " книги "
+	req.DeclaredValue = 150000
+	parcel, err := service.Register(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ServiceLevelStandard, parcel.ServiceLevel)
+	assert.Equal(t, "книги", parcel.Contents)
+	assert.Equal(t, 4800, parcel.VolumetricWeight())
+	storedParcel, err := repo.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	assert.Equal(t, parcel, storedParcel)
+
+	// объемный вес округляется вверх, в JSON он выводится вместе с полями посылки
+	assert.Equal(t, 1, models.Dimensions{Length: 1, Width: 1, Height: 1}.VolumetricWeight())
+	data, err := json.Marshal(parcel)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"volumetric_weight":4800`)
+
+	// посылка, допустимая для стандартной доставки, слишком велика для срочной
+	req.ServiceLevel = constants.ServiceLevelExpress
+	req.Weight = 25000
+	var validationErr *errors.ValidationError
+	_, err = service.Register(ctx, req)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "weight", validationErr.Field)
+
+	tests := []struct {
+		name  string
+		edit  func(r *RegisterRequest)
+		field string
+	}{
+		{"нет веса", func(r *RegisterRequest) { r.Weight = 0 }, "weight"},
+		{"отрицательный вес", func(r *RegisterRequest) { r.Weight = -1 }, "weight"},
+		{"неизвестный уровень", func(r *RegisterRequest) { r.ServiceLevel = "overnight" }, "service_level"},
+		{"не все габариты", func(r *RegisterRequest) { r.Dimensions = models.Dimensions{Length: 10} }, "dimensions"},
+		{"длинная сторона", func(r *RegisterRequest) { r.Dimensions = models.Dimensions{Length: 151, Width: 10, Height: 10} }, "dimensions"},
+		{"сумма сторон", func(r *RegisterRequest) { r.Dimensions = models.Dimensions{Length: 120, Width: 100, Height: 90} }, "dimensions"},
+		{"отрицательная ценность", func(r *RegisterRequest) { r.DeclaredValue = -1 }, "declared_value"},
+		{"длинное описание", func(r *RegisterRequest) { r.Contents = strings.Repeat("я", maxContentsLen+1) }, "contents"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRequest(1, testAddress)
+			tt.edit(&r)
+
+			var validationErr *errors.ValidationError
+			_, err := service.Register(ctx, r)
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+		})
+	}
+
+	// собственные ограничения
+	limits := DefaultLimits()
+	limits[constants.ServiceLevelStandard] = Limits{MaxWeight: 500, MaxSide: 50, MaxSideSum: 100}
+	light := NewParcelService(repo, repo.Clients(), WithLimits(limits))
+	_, err = light.Register(ctx, testRequest(1, testAddress))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "weight", validationErr.Field)
+	assert.Equal(t, 31500, DefaultLimits()[constants.ServiceLevelStandard].MaxWeight)
+}
+
+// TestIdempotentRegister проверяет повторную регистрацию с ключом идемпотентности
+func TestIdempotentRegister(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	req := testRequest(1, testAddress)
+	req.Recipient.Phone = "+7 999 765-43-21"
+	req.IdempotencyKey = "order-1"
+	first, err := service.Register(ctx, req)
+	require.NoError(t, err)
+
+	// повтор возвращает ту же посылку в текущем состоянии,
+	// запись телефона и пробелы вокруг ключа не важны
+	_, err = service.NextStatus(ctx, first.Number)
+	require.NoError(t, err)
+	req.Recipient.Phone = "+79997654321"
+	req.IdempotencyKey = " order-1 "
+	second, err := service.Register(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, first.Number, second.Number)
+	assert.Equal(t, constants.ParcelStatusSent, second.Status)
+
+	// тот же ключ с другими данными отклоняется
+	var validationErr *errors.ValidationError
+	changed := req
+	changed.Weight = 2000
+	_, err = service.Register(ctx, changed)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "idempotency_key", validationErr.Field)
+
+	// без ключа и с другим ключом регистрируются новые посылки
+	req.IdempotencyKey = ""
+	third, err := service.Register(ctx, req)
+	require.NoError(t, err)
+	req.IdempotencyKey = "order-2"
+	fourth, err := service.Register(ctx, req)
+	require.NoError(t, err)
+	assert.NotEqual(t, third.Number, fourth.Number)
+
+	page, err := service.List(ctx, models.ParcelFilter{Client: 1})
+	require.NoError(t, err)
+	assert.Len(t, page.Parcels, 3)
+
+	// ключи разных клиентов независимы
+	storetest.Client().Add(t, repo.Clients())
+	req.Client = 2
+	other, err := service.Register(ctx, req)
+	require.NoError(t, err)
+	assert.NotEqual(t, fourth.Number, other.Number)
+
+	// ключ удаленной посылки остается занятым: повтор не регистрирует посылку заново
+	require.NoError(t, service.Delete(ctx, other.Number))
+	_, err = service.Register(ctx, req)
+	require.ErrorIs(t, err, errors.ErrNotFound)
+
+	// длина ключа считается в символах, а не в байтах
+	req.IdempotencyKey = strings.Repeat("к", maxIdempotencyKeyLen)
+	_, err = service.Register(ctx, req)
+	require.NoError(t, err)
+
+	// некорректный ключ
+	req.IdempotencyKey = strings.Repeat("к", maxIdempotencyKeyLen+1)
+	_, err = service.Register(ctx, req)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "idempotency_key", validationErr.Field)
+}
+
+// TestIdempotentRegisterConcurrent проверяет, что параллельные запросы с одним ключом
+// регистрируют одну посылку и возвращают ее всем вызывающим
+func TestIdempotentRegisterConcurrent(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(t)
+
+	req := testRequest(1, testAddress)
+	req.IdempotencyKey = "order-1"
+
+	const n = 8
+	numbers := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parcel, err := service.Register(ctx, req)
+			assert.NoError(t, err)
+			numbers <- parcel.Number
+		}()
+	}
+	wg.Wait()
+	close(numbers)
+
+	for number := range numbers {
+		assert.Equal(t, 1, number)
+	}
+	page, err := service.List(ctx, models.ParcelFilter{Client: 1})
+	require.NoError(t, err)
+	assert.Len(t, page.Parcels, 1)
+}
+
+// TestTrackingCode проверяет выдачу кодов отслеживания и поиск посылки по коду
+func TestTrackingCode(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	// по умолчанию коды случайные, но корректные и разные
+	first, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	second, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	require.NoError(t, tracking.Validate(first.TrackingCode))
+	assert.NotEqual(t, first.TrackingCode, second.TrackingCode)
+
+	// код вводится с пробелами и в нижнем регистре
+	parcel, err := service.GetByTrackingCode(ctx, strings.ToLower(first.TrackingCode[:5]+" "+first.TrackingCode[5:]))
+	require.NoError(t, err)
+	assert.Equal(t, first, parcel)
+
+	// занятый код заменяется следующим
+	sequential := NewParcelService(repo, repo.Clients(),
+		WithTrackingGenerator(tracking.Sequence(tracking.DefaultService, tracking.DefaultCountry, 12345678)))
+	parcel, err = sequential.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	assert.Equal(t, "RR123456785RU", parcel.TrackingCode)
+	_, err = sequential.GetByTrackingCode(ctx, "RR123456785RU")
+	require.NoError(t, err)
+	repeated := NewParcelService(repo, repo.Clients(),
+		WithTrackingGenerator(tracking.Sequence(tracking.DefaultService, tracking.DefaultCountry, 12345678)))
+	parcel, err = repeated.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	assert.Equal(t, "RR12345679", parcel.TrackingCode[:10])
+
+	// код с опечаткой отклоняется без обращения к хранилищу, неизвестный код не найден
+	var validationErr *errors.ValidationError
+	_, err = service.GetByTrackingCode(ctx, "RR123456795RU")
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, tracking.FieldCode, validationErr.Field)
+	_, err = service.GetByTrackingCode(ctx, "12345")
+	require.ErrorAs(t, err, &validationErr)
+	_, err = service.GetByTrackingCode(ctx, "RR000000005RU")
+	require.ErrorIs(t, err, errors.ErrNotFound)
+
+	// ошибка генератора прерывает регистрацию
+	exhausted := NewParcelService(repo, repo.Clients(),
+		WithTrackingGenerator(tracking.Sequence(tracking.DefaultService, tracking.DefaultCountry, 100000000)))
+	_, err = exhausted.Register(ctx, testRequest(1, testAddress))
+	require.Error(t, err)
+}
+
+// TestQuote проверяет расчет стоимости до регистрации и ее сохранение при регистрации
+func TestQuote(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	// для расчета клиент и имя получателя не нужны
+	req := RegisterRequest{Weight: 4000, Recipient: models.Party{Address: testAddress}}
+	quote, err := service.Quote(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, pricing.ZoneLocal, quote.Zone)
+	assert.Equal(t, constants.ServiceLevelStandard, quote.ServiceLevel)
+	assert.Equal(t, int64(30000), quote.Price)
+
+	// стоимость зарегистрированной посылки совпадает с расчетом
+	req.Client = 1
+	req.Recipient.Name = "Получатель"
+	parcel, err := service.Register(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, quote.Price, parcel.Cost)
+
+	// изменение тарифов не меняет стоимость зарегистрированных посылок
+	tariffs := pricing.DefaultTable()
+	tariffs.Levels[constants.ServiceLevelStandard][pricing.ZoneLocal] = []pricing.Bracket{{MaxWeight: 31500, Price: 99900}}
+	repriced := NewParcelService(repo, repo.Clients(), WithTariffs(tariffs))
+	quote, err = repriced.Quote(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(99900), quote.Price)
+	storedParcel, err := repriced.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30000), storedParcel.Cost)
+
+	// зона считается от адреса отправителя, если он указан
+	req.Sender.Address = models.Address{Country: "Казахстан", City: "Алматы", Street: "пр-т Абая", House: "1"}
+	quote, err = service.Quote(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, pricing.ZoneInternational, quote.Zone)
+
+	// направление без тарифа и некорректные данные
+	var validationErr *errors.ValidationError
+	req.ServiceLevel = constants.ServiceLevelExpress
+	_, err = service.Quote(ctx, req)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "recipient.address", validationErr.Field)
+	_, err = service.Register(ctx, req)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "recipient.address", validationErr.Field)
+
+	_, err = service.Quote(ctx, RegisterRequest{Recipient: models.Party{Address: testAddress}})
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "weight", validationErr.Field)
+	_, err = service.Quote(ctx, RegisterRequest{Weight: 1000})
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "recipient.address", validationErr.Field)
+}
+
+// TestHistory проверяет запись событий при смене статуса
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService(t)
+
+	parcel, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+
+	_, err = service.NextStatus(ctx, parcel.Number)
+	require.NoError(t, err)
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusLost,
+		WithActor("operator-7"), WithLocation("Псков"), WithComment("не прибыла на сортировку"))
+	require.NoError(t, err)
+
+	// недопустимый переход не попадает в историю
+	_, err = service.Transition(ctx, parcel.Number, constants.ParcelStatusDelivered)
+	require.Error(t, err)
+
+	events, err := service.History(ctx, parcel.Number)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	assert.Equal(t, constants.ParcelStatusRegistered, events[0].From)
+	assert.Equal(t, constants.ParcelStatusSent, events[0].To)
+	assert.Equal(t, DefaultActor, events[0].Actor)
+	assert.NotEmpty(t, events[0].At)
+
+	assert.Equal(t, constants.ParcelStatusSent, events[1].From)
+	assert.Equal(t, constants.ParcelStatusLost, events[1].To)
+	assert.Equal(t, "operator-7", events[1].Actor)
+	assert.Equal(t, "Псков", events[1].Location)
+	assert.Equal(t, "не прибыла на сортировку", events[1].Comment)
+
+	// история несуществующей посылки
+	_, err = service.History(ctx, parcel.Number+1)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// TestNextStatusConcurrent проверяет, что параллельные вызовы NextStatus
+// для одной посылки не теряют обновлений: каждый переход по маршруту
+// выполняется ровно один раз, а история остается непрерывной цепочкой
+func TestNextStatusConcurrent(t *testing.T) {
+	repos := map[string]func(t *testing.T) storetest.Stores{
+		"memory": func(t *testing.T) storetest.Stores {
+			return storetest.NewMemory()
+		},
+		"sqlite": func(t *testing.T) storetest.Stores {
+			return storetest.NewSQLite(t)
+		},
+	}
+
+	for name, newStores := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			stores := newStores(t)
+			client := storetest.Client().Add(t, stores.Clients)
+			service := NewParcelService(stores.Parcels, stores.Clients)
+
+			parcel, err := service.Register(ctx, testRequest(client.ID, testAddress))
+			require.NoError(t, err)
+
+			// вызовов больше, чем переходов основного маршрута:
+			// лишние должны завершиться ошибкой недопустимого статуса
+			const workers = 16
+			var (
+				wg        sync.WaitGroup
+				succeeded atomic.Int32
+				errs      = make(chan error, workers)
+			)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					_, err := service.NextStatus(ctx, parcel.Number)
+					if err == nil {
+						succeeded.Add(1)
+						return
+					}
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				assert.ErrorIs(t, err, errors.ErrInvalidState)
+			}
+
+			// registered -> sent -> in_transit -> out_for_delivery -> delivered
+			assert.EqualValues(t, 4, succeeded.Load())
+
+			storedParcel, err := service.Get(ctx, parcel.Number)
+			require.NoError(t, err)
+			assert.Equal(t, constants.ParcelStatusDelivered, storedParcel.Status)
+
+			events, err := service.History(ctx, parcel.Number)
+			require.NoError(t, err)
+			require.Len(t, events, 4)
+			from := constants.ParcelStatusRegistered
+			for _, ev := range events {
+				assert.Equal(t, from, ev.From)
+				from = ev.To
+			}
+			assert.Equal(t, constants.ParcelStatusDelivered, from)
+		})
+	}
+}
+
+// TestRegisterBatch проверяет пакетную регистрацию: посылки с ошибками в данных
+// получают ошибку в своем результате и не мешают зарегистрировать остальные
+func TestRegisterBatch(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	invalid := testRequest(1, testAddress)
+	invalid.Weight = 0
+	keyed := testRequest(1, testAddress)
+	keyed.IdempotencyKey = "order-1"
+	conflicting := keyed
+	conflicting.Weight = 2000
+
+	results, err := service.RegisterBatch(ctx, []RegisterRequest{
+		testRequest(1, testAddress),
+		invalid,
+		testRequest(2, testAddress), // клиента 2 нет
+		keyed,
+		keyed,       // повтор ключа внутри пакета
+		conflicting, // тот же ключ с другими данными
+		testRequest(1, newTestAddress),
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 7)
+
+	for _, i := range []int{1, 2, 5} {
+		assert.ErrorIs(t, results[i].Err, errors.ErrValidation, i)
+		assert.Empty(t, results[i].Parcel.Number, i)
+	}
+	for _, i := range []int{0, 3, 4, 6} {
+		require.NoError(t, results[i].Err, i)
+		stored, err := repo.Get(ctx, results[i].Parcel.Number)
+		require.NoError(t, err)
+		assert.Equal(t, results[i].Parcel, stored)
+		require.NoError(t, tracking.Validate(stored.TrackingCode))
+	}
+	assert.Equal(t, results[3].Parcel, results[4].Parcel)
+	assert.Equal(t, newTestAddress, results[6].Parcel.Recipient.Address)
+
+	page, err := service.List(ctx, models.ParcelFilter{Client: 1})
+	require.NoError(t, err)
+	assert.Len(t, page.Parcels, 3)
+
+	// ключ, сохраненный пакетом, работает и для одиночной регистрации
+	parcel, err := service.Register(ctx, keyed)
+	require.NoError(t, err)
+	assert.Equal(t, results[3].Parcel.Number, parcel.Number)
+
+	// пустой и слишком большой пакеты отклоняются целиком
+	_, err = service.RegisterBatch(ctx, nil)
+	require.ErrorIs(t, err, errors.ErrValidation)
+	_, err = service.RegisterBatch(ctx, make([]RegisterRequest, maxBatchSize+1))
+	require.ErrorIs(t, err, errors.ErrValidation)
+}
+
+// TestTransitionBatch проверяет пакетный перевод посылок в статус
+func TestTransitionBatch(t *testing.T) {
+	ctx := context.Background()
+	service, repo := newTestService(t)
+
+	var numbers []int
+	for i := 0; i < 3; i++ {
+		parcel, err := service.Register(ctx, testRequest(1, testAddress))
+		require.NoError(t, err)
+		numbers = append(numbers, parcel.Number)
+	}
+	_, err := service.Transition(ctx, numbers[2], constants.ParcelStatusCancelled)
+	require.NoError(t, err)
+
+	results, err := service.TransitionBatch(ctx,
+		[]int{numbers[0], numbers[2], 100, numbers[1], numbers[0]},
+		constants.ParcelStatusSent, WithActor("склад"))
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	// отмененную посылку отправить нельзя
+	var stateErr *errors.StateError
+	require.ErrorAs(t, results[1].Err, &stateErr)
+	assert.Equal(t, constants.ParcelStatusCancelled, stateErr.Status)
+	assert.ErrorIs(t, results[2].Err, errors.ErrNotFound)
+	assert.ErrorIs(t, results[4].Err, errors.ErrValidation)
+
+	for _, i := range []int{0, 3} {
+		require.NoError(t, results[i].Err, i)
+		assert.Equal(t, constants.ParcelStatusSent, results[i].Parcel.Status)
+		stored, err := repo.Get(ctx, results[i].Parcel.Number)
+		require.NoError(t, err)
+		assert.Equal(t, results[i].Parcel, stored)
+		require.NotNil(t, stored.SentAt)
+
+		events, err := service.History(ctx, stored.Number)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "склад", events[0].Actor)
+		assert.Equal(t, *stored.SentAt, events[0].At)
+	}
+	assert.Equal(t, results[0].Parcel.SentAt, results[3].Parcel.SentAt)
+
+	stored, err := repo.Get(ctx, numbers[2])
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusCancelled, stored.Status)
+
+	// неизвестный статус отклоняется целиком
+	_, err = service.TransitionBatch(ctx, numbers, "unknown")
+	require.ErrorIs(t, err, errors.ErrValidation)
+}
+
+// определяем структурный тип racingRepository - хранилище, в котором посылка race
+// сразу после первого чтения переходит в статус to, как при параллельном запросе,
+// завершившемся между проверкой перехода и записью статуса
+type racingRepository struct {
+	store.ParcelRepository
+	race int    // номер посылки
+	to   string // статус, который устанавливает параллельный запрос
+}
+
+// Метод WithTx типа racingRepository передает в fn транзакцию с той же подменой чтения
+func (r racingRepository) WithTx(ctx context.Context, fn func(tx store.ParcelRepository) error) error {
+	return r.ParcelRepository.WithTx(ctx, func(tx store.ParcelRepository) error {
+		return fn(racingRepository{ParcelRepository: tx, race: r.race, to: r.to})
+	})
+}
+
+// Метод Get типа racingRepository возвращает прочитанную посылку,
+// а в хранилище меняет ее статус на r.to
+func (r racingRepository) Get(ctx context.Context, number int) (models.Parcel, error) {
+	p, err := r.ParcelRepository.Get(ctx, number)
+	if err == nil && number == r.race && p.Status != r.to {
+		err = r.ParcelRepository.SetStatus(ctx, number, r.to, p.UpdatedAt)
+	}
+
+	return p, err
+}
+
+// TestTransitionBatchRace проверяет, что посылка, статус которой изменился
+// после проверки перехода, получает ошибку в своем результате, а не перезаписывается
+func TestTransitionBatchRace(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestStore(t)
+	service := NewParcelService(repo, repo.Clients())
+
+	first, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	second, err := service.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+
+	racing := NewParcelService(racingRepository{ParcelRepository: repo, race: first.Number,
+		to: constants.ParcelStatusCancelled}, repo.Clients())
+	results, err := racing.TransitionBatch(ctx, []int{first.Number, second.Number}, constants.ParcelStatusSent)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	var stateErr *errors.StateError
+	require.ErrorAs(t, results[0].Err, &stateErr)
+	assert.Equal(t, constants.ParcelStatusCancelled, stateErr.Status)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, constants.ParcelStatusSent, results[1].Parcel.Status)
+
+	stored, err := repo.Get(ctx, first.Number)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusCancelled, stored.Status)
+	events, err := repo.Events(ctx, first.Number)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+// размер пакета в бенчмарках регистрации и смены статуса
+const benchmarkBatchSize = 100
+
+// newBenchmarkService возвращает сервис поверх новой БД SQLite с активным клиентом,
+// журнал сервиса отключен, чтобы не влиять на замеры
+func newBenchmarkService(b *testing.B) (ParcelService, int) {
+	stores := storetest.NewSQLite(b)
+	client := storetest.Client().Add(b, stores.Clients)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return NewParcelService(stores.Parcels, stores.Clients, WithLogger(logger)), client.ID
+}
+
+// BenchmarkRegister регистрирует пакет посылок вызовами Register по одной посылке
+func BenchmarkRegister(b *testing.B) {
+	ctx := context.Background()
+	service, client := newBenchmarkService(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchmarkBatchSize; j++ {
+			if _, err := service.Register(ctx, testRequest(client, testAddress)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkRegisterBatch регистрирует тот же пакет одним вызовом RegisterBatch:
+// посылки сохраняются одной транзакцией и подготовленным запросом
+func BenchmarkRegisterBatch(b *testing.B) {
+	ctx := context.Background()
+	service, client := newBenchmarkService(b)
+
+	reqs := make([]RegisterRequest, benchmarkBatchSize)
+	for j := range reqs {
+		reqs[j] = testRequest(client, testAddress)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := service.RegisterBatch(ctx, reqs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// registerBenchmarkBatch регистрирует пакет посылок для бенчмарков смены статуса
+// и возвращает их номера; время регистрации в замер не входит
+func registerBenchmarkBatch(b *testing.B, service ParcelService, client int) []int {
+	b.StopTimer()
+	defer b.StartTimer()
+
+	reqs := make([]RegisterRequest, benchmarkBatchSize)
+	for j := range reqs {
+		reqs[j] = testRequest(client, testAddress)
+	}
+	results, err := service.RegisterBatch(context.Background(), reqs)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	numbers := make([]int, len(results))
+	for j, r := range results {
+		numbers[j] = r.Parcel.Number
+	}
+
+	return numbers
+}
+
+// BenchmarkTransition отправляет пакет посылок вызовами Transition по одной посылке
+func BenchmarkTransition(b *testing.B) {
+	ctx := context.Background()
+	service, client := newBenchmarkService(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, number := range registerBenchmarkBatch(b, service, client) {
+			if _, err := service.Transition(ctx, number, constants.ParcelStatusSent); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkTransitionBatch отправляет тот же пакет одним вызовом TransitionBatch:
+// статусы меняются одной транзакцией и подготовленным запросом
+func BenchmarkTransitionBatch(b *testing.B) {
+	ctx := context.Background()
+	service, client := newBenchmarkService(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		numbers := registerBenchmarkBatch(b, service, client)
+		results, err := service.TransitionBatch(ctx, numbers, constants.ParcelStatusSent)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				b.Fatal(r.Err)
+			}
+		}
+	}
+}
+
+// определяем структурный тип unseenCodesRepository - хранилище, в котором GetByTrackingCode
+// не находит ни одной посылки, как при параллельной регистрации, занявшей код
+// после его проверки, но до добавления посылки
+type unseenCodesRepository struct {
+	store.ParcelRepository
+}
+
+// Метод GetByTrackingCode типа unseenCodesRepository всегда возвращает errors.NotFoundError
+func (r unseenCodesRepository) GetByTrackingCode(_ context.Context, code string) (models.Parcel, error) {
+	return models.Parcel{}, errors.NotFound("посылка", code)
+}
+
+// определяем структурный тип fixedGenerator - генератор, всегда выдающий один и тот же код
+type fixedGenerator string
+
+// Метод Next типа fixedGenerator возвращает код g
+func (g fixedGenerator) Next() (string, error) {
+	return string(g), nil
+}
+
+// TestTrackingCodeRace проверяет, что регистрация, код которой занят после проверки,
+// повторяется с новым кодом, а число повторов ограничено
+func TestTrackingCodeRace(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestStore(t)
+	sequence := func() tracking.Generator {
+		return tracking.Sequence(tracking.DefaultService, tracking.DefaultCountry, 12345678)
+	}
+
+	taken, err := NewParcelService(repo, repo.Clients(), WithTrackingGenerator(sequence())).
+		Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	assert.Equal(t, "RR123456785RU", taken.TrackingCode)
+
+	// проверка пропускает занятый код, его отклоняет хранилище, и посылке выдается следующий
+	racing := NewParcelService(unseenCodesRepository{repo}, repo.Clients(), WithTrackingGenerator(sequence()))
+	parcel, err := racing.Register(ctx, testRequest(1, testAddress))
+	require.NoError(t, err)
+	assert.Equal(t, "RR12345679", parcel.TrackingCode[:10])
+	stored, err := repo.Get(ctx, parcel.Number)
+	require.NoError(t, err)
+	assert.Equal(t, parcel, stored)
+
+	// в пакете новые коды получают все посылки
+	racing = NewParcelService(unseenCodesRepository{repo}, repo.Clients(), WithTrackingGenerator(sequence()))
+	results, err := racing.RegisterBatch(ctx, []RegisterRequest{testRequest(1, testAddress), testRequest(1, testAddress)})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, res := range results {
+		require.NoError(t, res.Err)
+		stored, err := repo.Get(ctx, res.Parcel.Number)
+		require.NoError(t, err)
+		assert.Equal(t, res.Parcel.TrackingCode, stored.TrackingCode)
+		assert.NotEqual(t, taken.TrackingCode, stored.TrackingCode)
+		assert.NotEqual(t, parcel.TrackingCode, stored.TrackingCode)
+	}
+	assert.NotEqual(t, results[0].Parcel.TrackingCode, results[1].Parcel.TrackingCode)
+
+	// если свободный код так и не выдан, регистрация отклоняется после maxTrackingAttempts попыток
+	stuck := NewParcelService(unseenCodesRepository{repo}, repo.Clients(),
+		WithTrackingGenerator(fixedGenerator(taken.TrackingCode)))
+	_, err = stuck.Register(ctx, testRequest(1, testAddress))
+	require.ErrorIs(t, err, store.ErrTrackingCodeTaken)
+	_, err = stuck.RegisterBatch(ctx, []RegisterRequest{testRequest(1, testAddress)})
+	require.ErrorIs(t, err, store.ErrTrackingCodeTaken)
+	page, err := repo.List(ctx, models.ParcelFilter{Client: 1})
+	require.NoError(t, err)
+	assert.Len(t, page.Parcels, 4)
+}
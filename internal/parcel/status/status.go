@@ -0,0 +1,118 @@
+package status
+
+import (
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+)
+
+// в пакете описан конечный автомат статусов посылки:
+// какие статусы существуют, какие переходы между ними допустимы
+// и какой статус следует за текущим на основном маршруте доставки
+
+// определяем структурный тип Machine - автомат статусов посылки
+type Machine struct {
+	route       []string            // основной маршрут доставки, по которому двигает посылку NextStatus
+	transitions map[string][]string // допустимые переходы: текущий статус -> возможные следующие
+}
+
+// функция NewMachine создает автомат статусов
+// Параметры
+// route - статусы основного маршрута в порядке следования
+// transitions - допустимые переходы из каждого статуса,
+// статус без исходящих переходов считается конечным
+func NewMachine(route []string, transitions map[string][]string) Machine {
+	return Machine{route: route, transitions: transitions}
+}
+
+// функция Default возвращает автомат статусов, принятый в трекере:
+// registered -> sent -> in_transit -> out_for_delivery -> delivered,
+// зарегистрированную посылку можно отменить, отправленную - вернуть отправителю
+// или признать утерянной, утерянная посылка может найтись и продолжить путь
+func Default() Machine {
+	return NewMachine(
+		[]string{
+			constants.ParcelStatusRegistered,
+			constants.ParcelStatusSent,
+			constants.ParcelStatusInTransit,
+			constants.ParcelStatusOutForDelivery,
+			constants.ParcelStatusDelivered,
+		},
+		map[string][]string{
+			constants.ParcelStatusRegistered: {
+				constants.ParcelStatusSent,
+				constants.ParcelStatusCancelled,
+			},
+			constants.ParcelStatusSent: {
+				constants.ParcelStatusInTransit,
+				constants.ParcelStatusReturned,
+				constants.ParcelStatusLost,
+			},
+			constants.ParcelStatusInTransit: {
+				constants.ParcelStatusOutForDelivery,
+				constants.ParcelStatusReturned,
+				constants.ParcelStatusLost,
+			},
+			constants.ParcelStatusOutForDelivery: {
+				constants.ParcelStatusDelivered,
+				constants.ParcelStatusReturned,
+				constants.ParcelStatusLost,
+			},
+			constants.ParcelStatusLost: {
+				constants.ParcelStatusInTransit,
+			},
+			// конечные статусы
+			constants.ParcelStatusDelivered: nil,
+			constants.ParcelStatusCancelled: nil,
+			constants.ParcelStatusReturned:  nil,
+		},
+	)
+}
+
+// Метод Known типа Machine сообщает, известен ли автомату статус
+// Параметры
+// status - проверяемый статус
+func (m Machine) Known(status string) bool {
+	_, ok := m.transitions[status]
+	return ok
+}
+
+// Метод Allowed типа Machine возвращает статусы, в которые можно перейти из from
+// Параметры
+// from - текущий статус
+func (m Machine) Allowed(from string) []string {
+	return m.transitions[from]
+}
+
+// Метод CanTransition типа Machine сообщает, допустим ли переход из from в to
+// Параметры
+// from - текущий статус
+// to - новый статус
+func (m Machine) CanTransition(from string, to string) bool {
+	for _, status := range m.transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Метод IsTerminal типа Machine сообщает, является ли известный статус конечным
+// Параметры
+// status - проверяемый статус
+func (m Machine) IsTerminal(status string) bool {
+	return m.Known(status) && len(m.transitions[status]) == 0
+}
+
+// Метод Next типа Machine возвращает статус, следующий за from на основном маршруте.
+// Второе значение равно false, если from не лежит на маршруте или является его последним шагом
+// Параметры
+// from - текущий статус
+func (m Machine) Next(from string) (string, bool) {
+	for i := 0; i < len(m.route)-1; i++ {
+		if m.route[i] == from {
+			return m.route[i+1], true
+		}
+	}
+
+	return "", false
+}
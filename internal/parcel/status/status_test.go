@@ -0,0 +1,75 @@
+package status
+
+import (
+	// импортируем пакеты standard library
+	"testing"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+)
+
+// TestDefaultRoute проверяет основной маршрут доставки
+func TestDefaultRoute(t *testing.T) {
+	m := Default()
+
+	route := []string{
+		constants.ParcelStatusRegistered,
+		constants.ParcelStatusSent,
+		constants.ParcelStatusInTransit,
+		constants.ParcelStatusOutForDelivery,
+		constants.ParcelStatusDelivered,
+	}
+	for i := 0; i < len(route)-1; i++ {
+		next, ok := m.Next(route[i])
+		assert.True(t, ok)
+		assert.Equal(t, route[i+1], next)
+		// каждый шаг маршрута - допустимый переход
+		assert.True(t, m.CanTransition(route[i], next))
+	}
+
+	// у доставленной и утерянной посылки следующего статуса на маршруте нет
+	_, ok := m.Next(constants.ParcelStatusDelivered)
+	assert.False(t, ok)
+	_, ok = m.Next(constants.ParcelStatusLost)
+	assert.False(t, ok)
+}
+
+// TestDefaultTransitions проверяет допустимые и недопустимые переходы
+func TestDefaultTransitions(t *testing.T) {
+	m := Default()
+
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{constants.ParcelStatusRegistered, constants.ParcelStatusCancelled, true},
+		{constants.ParcelStatusRegistered, constants.ParcelStatusDelivered, false},
+		{constants.ParcelStatusSent, constants.ParcelStatusLost, true},
+		{constants.ParcelStatusSent, constants.ParcelStatusCancelled, false},
+		{constants.ParcelStatusOutForDelivery, constants.ParcelStatusReturned, true},
+		{constants.ParcelStatusLost, constants.ParcelStatusInTransit, true},
+		{constants.ParcelStatusDelivered, constants.ParcelStatusSent, false},
+		{constants.ParcelStatusCancelled, constants.ParcelStatusRegistered, false},
+		{"unknown", constants.ParcelStatusSent, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, m.CanTransition(tt.from, tt.to), "%s -> %s", tt.from, tt.to)
+	}
+
+	// конечные статусы
+	assert.True(t, m.IsTerminal(constants.ParcelStatusDelivered))
+	assert.True(t, m.IsTerminal(constants.ParcelStatusCancelled))
+	assert.True(t, m.IsTerminal(constants.ParcelStatusReturned))
+	assert.False(t, m.IsTerminal(constants.ParcelStatusLost))
+	assert.False(t, m.IsTerminal("unknown"))
+
+	// все статусы, в которые ведут переходы, известны автомату
+	for from, targets := range m.transitions {
+		for _, to := range targets {
+			assert.True(t, m.Known(to), "%s -> %s", from, to)
+		}
+	}
+}
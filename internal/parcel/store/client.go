@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// clientColumns - столбцы таблицы client в порядке, который ожидает scanClient
+const clientColumns = "id, name, phone, email, active, created_at, updated_at"
+
+// определяем структурный тип ClientStore для работы с таблицей client в SQLite и PostgreSQL.
+// Запросы используют позиционные параметры $1, $2, ..., которые понимают оба драйвера,
+// а от диалекта зависит только представление моментов времени
+type ClientStore struct {
+	db      dbtx    // выполняет запросы: указатель на БД или транзакция
+	dialect Dialect // диалект SQL подключенной СУБД
+}
+
+// функция NewClientStore для создания нового экземпляра ClientStore
+// Параметры
+// db - указатель на БД
+// dialect - диалект SQL подключенной СУБД
+func NewClientStore(db *sql.DB, dialect Dialect) ClientStore {
+	return ClientStore{db: db, dialect: dialect}
+}
+
+// Метод Create типа ClientStore добавляет
+// в таблицу client запись для нового клиента
+// Параметры
+// ctx - контекст запроса
+// c - экземпляр типа Client
+// возвращает идентификатор, присвоенный клиенту
+func (s ClientStore) Create(ctx context.Context, c models.Client) (int, error) {
+	updatedAt := c.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = c.CreatedAt
+	}
+
+	var id int
+	err := s.db.QueryRowContext(ctx, `INSERT INTO client (name, phone, email, active, created_at, updated_at)
+									  VALUES ($1, $2, $3, $4, $5, $6)
+									  RETURNING id`,
+		c.Name, c.Phone, c.Email, c.Active, s.dialect.timeArg(c.CreatedAt), s.dialect.timeArg(updatedAt)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Метод Get типа ClientStore
+// получает данные о клиенте по идентификатору
+// Параметры
+// ctx - контекст запроса
+// id - идентификатор клиента
+func (s ClientStore) Get(ctx context.Context, id int) (models.Client, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+clientColumns+`
+									  FROM client
+									  WHERE id = $1`, id)
+
+	c, err := scanClient(row)
+	if err == sql.ErrNoRows {
+		return c, errors.NotFound(entityClient, id)
+	}
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// Метод Update типа ClientStore
+// изменяет имя, телефон и адрес электронной почты клиента
+// Параметры
+// ctx - контекст запроса
+// c - клиент с новыми данными, UpdatedAt - момент изменения
+func (s ClientStore) Update(ctx context.Context, c models.Client) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE client
+									   SET name = $1, phone = $2, email = $3, updated_at = $4
+									   WHERE id = $5`,
+		c.Name, c.Phone, c.Email, s.dialect.timeArg(c.UpdatedAt), c.ID)
+	if err != nil {
+		return err
+	}
+
+	return checkClientAffected(res, c.ID)
+}
+
+// Метод List типа ClientStore
+// возвращает клиентов, удовлетворяющих условиям f, по возрастанию идентификатора
+// Параметры
+// ctx - контекст запроса
+// f - условия выборки
+func (s ClientStore) List(ctx context.Context, f models.ClientFilter) ([]models.Client, error) {
+	f, err := normalizeClientFilter(f)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"id > $1"}
+	args := []any{f.AfterID}
+	if !f.IncludeInactive {
+		args = append(args, true)
+		conditions = append(conditions, fmt.Sprintf("active = $%d", len(args)))
+	}
+	// поиск подстроки без LIKE, как и в списке посылок
+	if f.NameContains != "" {
+		args = append(args, f.NameContains)
+		fn := "instr(name, $%d) > 0"
+		if s.dialect == Postgres {
+			fn = "strpos(name, $%d) > 0"
+		}
+		conditions = append(conditions, fmt.Sprintf(fn, len(args)))
+	}
+	args = append(args, f.Limit)
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT `+clientColumns+`
+													  FROM client
+													  WHERE %s
+													  ORDER BY id
+													  LIMIT $%d`, strings.Join(conditions, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var res = make([]models.Client, 0)
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// Метод Deactivate типа ClientStore
+// деактивирует клиента
+// Параметры
+// ctx - контекст запроса
+// id - идентификатор клиента
+// at - момент изменения
+func (s ClientStore) Deactivate(ctx context.Context, id int, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE client
+									   SET active = $1, updated_at = $2
+									   WHERE id = $3`,
+		false, s.dialect.timeArg(at), id)
+	if err != nil {
+		return err
+	}
+
+	return checkClientAffected(res, id)
+}
+
+// функция checkClientAffected возвращает errors.NotFoundError,
+// если запрос не изменил ни одной строки клиента id
+func checkClientAffected(res sql.Result, id int) error {
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.NotFound(entityClient, id)
+	}
+
+	return nil
+}
+
+// функция normalizeClientFilter проверяет условия выборки клиентов
+// и заполняет значения по умолчанию, см. normalizeFilter
+func normalizeClientFilter(f models.ClientFilter) (models.ClientFilter, error) {
+	switch {
+	case f.Limit < 0:
+		return f, errors.Validation("limit", "размер страницы не может быть отрицательным")
+	case f.Limit == 0:
+		f.Limit = DefaultListLimit
+	case f.Limit > MaxListLimit:
+		return f, errors.Validation("limit", fmt.Sprintf("размер страницы не может превышать %d", MaxListLimit))
+	}
+
+	if f.AfterID < 0 {
+		return f, errors.Validation("after", "идентификатор не может быть отрицательным")
+	}
+
+	return f, nil
+}
+
+// функция matchClientFilter проверяет, что клиент удовлетворяет условиям выборки f.
+// Используется хранилищем в памяти
+func matchClientFilter(c models.Client, f models.ClientFilter) bool {
+	return c.ID > f.AfterID &&
+		(f.IncludeInactive || c.Active) &&
+		strings.Contains(c.Name, f.NameContains)
+}
+
+// функция scanClient читает клиента из строки результата со столбцами clientColumns
+func scanClient(row rowScanner) (models.Client, error) {
+	c := models.Client{}
+	err := row.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.Active, scanTime(&c.CreatedAt), scanTime(&c.UpdatedAt))
+
+	return c, err
+}
@@ -0,0 +1,158 @@
+package store_test
+
+import (
+	// импортируем пакеты standard library
+	"context"
+	"testing"
+	"time"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/clock/clocktest"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store/storetest"
+)
+
+// runClientRepositoryTests запускает набор тестов, который должна проходить
+// каждая реализация ClientRepository, см. runParcelRepositoryTests
+func runClientRepositoryTests(t *testing.T, newStores func(t *testing.T) storetest.Stores) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s storetest.Stores)
+	}{
+		{"CreateGetUpdate", testClientCreateGetUpdate},
+		{"List", testClientList},
+		{"Deactivate", testClientDeactivate},
+		{"ParcelForeignKey", testParcelForeignKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.run(t, newStores(t))
+		})
+	}
+}
+
+// TestClientStore проверяет хранилище клиентов в SQLite
+func TestClientStore(t *testing.T) {
+	t.Parallel()
+
+	runClientRepositoryTests(t, func(t *testing.T) storetest.Stores {
+		return storetest.NewSQLite(t)
+	})
+}
+
+// TestMemoryClientStore проверяет, что MemoryClientStore проходит те же тесты, что и ClientStore
+func TestMemoryClientStore(t *testing.T) {
+	t.Parallel()
+
+	runClientRepositoryTests(t, func(t *testing.T) storetest.Stores {
+		return storetest.NewMemory()
+	})
+}
+
+// testClientCreateGetUpdate проверяет создание, получение и изменение клиента
+func testClientCreateGetUpdate(t *testing.T, s storetest.Stores) {
+	ctx := context.Background()
+
+	// create
+	client := storetest.Client().Name("ООО Ромашка").Phone("+79991234567").Email("info@example.com").Add(t, s.Clients)
+	require.NotEmpty(t, client.ID)
+
+	// get
+	stored, err := s.Clients.Get(ctx, client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, client, stored)
+
+	// update
+	client.Name = "ООО Ландыш"
+	client.Phone = ""
+	client.UpdatedAt = clocktest.Epoch.Add(time.Hour)
+	require.NoError(t, s.Clients.Update(ctx, client))
+
+	stored, err = s.Clients.Get(ctx, client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, client, stored)
+
+	// несуществующий клиент
+	_, err = s.Clients.Get(ctx, client.ID+1)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	client.ID++
+	assert.ErrorIs(t, s.Clients.Update(ctx, client), errors.ErrNotFound)
+}
+
+// testClientList проверяет условия выборки и постраничный обход клиентов
+func testClientList(t *testing.T, s storetest.Stores) {
+	ctx := context.Background()
+
+	first := storetest.Client().Name("Иванов").Add(t, s.Clients)
+	second := storetest.Client().Name("Петров").Add(t, s.Clients)
+	inactive := storetest.Client().Name("Иванова").Inactive().Add(t, s.Clients)
+	third := storetest.Client().Name("Сидоров").Add(t, s.Clients)
+
+	ids := func(f models.ClientFilter) []int {
+		clients, err := s.Clients.List(ctx, f)
+		require.NoError(t, err)
+		res := make([]int, 0, len(clients))
+		for _, c := range clients {
+			res = append(res, c.ID)
+		}
+		return res
+	}
+
+	// по умолчанию деактивированные клиенты не выводятся
+	assert.Equal(t, []int{first.ID, second.ID, third.ID}, ids(models.ClientFilter{}))
+	assert.Equal(t, []int{first.ID, second.ID, inactive.ID, third.ID}, ids(models.ClientFilter{IncludeInactive: true}))
+
+	// по подстроке имени
+	assert.Equal(t, []int{first.ID, inactive.ID}, ids(models.ClientFilter{NameContains: "Иванов", IncludeInactive: true}))
+
+	// постранично
+	assert.Equal(t, []int{first.ID, second.ID}, ids(models.ClientFilter{Limit: 2}))
+	assert.Equal(t, []int{third.ID}, ids(models.ClientFilter{AfterID: second.ID, Limit: 2}))
+
+	// некорректные условия
+	for _, f := range []models.ClientFilter{{Limit: -1}, {Limit: store.MaxListLimit + 1}, {AfterID: -1}} {
+		_, err := s.Clients.List(ctx, f)
+		assert.ErrorIs(t, err, errors.ErrValidation, "%+v", f)
+	}
+}
+
+// testClientDeactivate проверяет деактивацию клиента
+func testClientDeactivate(t *testing.T, s storetest.Stores) {
+	ctx := context.Background()
+
+	client := storetest.Client().Add(t, s.Clients)
+
+	at := clocktest.Epoch.Add(time.Hour)
+	require.NoError(t, s.Clients.Deactivate(ctx, client.ID, at))
+
+	stored, err := s.Clients.Get(ctx, client.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.Active)
+	assert.Equal(t, at, stored.UpdatedAt)
+
+	// повторная деактивация не считается ошибкой
+	require.NoError(t, s.Clients.Deactivate(ctx, client.ID, at))
+
+	assert.ErrorIs(t, s.Clients.Deactivate(ctx, client.ID+1, at), errors.ErrNotFound)
+}
+
+// testParcelForeignKey проверяет, что посылку нельзя сохранить для несуществующего клиента
+func testParcelForeignKey(t *testing.T, s storetest.Stores) {
+	ctx := context.Background()
+
+	client := storetest.Client().Add(t, s.Clients)
+
+	_, err := s.Parcels.Add(ctx, storetest.Parcel().Client(client.ID+1).Build())
+	require.Error(t, err)
+
+	// посылку существующего клиента сохранить можно
+	storetest.Parcel().Client(client.ID).Add(t, s.Parcels)
+}
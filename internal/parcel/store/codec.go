@@ -0,0 +1,286 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/address"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+)
+
+// в файле собрано преобразование моментов времени и адресов между models и БД.
+// В SQLite нет типа для даты и времени, поэтому момент хранится текстом
+// в UTC в формате sqliteTimeLayout с миллисекундами: строки одной длины
+// сравниваются и сортируются так же, как сами моменты времени.
+// В PostgreSQL используется timestamptz, и драйвер pgx передает time.Time как есть.
+// Адрес доставки (адрес получателя) хранится в двух столбцах: address - одной строкой
+// (models.Address.String), по ней ищет подстроку ParcelFilter.AddressContains,
+// и address_fields - по частям в JSON. Адреса отправителя и возврата хранятся только в JSON
+
+// sqliteTimeLayout - формат моментов времени в SQLite
+const sqliteTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// parcelColumns - столбцы таблицы parcel в порядке, который ожидает scanParcel
+const parcelColumns = "number, client, status, tracking_code, sender_name, sender_phone, sender_address, " +
+	"recipient_name, recipient_phone, address, address_fields, return_address, " +
+	"service_level, weight, length, width, height, contents, declared_value, cost, " +
+	"created_at, updated_at, sent_at, delivered_at, deleted_at"
+
+// метод timeArg типа Dialect возвращает значение параметра запроса для момента t
+func (d Dialect) timeArg(t time.Time) any {
+	if d == SQLite {
+		return t.UTC().Format(sqliteTimeLayout)
+	}
+
+	return t.UTC()
+}
+
+// метод nullTimeArg типа Dialect возвращает значение параметра запроса
+// для необязательного момента t: NULL, если момент не задан
+func (d Dialect) nullTimeArg(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return d.timeArg(*t)
+}
+
+// определяем структурный тип timeScanner - приемник sql.Scanner для столбца с моментом времени,
+// который понимает как текст SQLite, так и time.Time драйвера pgx
+type timeScanner struct {
+	dst  *time.Time  // приемник обязательного момента
+	null **time.Time // приемник необязательного момента, NULL сохраняется как nil
+}
+
+// функция scanTime возвращает приемник для обязательного столбца с моментом времени
+func scanTime(dst *time.Time) sql.Scanner {
+	return timeScanner{dst: dst}
+}
+
+// функция scanNullTime возвращает приемник для необязательного столбца с моментом времени
+func scanNullTime(dst **time.Time) sql.Scanner {
+	return timeScanner{null: dst}
+}
+
+// Метод Scan типа timeScanner реализует sql.Scanner
+func (s timeScanner) Scan(src any) error {
+	var t time.Time
+	switch v := src.(type) {
+	case nil:
+		if s.null == nil {
+			return fmt.Errorf("пустое значение в обязательном столбце с датой и временем")
+		}
+		*s.null = nil
+		return nil
+	case time.Time:
+		t = v
+	case string:
+		parsed, err := parseStoredTime(v)
+		if err != nil {
+			return err
+		}
+		t = parsed
+	case []byte:
+		parsed, err := parseStoredTime(string(v))
+		if err != nil {
+			return err
+		}
+		t = parsed
+	default:
+		return fmt.Errorf("неподдерживаемый тип %T для даты и времени", src)
+	}
+
+	t = t.UTC()
+	if s.null != nil {
+		*s.null = &t
+	} else {
+		*s.dst = t
+	}
+
+	return nil
+}
+
+// функция parseStoredTime разбирает момент времени, сохраненный текстом.
+// Кроме основного формата принимается RFC 3339, в котором время записывалось раньше
+func parseStoredTime(v string) (time.Time, error) {
+	if t, err := time.Parse(sqliteTimeLayout, v); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("некорректная дата и время %q в БД", v)
+	}
+
+	return t, nil
+}
+
+// функция nullStringArg возвращает значение необязательного текстового столбца:
+// NULL, если строка пустая. Так хранятся отсутствующие коды отслеживания,
+// которые не должны нарушать уникальный индекс
+func nullStringArg(s string) any {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+// функция trackingCodeErr возвращает ErrTrackingCodeTaken, если ошибка err добавления
+// посылки с кодом code вызвана нарушением уникального индекса parcel_tracking_code_idx,
+// и саму err в остальных случаях
+func trackingCodeErr(err error, code string) error {
+	var sqliteErr *sqlite.Error
+	if stderrors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE &&
+		strings.Contains(sqliteErr.Error(), "parcel.tracking_code") {
+		return fmt.Errorf("%w: %s", ErrTrackingCodeTaken, code)
+	}
+
+	var pgErr *pgconn.PgError
+	if stderrors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "parcel_tracking_code_idx" {
+		return fmt.Errorf("%w: %s", ErrTrackingCodeTaken, code)
+	}
+
+	return err
+}
+
+// функция addressArg возвращает значение столбца с адресом a в JSON
+func addressArg(a models.Address) (string, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// функция nullAddressArg возвращает значение необязательного столбца с адресом в JSON:
+// NULL, если адрес не задан
+func nullAddressArg(a *models.Address) (any, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	return addressArg(*a)
+}
+
+// определяем структурный тип parcelAddresses - значения столбцов с адресами посылки
+type parcelAddresses struct {
+	sender    string // sender_address
+	recipient string // address_fields, однострочный адрес получателя - Recipient.Address.String()
+	ret       any    // return_address
+}
+
+// функция addressArgs возвращает значения столбцов с адресами посылки p
+func addressArgs(p models.Parcel) (parcelAddresses, error) {
+	var (
+		res parcelAddresses
+		err error
+	)
+	if res.sender, err = addressArg(p.Sender.Address); err != nil {
+		return res, err
+	}
+	if res.recipient, err = addressArg(p.Recipient.Address); err != nil {
+		return res, err
+	}
+	if res.ret, err = nullAddressArg(p.ReturnAddress); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// определяем структурный тип addressScanner - приемник sql.Scanner для столбца с адресом в JSON,
+// который понимает как текст SQLite, так и jsonb драйвера pgx
+type addressScanner struct {
+	dst  *models.Address  // приемник обязательного адреса, NULL читается как пустой адрес
+	null **models.Address // приемник необязательного адреса, NULL сохраняется как nil
+}
+
+// функция scanAddress возвращает приемник для столбца с адресом
+func scanAddress(dst *models.Address) sql.Scanner {
+	return addressScanner{dst: dst}
+}
+
+// функция scanNullAddress возвращает приемник для необязательного столбца с адресом
+func scanNullAddress(dst **models.Address) sql.Scanner {
+	return addressScanner{null: dst}
+}
+
+// Метод Scan типа addressScanner реализует sql.Scanner
+func (s addressScanner) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		if s.null != nil {
+			*s.null = nil
+		} else {
+			*s.dst = models.Address{}
+		}
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("неподдерживаемый тип %T для адреса", src)
+	}
+
+	var a models.Address
+	if err := json.Unmarshal(data, &a); err != nil {
+		return fmt.Errorf("некорректный адрес %q в БД: %w", data, err)
+	}
+
+	if s.null != nil {
+		*s.null = &a
+	} else {
+		*s.dst = a
+	}
+
+	return nil
+}
+
+// определяем тип rowScanner - общий метод *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// функция scanParcel читает посылку из строки результата со столбцами parcelColumns.
+// У посылок, сохраненных до появления столбца address_fields, части адреса доставки
+// получаются разбором однострочного адреса address.Parse
+func scanParcel(row rowScanner) (models.Parcel, error) {
+	var (
+		p      models.Parcel
+		code   sql.NullString
+		line   string
+		fields *models.Address
+	)
+	err := row.Scan(&p.Number, &p.Client, &p.Status, &code,
+		&p.Sender.Name, &p.Sender.Phone, scanAddress(&p.Sender.Address),
+		&p.Recipient.Name, &p.Recipient.Phone, &line, scanNullAddress(&fields),
+		scanNullAddress(&p.ReturnAddress),
+		&p.ServiceLevel, &p.Weight, &p.Dimensions.Length, &p.Dimensions.Width, &p.Dimensions.Height,
+		&p.Contents, &p.DeclaredValue, &p.Cost,
+		scanTime(&p.CreatedAt), scanTime(&p.UpdatedAt), scanNullTime(&p.SentAt), scanNullTime(&p.DeliveredAt),
+		scanNullTime(&p.DeletedAt))
+	if err != nil {
+		return p, err
+	}
+
+	p.TrackingCode = code.String
+	if fields != nil {
+		p.Recipient.Address = *fields
+	} else {
+		p.Recipient.Address = address.Parse(line)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,376 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// в файле собрано общее для всех хранилищ построение списка посылок:
+// проверка условий выборки, курсор страницы и SQL-запрос с условиями и сортировкой.
+// Страницы строятся по ключу сортировки (keyset pagination): курсор хранит ключ
+// последней посылки страницы, и следующая страница начинается строго после него,
+// поэтому запрос не пропускает строки через OFFSET и не зависит от размера выборки
+
+// размер страницы списка посылок
+const (
+	DefaultListLimit = 100  // размер страницы, если он не задан
+	MaxListLimit     = 1000 // наибольший допустимый размер страницы
+)
+
+// определяем структурный тип listCursor - содержимое курсора страницы:
+// порядок сортировки и ключ последней посылки предыдущей страницы
+type listCursor struct {
+	OrderBy   string    `json:"o"`
+	Desc      bool      `json:"d,omitempty"`
+	Number    int       `json:"n"`
+	CreatedAt time.Time `json:"c"`
+}
+
+// функция normalizeFilter проверяет условия выборки и заполняет значения по умолчанию,
+// некорректные условия возвращаются как errors.ValidationError
+// Параметры
+// f - условия выборки
+func normalizeFilter(f models.ParcelFilter) (models.ParcelFilter, error) {
+	switch f.OrderBy {
+	case "":
+		f.OrderBy = models.OrderByNumber
+	case models.OrderByNumber, models.OrderByCreatedAt:
+	default:
+		return f, errors.Validation("order_by", fmt.Sprintf("ожидается %s или %s, получено %q",
+			models.OrderByNumber, models.OrderByCreatedAt, f.OrderBy))
+	}
+
+	switch {
+	case f.Limit < 0:
+		return f, errors.Validation("limit", "размер страницы не может быть отрицательным")
+	case f.Limit == 0:
+		f.Limit = DefaultListLimit
+	case f.Limit > MaxListLimit:
+		return f, errors.Validation("limit", fmt.Sprintf("размер страницы не может превышать %d", MaxListLimit))
+	}
+
+	if !f.CreatedFrom.IsZero() && !f.CreatedTo.IsZero() && !f.CreatedFrom.Before(f.CreatedTo) {
+		return f, errors.Validation("created_to", "конец периода должен быть позже его начала")
+	}
+
+	return f, nil
+}
+
+// функция decodeCursor разбирает курсор страницы.
+// Курсор, выданный для другого порядка сортировки, считается некорректным
+// Параметры
+// f - условия выборки после normalizeFilter
+func decodeCursor(f models.ParcelFilter) (*listCursor, error) {
+	if f.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return nil, errors.Validation("cursor", "некорректный курсор")
+	}
+
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.Validation("cursor", "некорректный курсор")
+	}
+
+	if c.OrderBy != f.OrderBy || c.Desc != f.Desc {
+		return nil, errors.Validation("cursor", "курсор выдан для другого порядка сортировки")
+	}
+
+	return &c, nil
+}
+
+// функция encodeCursor возвращает курсор страницы, следующей за посылкой p
+// Параметры
+// f - условия выборки после normalizeFilter
+// p - последняя посылка текущей страницы
+func encodeCursor(f models.ParcelFilter, p models.Parcel) string {
+	c := listCursor{OrderBy: f.OrderBy, Desc: f.Desc, Number: p.Number}
+	if f.OrderBy == models.OrderByCreatedAt {
+		c.CreatedAt = p.CreatedAt
+	}
+
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// функция newPage формирует страницу из посылок, выбранных с запасом в одну строку:
+// если строк больше размера страницы, есть следующая страница
+// Параметры
+// f - условия выборки после normalizeFilter
+// parcels - не более f.Limit+1 посылок в порядке сортировки
+func newPage(f models.ParcelFilter, parcels []models.Parcel) models.ParcelPage {
+	if len(parcels) <= f.Limit {
+		return models.ParcelPage{Parcels: parcels}
+	}
+
+	parcels = parcels[:f.Limit]
+
+	return models.ParcelPage{Parcels: parcels, NextCursor: encodeCursor(f, parcels[len(parcels)-1])}
+}
+
+// определяем структурный тип listQuery - построитель запроса списка посылок
+// с параметрами в синтаксисе диалекта
+type listQuery struct {
+	dialect    Dialect
+	conditions []string
+	args       []any
+}
+
+// метод arg типа listQuery добавляет параметр запроса и возвращает его обозначение в тексте запроса
+func (q *listQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	if q.dialect == Postgres {
+		return fmt.Sprintf("$%d", len(q.args))
+	}
+
+	return "?"
+}
+
+// функция buildListQuery возвращает запрос списка посылок и его параметры.
+// Выбирается f.Limit+1 строка, чтобы newPage определил наличие следующей страницы
+// Параметры
+// dialect - диалект SQL
+// f - условия выборки после normalizeFilter
+// c - курсор страницы или nil для первой страницы
+func buildListQuery(dialect Dialect, f models.ParcelFilter, c *listCursor) (string, []any) {
+	q, query := buildSelectQuery(dialect, f, c)
+	query += " LIMIT " + q.arg(f.Limit+1)
+
+	return query, q.args
+}
+
+// функция buildSelectQuery возвращает построитель и текст запроса посылок,
+// удовлетворяющих условиям f, в порядке сортировки f, без ограничения числа строк
+// Параметры
+// dialect - диалект SQL
+// f - условия выборки после normalizeFilter
+// c - курсор страницы или nil, чтобы выбрать посылки с начала
+func buildSelectQuery(dialect Dialect, f models.ParcelFilter, c *listCursor) (*listQuery, string) {
+	q := &listQuery{dialect: dialect}
+
+	if f.Client != 0 {
+		q.conditions = append(q.conditions, "client = "+q.arg(f.Client))
+	}
+
+	if !f.IncludeDeleted {
+		q.conditions = append(q.conditions, "deleted_at IS NULL")
+	}
+
+	if len(f.Statuses) > 0 {
+		placeholders := make([]string, 0, len(f.Statuses))
+		for _, status := range f.Statuses {
+			placeholders = append(placeholders, q.arg(status))
+		}
+		q.conditions = append(q.conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	if !f.CreatedFrom.IsZero() {
+		q.conditions = append(q.conditions, "created_at >= "+q.arg(dialect.timeArg(f.CreatedFrom)))
+	}
+
+	if !f.CreatedTo.IsZero() {
+		q.conditions = append(q.conditions, "created_at < "+q.arg(dialect.timeArg(f.CreatedTo)))
+	}
+
+	// поиск подстроки без LIKE, чтобы символы % и _ в подстроке не были шаблоном
+	if f.AddressContains != "" {
+		fn := "instr(address, %s) > 0"
+		if dialect == Postgres {
+			fn = "strpos(address, %s) > 0"
+		}
+		q.conditions = append(q.conditions, fmt.Sprintf(fn, q.arg(f.AddressContains)))
+	}
+
+	if f.RecipientPhone != "" {
+		q.conditions = append(q.conditions, "recipient_phone = "+q.arg(f.RecipientPhone))
+	}
+
+	// ключ сортировки: номер или пара (дата создания, номер)
+	cmp, dir := ">", "ASC"
+	if f.Desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	if c != nil {
+		if f.OrderBy == models.OrderByCreatedAt {
+			createdAt := q.arg(dialect.timeArg(c.CreatedAt))
+			q.conditions = append(q.conditions, fmt.Sprintf("(created_at %s %s OR (created_at = %s AND number %s %s))",
+				cmp, createdAt, q.arg(dialect.timeArg(c.CreatedAt)), cmp, q.arg(c.Number)))
+		} else {
+			q.conditions = append(q.conditions, fmt.Sprintf("number %s %s", cmp, q.arg(c.Number)))
+		}
+	}
+
+	query := "SELECT " + parcelColumns + " FROM parcel"
+	if len(q.conditions) > 0 {
+		query += " WHERE " + strings.Join(q.conditions, " AND ")
+	}
+
+	if f.OrderBy == models.OrderByCreatedAt {
+		query += fmt.Sprintf(" ORDER BY created_at %s, number %s", dir, dir)
+	} else {
+		query += " ORDER BY number " + dir
+	}
+
+	return q, query
+}
+
+// функция matchFilter проверяет, что посылка удовлетворяет условиям выборки f
+// и следует за курсором c. Используется хранилищем в памяти
+func matchFilter(p models.Parcel, f models.ParcelFilter, c *listCursor) bool {
+	if f.Client != 0 && p.Client != f.Client {
+		return false
+	}
+
+	if !f.IncludeDeleted && p.DeletedAt != nil {
+		return false
+	}
+
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, status := range f.Statuses {
+			if p.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if !f.CreatedFrom.IsZero() && p.CreatedAt.Before(f.CreatedFrom) {
+		return false
+	}
+
+	if !f.CreatedTo.IsZero() && !p.CreatedAt.Before(f.CreatedTo) {
+		return false
+	}
+
+	if !strings.Contains(p.Recipient.Address.String(), f.AddressContains) {
+		return false
+	}
+
+	if f.RecipientPhone != "" && p.Recipient.Phone != f.RecipientPhone {
+		return false
+	}
+
+	if c != nil {
+		key := models.Parcel{Number: c.Number, CreatedAt: c.CreatedAt}
+		return parcelLess(key, p, f)
+	}
+
+	return true
+}
+
+// функция parcelLess сообщает, идет ли посылка a раньше посылки b
+// в порядке сортировки f. Используется хранилищем в памяти
+func parcelLess(a models.Parcel, b models.Parcel, f models.ParcelFilter) bool {
+	less := a.Number < b.Number
+	if f.OrderBy == models.OrderByCreatedAt && !a.CreatedAt.Equal(b.CreatedAt) {
+		less = a.CreatedAt.Before(b.CreatedAt)
+	}
+
+	if f.Desc {
+		return !less && a.Number != b.Number
+	}
+
+	return less
+}
+
+// функция listParcels выполняет запрос списка посылок в БД диалекта dialect.
+// Общая реализация метода List для ParcelStore и PostgresStore
+// Параметры
+// ctx - контекст запроса
+// db - БД или транзакция
+// dialect - диалект SQL
+// f - условия выборки
+func listParcels(ctx context.Context, db dbtx, dialect Dialect, f models.ParcelFilter) (models.ParcelPage, error) {
+	f, err := normalizeFilter(f)
+	if err != nil {
+		return models.ParcelPage{}, err
+	}
+
+	c, err := decodeCursor(f)
+	if err != nil {
+		return models.ParcelPage{}, err
+	}
+
+	query, args := buildListQuery(dialect, f, c)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.ParcelPage{}, err
+	}
+
+	defer rows.Close()
+
+	var res = make([]models.Parcel, 0, f.Limit+1)
+	for rows.Next() {
+		p, err := scanParcel(rows)
+		if err != nil {
+			return models.ParcelPage{}, err
+		}
+		res = append(res, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.ParcelPage{}, err
+	}
+
+	return newPage(f, res), nil
+}
+
+// функция iterateFilter возвращает условия выборки для обхода посылок методом Iterate:
+// размер страницы и курсор при обходе не используются
+func iterateFilter(f models.ParcelFilter) (models.ParcelFilter, error) {
+	f.Limit, f.Cursor = 0, ""
+
+	return normalizeFilter(f)
+}
+
+// функция iterateParcels передает в fn посылки, удовлетворяющие условиям f, в порядке сортировки f.
+// Посылки читаются из результата запроса по одной, без загрузки всей выборки в память.
+// Общая реализация метода Iterate для ParcelStore и PostgresStore
+// Параметры
+// ctx - контекст запроса
+// db - БД или транзакция
+// dialect - диалект SQL
+// f - условия выборки
+// fn - функция, которая получает посылки; ошибка fn прекращает обход и возвращается
+func iterateParcels(ctx context.Context, db dbtx, dialect Dialect, f models.ParcelFilter, fn func(models.Parcel) error) error {
+	f, err := iterateFilter(f)
+	if err != nil {
+		return err
+	}
+
+	q, query := buildSelectQuery(dialect, f, nil)
+	rows, err := db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanParcel(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
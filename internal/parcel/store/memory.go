@@ -0,0 +1,821 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// определяем структурный тип MemoryStore - хранилище посылок в памяти процесса.
+// Повторяет поведение ParcelStore (в том числе возвращаемые ошибки)
+// и предназначено для тестов сервиса, которым не нужна БД.
+// Отмененный контекст приводит к ошибке ctx.Err() до выполнения операции
+type MemoryStore struct {
+	mu   *sync.Mutex // защищает data при конкурентном доступе; nil внутри WithTx, где блокировка уже захвачена
+	data *memoryData // посылки, история и клиенты
+}
+
+// определяем структурный тип memoryData - данные MemoryStore
+type memoryData struct {
+	parcels map[int]models.Parcel                    // посылки по номеру
+	last    int                                      // последний выданный номер посылки, аналог автоинкремента
+	events  []models.StatusEvent                     // история смены статусов всех посылок в порядке записи
+	event   int                                      // последний выданный идентификатор события
+	clients map[int]models.Client                    // клиенты по идентификатору
+	client  int                                      // последний выданный идентификатор клиента
+	keys    map[idempotencyKey]models.IdempotencyKey // ключи идемпотентности по клиенту и ключу
+}
+
+// определяем структурный тип idempotencyKey - первичный ключ ключа идемпотентности
+type idempotencyKey struct {
+	client int    // идентификатор клиента
+	key    string // ключ, переданный клиентом
+}
+
+// функция NewMemoryStore для создания нового пустого экземпляра MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{mu: &sync.Mutex{}, data: &memoryData{
+		parcels: make(map[int]models.Parcel),
+		clients: make(map[int]models.Client),
+		keys:    make(map[idempotencyKey]models.IdempotencyKey),
+	}}
+}
+
+// Метод Clients типа MemoryStore возвращает хранилище клиентов,
+// работающее с теми же данными и той же блокировкой, что и хранилище посылок
+func (s *MemoryStore) Clients() *MemoryClientStore {
+	return &MemoryClientStore{mu: s.mu, data: s.data}
+}
+
+// метод lock типа MemoryStore захватывает блокировку и возвращает функцию ее освобождения.
+// Внутри WithTx блокировка уже захвачена, и lock ничего не делает
+func (s *MemoryStore) lock() func() {
+	if s.mu == nil {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// метод clone типа memoryData возвращает копию данных,
+// из которой WithTx восстанавливает состояние при откате
+func (d *memoryData) clone() memoryData {
+	c := memoryData{
+		parcels: make(map[int]models.Parcel, len(d.parcels)),
+		last:    d.last,
+		event:   d.event,
+		clients: make(map[int]models.Client, len(d.clients)),
+		client:  d.client,
+		keys:    make(map[idempotencyKey]models.IdempotencyKey, len(d.keys)),
+	}
+	for number, p := range d.parcels {
+		c.parcels[number] = p
+	}
+	c.events = append([]models.StatusEvent(nil), d.events...)
+	for id, client := range d.clients {
+		c.clients[id] = client
+	}
+	for pk, k := range d.keys {
+		c.keys[pk] = k
+	}
+
+	return c
+}
+
+// Метод WithTx типа MemoryStore выполняет fn под блокировкой хранилища:
+// параллельные операции ждут ее завершения, а если fn вернула ошибку,
+// все сделанные в ней изменения отменяются
+// Параметры
+// ctx - контекст запроса
+// fn - функция, выполняющая операции с хранилищем tx
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(tx ParcelRepository) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// вложенный вызов выполняется в уже открытой транзакции
+	if s.mu == nil {
+		return fn(s)
+	}
+
+	defer s.lock()()
+
+	snapshot := s.data.clone()
+	if err := fn(&MemoryStore{data: s.data}); err != nil {
+		*s.data = snapshot
+		return err
+	}
+
+	return nil
+}
+
+// Метод Add типа MemoryStore сохраняет посылку
+// и возвращает присвоенный ей номер. Как и внешний ключ parcel.client в БД,
+// хранилище не принимает посылку несуществующего клиента
+// Параметры
+// ctx - контекст запроса
+// p - экземпляр типа Parcel
+func (s *MemoryStore) Add(ctx context.Context, p models.Parcel) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	defer s.lock()()
+
+	if _, ok := s.data.clients[p.Client]; !ok {
+		return 0, fmt.Errorf("нарушение внешнего ключа: клиент %d не существует", p.Client)
+	}
+	if _, ok := s.data.findTrackingCode(p.TrackingCode); ok {
+		return 0, fmt.Errorf("%w: %s", ErrTrackingCodeTaken, p.TrackingCode)
+	}
+
+	s.data.last++
+	p.Number = s.data.last
+	if p.UpdatedAt.IsZero() {
+		p.UpdatedAt = p.CreatedAt
+	}
+	s.data.parcels[p.Number] = p
+
+	return p.Number, nil
+}
+
+// Метод AddBatch типа MemoryStore сохраняет посылки и возвращает присвоенные им номера.
+// Как и в ParcelStore, если какую-либо посылку сохранить не удалось, не сохраняется ни одна
+// Параметры
+// ctx - контекст запроса
+// parcels - новые посылки
+func (s *MemoryStore) AddBatch(ctx context.Context, parcels []models.Parcel) ([]int, error) {
+	numbers := make([]int, 0, len(parcels))
+	err := s.WithTx(ctx, func(tx ParcelRepository) error {
+		for _, p := range parcels {
+			number, err := tx.Add(ctx, p)
+			if err != nil {
+				return err
+			}
+			numbers = append(numbers, number)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return numbers, nil
+}
+
+// Метод Get типа MemoryStore возвращает посылку по номеру,
+// для несуществующей посылки возвращается errors.NotFoundError, как и у ParcelStore
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+func (s *MemoryStore) Get(ctx context.Context, number int) (models.Parcel, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Parcel{}, err
+	}
+
+	defer s.lock()()
+
+	p, ok := s.data.parcel(number)
+	if !ok {
+		return models.Parcel{}, errors.NotFound(entityParcel, number)
+	}
+
+	return p, nil
+}
+
+// метод parcel типа memoryData возвращает неудаленную посылку по номеру
+func (d *memoryData) parcel(number int) (models.Parcel, bool) {
+	p, ok := d.parcels[number]
+	if !ok || p.DeletedAt != nil {
+		return models.Parcel{}, false
+	}
+
+	return p, true
+}
+
+// Метод GetByTrackingCode типа MemoryStore возвращает посылку по коду отслеживания,
+// для неизвестного кода возвращается errors.NotFoundError
+// Параметры
+// ctx - контекст запроса
+// code - код отслеживания
+func (s *MemoryStore) GetByTrackingCode(ctx context.Context, code string) (models.Parcel, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Parcel{}, err
+	}
+
+	defer s.lock()()
+
+	p, ok := s.data.findTrackingCode(code)
+	if !ok || p.DeletedAt != nil {
+		return models.Parcel{}, errors.NotFound(entityParcel, code)
+	}
+
+	return p, nil
+}
+
+// метод findTrackingCode типа memoryData ищет посылку с кодом отслеживания code,
+// в том числе удаленную: как и уникальный индекс БД, код удаленной посылки занят до Purge.
+// Как и NULL в уникальном индексе БД, пустой код не совпадает ни с одним другим
+func (d *memoryData) findTrackingCode(code string) (models.Parcel, bool) {
+	if code == "" {
+		return models.Parcel{}, false
+	}
+	for _, p := range d.parcels {
+		if p.TrackingCode == code {
+			return p, true
+		}
+	}
+
+	return models.Parcel{}, false
+}
+
+// Метод GetByClient типа MemoryStore возвращает все посылки клиента в порядке номеров,
+// как и ParcelStore.GetByClient: порядок обхода словаря случаен
+// Параметры
+// ctx - контекст запроса
+// client - идентификатор клиента
+func (s *MemoryStore) GetByClient(ctx context.Context, client int) ([]models.Parcel, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer s.lock()()
+
+	var res = make([]models.Parcel, 0)
+	for _, p := range s.data.parcels {
+		if p.Client == client && p.DeletedAt == nil {
+			res = append(res, p)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Number < res[j].Number })
+
+	return res, nil
+}
+
+// Метод List типа MemoryStore
+// возвращает страницу посылок, удовлетворяющих условиям f, как и ParcelStore.List
+// Параметры
+// ctx - контекст запроса
+// f - условия выборки, сортировка и курсор страницы
+func (s *MemoryStore) List(ctx context.Context, f models.ParcelFilter) (models.ParcelPage, error) {
+	if err := ctx.Err(); err != nil {
+		return models.ParcelPage{}, err
+	}
+
+	f, err := normalizeFilter(f)
+	if err != nil {
+		return models.ParcelPage{}, err
+	}
+
+	c, err := decodeCursor(f)
+	if err != nil {
+		return models.ParcelPage{}, err
+	}
+
+	res := s.selectParcels(f, c)
+	if len(res) > f.Limit+1 {
+		res = res[:f.Limit+1]
+	}
+
+	return newPage(f, res), nil
+}
+
+// Метод Iterate типа MemoryStore
+// передает в fn все посылки, удовлетворяющие условиям f, как и ParcelStore.Iterate.
+// Выборка копируется до обхода, поэтому fn может обращаться к хранилищу
+// Параметры
+// ctx - контекст запроса
+// f - условия выборки и сортировка
+// fn - функция, которая получает посылки; ошибка fn прекращает обход и возвращается
+func (s *MemoryStore) Iterate(ctx context.Context, f models.ParcelFilter, fn func(models.Parcel) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f, err := iterateFilter(f)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range s.selectParcels(f, nil) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// метод selectParcels типа MemoryStore возвращает посылки,
+// удовлетворяющие условиям f и следующие за курсором c, в порядке сортировки f
+func (s *MemoryStore) selectParcels(f models.ParcelFilter, c *listCursor) []models.Parcel {
+	defer s.lock()()
+
+	var res = make([]models.Parcel, 0)
+	for _, p := range s.data.parcels {
+		if matchFilter(p, f, c) {
+			res = append(res, p)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return parcelLess(res[i], res[j], f) })
+
+	return res
+}
+
+// Метод SetStatus типа MemoryStore изменяет статус посылки
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// status - новый статус посылки
+// at - момент изменения
+func (s *MemoryStore) SetStatus(ctx context.Context, number int, status string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	p, ok := s.data.parcel(number)
+	if !ok {
+		return errors.NotFound(entityParcel, number)
+	}
+
+	setStatus(&p, status, at)
+	s.data.parcels[number] = p
+
+	return nil
+}
+
+// метод SetStatusBatch типа MemoryStore
+// переводит посылки в статус to, если их текущий статус равен ожидаемому,
+// как и ParcelStore.SetStatusBatch
+// Параметры
+// ctx - контекст запроса
+// changes - номера посылок и их ожидаемые текущие статусы
+// to - новый статус посылок
+// at - момент изменения
+func (s *MemoryStore) SetStatusBatch(ctx context.Context, changes []StatusChange, to string, at time.Time) ([]error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer s.lock()()
+
+	failures := make([]error, len(changes))
+	for i, c := range changes {
+		p, ok := s.data.parcel(c.Number)
+		switch {
+		case !ok:
+			failures[i] = errors.NotFound(entityParcel, c.Number)
+		case p.Status != c.From:
+			failures[i] = errors.InvalidState(c.Number, p.Status, opSetStatus)
+		default:
+			setStatus(&p, to, at)
+			s.data.parcels[c.Number] = p
+		}
+	}
+
+	return failures, nil
+}
+
+// Метод SetAddress типа MemoryStore изменяет адрес посылки,
+// если ее статус равен `зарегистрирована`
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// address - новый адрес
+// at - момент изменения
+func (s *MemoryStore) SetAddress(ctx context.Context, number int, address models.Address, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	if err := s.checkRegistered(number, opSetAddress); err != nil {
+		return err
+	}
+
+	p := s.data.parcels[number]
+	p.Recipient.Address = address
+	p.UpdatedAt = at
+	s.data.parcels[number] = p
+
+	return nil
+}
+
+// Метод Delete типа MemoryStore удаляет посылку, отмечая момент удаления,
+// если ее статус равен `зарегистрирована`
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// at - момент удаления
+func (s *MemoryStore) Delete(ctx context.Context, number int, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	if err := s.checkRegistered(number, opDelete); err != nil {
+		return err
+	}
+
+	p := s.data.parcels[number]
+	p.DeletedAt = &at
+	p.UpdatedAt = at
+	s.data.parcels[number] = p
+
+	return nil
+}
+
+// Метод Restore типа MemoryStore восстанавливает удаленную посылку
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// at - момент восстановления
+func (s *MemoryStore) Restore(ctx context.Context, number int, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	p, ok := s.data.parcels[number]
+	if !ok {
+		return errors.NotFound(entityParcel, number)
+	}
+
+	if p.DeletedAt == nil {
+		return errors.InvalidState(number, p.Status, opRestore)
+	}
+
+	p.DeletedAt = nil
+	p.UpdatedAt = at
+	s.data.parcels[number] = p
+
+	return nil
+}
+
+// Метод Purge типа MemoryStore окончательно удаляет посылки,
+// удаленные раньше момента before, вместе с историей и ключами идемпотентности
+// Параметры
+// ctx - контекст запроса
+// before - граница момента удаления
+func (s *MemoryStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	defer s.lock()()
+
+	purged := make(map[int]bool)
+	for number, p := range s.data.parcels {
+		if p.DeletedAt != nil && p.DeletedAt.Before(before) {
+			purged[number] = true
+			delete(s.data.parcels, number)
+		}
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+
+	// история и ключи идемпотентности удаляются вместе с посылкой, как по ON DELETE CASCADE в БД
+	events := s.data.events[:0]
+	for _, ev := range s.data.events {
+		if !purged[ev.Parcel] {
+			events = append(events, ev)
+		}
+	}
+	s.data.events = events
+	for pk, k := range s.data.keys {
+		if purged[k.Parcel] {
+			delete(s.data.keys, pk)
+		}
+	}
+
+	return len(purged), nil
+}
+
+// метод CompareAndSetStatus типа MemoryStore
+// переводит посылку из статуса from в статус to, если ее текущий статус равен from
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// from - ожидаемый текущий статус
+// to - новый статус
+// at - момент изменения
+func (s *MemoryStore) CompareAndSetStatus(ctx context.Context, number int, from string, to string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	p, ok := s.data.parcel(number)
+	if !ok {
+		return errors.NotFound(entityParcel, number)
+	}
+
+	if p.Status != from {
+		return errors.InvalidState(number, p.Status, opSetStatus)
+	}
+
+	setStatus(&p, to, at)
+	s.data.parcels[number] = p
+
+	return nil
+}
+
+// метод AddEvent типа MemoryStore
+// записывает событие смены статуса в историю
+// Параметры
+// ctx - контекст запроса
+// ev - событие смены статуса
+func (s *MemoryStore) AddEvent(ctx context.Context, ev models.StatusEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	s.data.event++
+	ev.ID = s.data.event
+	s.data.events = append(s.data.events, ev)
+
+	return nil
+}
+
+// метод AddEvents типа MemoryStore
+// записывает события в историю
+// Параметры
+// ctx - контекст запроса
+// events - события смены статуса
+func (s *MemoryStore) AddEvents(ctx context.Context, events []models.StatusEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	for _, ev := range events {
+		s.data.event++
+		ev.ID = s.data.event
+		s.data.events = append(s.data.events, ev)
+	}
+
+	return nil
+}
+
+// метод AddIdempotencyKey типа MemoryStore
+// сохраняет ключ идемпотентности, ключ, уже использованный клиентом, не принимается
+// Параметры
+// ctx - контекст запроса
+// k - ключ идемпотентности и посылка, зарегистрированная по нему
+func (s *MemoryStore) AddIdempotencyKey(ctx context.Context, k models.IdempotencyKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	pk := idempotencyKey{client: k.Client, key: k.Key}
+	if _, ok := s.data.keys[pk]; ok {
+		return fmt.Errorf("нарушение уникальности: ключ идемпотентности %q клиента %d уже используется", k.Key, k.Client)
+	}
+	if _, ok := s.data.parcels[k.Parcel]; !ok {
+		return fmt.Errorf("нарушение внешнего ключа: посылка %d не существует", k.Parcel)
+	}
+	s.data.keys[pk] = k
+
+	return nil
+}
+
+// Метод GetIdempotencyKey типа MemoryStore
+// возвращает ключ идемпотентности клиента или ошибку errors.NotFoundError
+// Параметры
+// ctx - контекст запроса
+// client - идентификатор клиента
+// key - ключ идемпотентности
+func (s *MemoryStore) GetIdempotencyKey(ctx context.Context, client int, key string) (models.IdempotencyKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.IdempotencyKey{}, err
+	}
+
+	defer s.lock()()
+
+	k, ok := s.data.keys[idempotencyKey{client: client, key: key}]
+	if !ok {
+		return models.IdempotencyKey{}, errors.NotFound(entityKey, key)
+	}
+
+	return k, nil
+}
+
+// Метод Events типа MemoryStore
+// возвращает историю смены статусов посылки в хронологическом порядке,
+// история удаленной посылки не видна, как и в ParcelStore.Events
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+func (s *MemoryStore) Events(ctx context.Context, number int) ([]models.StatusEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer s.lock()()
+
+	var res = make([]models.StatusEvent, 0)
+	if _, ok := s.data.parcel(number); !ok {
+		return res, nil
+	}
+	for _, ev := range s.data.events {
+		if ev.Parcel == number {
+			res = append(res, ev)
+		}
+	}
+
+	return res, nil
+}
+
+// метод checkRegistered типа MemoryStore проверяет, что посылка существует
+// и находится в статусе `зарегистрирована`. Вызывается под блокировкой хранилища
+// Параметры
+// number - номер посылки
+// op - название операции для текста ошибки
+func (s *MemoryStore) checkRegistered(number int, op string) error {
+	p, ok := s.data.parcel(number)
+	if !ok {
+		return errors.NotFound(entityParcel, number)
+	}
+
+	if p.Status != constants.ParcelStatusRegistered {
+		return errors.InvalidState(number, p.Status, op)
+	}
+
+	return nil
+}
+
+// функция setStatus переводит посылку p в статус status в момент at
+// и обновляет время изменения, отправки и доставки так же, как UPDATE в ParcelStore
+func setStatus(p *models.Parcel, status string, at time.Time) {
+	p.Status = status
+	p.UpdatedAt = at
+
+	switch {
+	case status == constants.ParcelStatusSent && p.SentAt == nil:
+		p.SentAt = &at
+	case status == constants.ParcelStatusDelivered:
+		p.DeliveredAt = &at
+	}
+}
+
+// определяем структурный тип MemoryClientStore - хранилище клиентов в памяти процесса,
+// повторяет поведение ClientStore. Создается методом MemoryStore.Clients
+type MemoryClientStore struct {
+	mu   *sync.Mutex // блокировка MemoryStore; nil внутри WithTx
+	data *memoryData // данные MemoryStore
+}
+
+// метод lock типа MemoryClientStore захватывает блокировку, см. MemoryStore.lock
+func (s *MemoryClientStore) lock() func() {
+	if s.mu == nil {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// Метод Create типа MemoryClientStore сохраняет клиента
+// и возвращает присвоенный ему идентификатор
+// Параметры
+// ctx - контекст запроса
+// c - экземпляр типа Client
+func (s *MemoryClientStore) Create(ctx context.Context, c models.Client) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	defer s.lock()()
+
+	s.data.client++
+	c.ID = s.data.client
+	if c.UpdatedAt.IsZero() {
+		c.UpdatedAt = c.CreatedAt
+	}
+	s.data.clients[c.ID] = c
+
+	return c.ID, nil
+}
+
+// Метод Get типа MemoryClientStore возвращает клиента по идентификатору
+// Параметры
+// ctx - контекст запроса
+// id - идентификатор клиента
+func (s *MemoryClientStore) Get(ctx context.Context, id int) (models.Client, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Client{}, err
+	}
+
+	defer s.lock()()
+
+	c, ok := s.data.clients[id]
+	if !ok {
+		return models.Client{}, errors.NotFound(entityClient, id)
+	}
+
+	return c, nil
+}
+
+// Метод Update типа MemoryClientStore изменяет имя и контакты клиента
+// Параметры
+// ctx - контекст запроса
+// c - клиент с новыми данными, UpdatedAt - момент изменения
+func (s *MemoryClientStore) Update(ctx context.Context, c models.Client) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	stored, ok := s.data.clients[c.ID]
+	if !ok {
+		return errors.NotFound(entityClient, c.ID)
+	}
+
+	stored.Name = c.Name
+	stored.Phone = c.Phone
+	stored.Email = c.Email
+	stored.UpdatedAt = c.UpdatedAt
+	s.data.clients[c.ID] = stored
+
+	return nil
+}
+
+// Метод List типа MemoryClientStore возвращает клиентов,
+// удовлетворяющих условиям f, как и ClientStore.List
+// Параметры
+// ctx - контекст запроса
+// f - условия выборки
+func (s *MemoryClientStore) List(ctx context.Context, f models.ClientFilter) ([]models.Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f, err := normalizeClientFilter(f)
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.lock()()
+
+	var res = make([]models.Client, 0)
+	for _, c := range s.data.clients {
+		if matchClientFilter(c, f) {
+			res = append(res, c)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	if len(res) > f.Limit {
+		res = res[:f.Limit]
+	}
+
+	return res, nil
+}
+
+// Метод Deactivate типа MemoryClientStore деактивирует клиента
+// Параметры
+// ctx - контекст запроса
+// id - идентификатор клиента
+// at - момент изменения
+func (s *MemoryClientStore) Deactivate(ctx context.Context, id int, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+
+	c, ok := s.data.clients[id]
+	if !ok {
+		return errors.NotFound(entityClient, id)
+	}
+
+	c.Active = false
+	c.UpdatedAt = at
+	s.data.clients[id] = c
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package store_test
+
+import (
+	// импортируем пакеты standard library
+	"testing"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store/storetest"
+)
+
+// TestMemoryStore проверяет, что MemoryStore проходит те же тесты, что и ParcelStore
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	runParcelRepositoryTests(t, func(t *testing.T) storetest.Stores {
+		return storetest.NewMemory()
+	})
+}
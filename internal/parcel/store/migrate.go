@@ -0,0 +1,457 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationsFS содержит SQL-файлы миграций, встроенные в бинарный файл.
+// Миграции каждой СУБД лежат в своем каталоге migrations/<диалект>,
+// имена файлов имеют вид <версия>_<название>.up.sql и <версия>_<название>.down.sql
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationsFS embed.FS
+
+// migrationQueries содержит запросы к таблице schema_migrations для каждого диалекта:
+// параметры передаются по порядку, но синтаксис у СУБД разный
+var migrationQueries = map[Dialect]struct {
+	lock    string // блокировка миграций до конца транзакции, см. lockMigrations
+	applied string // проверка, записана ли версия
+	insert  string // запись о примененной миграции
+	delete  string // удаление записи при откате
+}{
+	SQLite: {
+		applied: "SELECT COUNT(*) FROM schema_migrations WHERE version = ?",
+		insert: `INSERT INTO schema_migrations (version, name, applied_at)
+				 VALUES (?, ?, ?)`,
+		delete: "DELETE FROM schema_migrations WHERE version = ?",
+	},
+	Postgres: {
+		lock:    "SELECT pg_advisory_xact_lock($1)",
+		applied: "SELECT COUNT(*) FROM schema_migrations WHERE version = $1",
+		insert: `INSERT INTO schema_migrations (version, name, applied_at)
+				 VALUES ($1, $2, $3)`,
+		delete: "DELETE FROM schema_migrations WHERE version = $1",
+	},
+}
+
+// migrationLockKey - ключ рекомендательной блокировки PostgreSQL, которую держат транзакции миграций
+const migrationLockKey int64 = 0x70617263656c // "parcel"
+
+// определяем структурный тип Migration - одна версия схемы БД
+type Migration struct {
+	Version int    // номер версии, берется из префикса имени файла
+	Name    string // название миграции
+	Up      string // SQL для применения миграции
+	Down    string // SQL для отката миграции
+}
+
+// функция Migrations возвращает все встроенные миграции диалекта dialect,
+// отсортированные по возрастанию версии
+func Migrations(dialect Dialect) ([]Migration, error) {
+	if _, ok := migrationQueries[dialect]; !ok {
+		return nil, fmt.Errorf("миграции для диалекта %q не поддерживаются", dialect)
+	}
+
+	return loadMigrations(migrationsFS, "migrations/"+string(dialect))
+}
+
+// функция loadMigrations читает файлы миграций из каталога dir
+// и собирает их в слайс, отсортированный по версии
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// собираем миграции по номеру версии: у каждой версии есть up- и down-файл
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("миграция %s: неизвестное расширение файла", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("миграция %s: имя файла должно иметь вид <версия>_<название>", fileName)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("миграция %s: некорректный номер версии %q", fileName, prefix)
+		}
+
+		body, err := fs.ReadFile(fsys, dir+"/"+fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("миграция %d: разные названия %q и %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("миграция %d_%s: должны присутствовать up- и down-файлы", m.Version, m.Name)
+		}
+		res = append(res, *m)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+
+	return res, nil
+}
+
+// функция Migrate приводит схему БД к актуальной версии:
+// применяет по порядку все миграции, которые еще не записаны в таблицу schema_migrations.
+// Каждая миграция выполняется в отдельной транзакции вместе с записью о ее применении,
+// поэтому пустой файл SQLite после вызова Migrate содержит актуальную схему.
+// Несколько процессов могут вызвать Migrate одновременно: транзакции миграций выполняются
+// по очереди (см. lockMigrations), и версия, которую успел применить другой процесс, пропускается.
+// Миграции SQLite выполняются с выключенной проверкой внешних ключей, см. migrationConn
+// Параметры
+// ctx - контекст, отмена которого прерывает миграцию
+// db - указатель на БД
+// dialect - диалект SQL, определяет набор миграций
+func Migrate(ctx context.Context, db *sql.DB, dialect Dialect) error {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	applied, err := lockedVersions(ctx, db, dialect)
+	if err != nil {
+		return err
+	}
+
+	conn, release, err := migrationConn(ctx, db, dialect)
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		err := inTx(ctx, conn, func(tx *sql.Tx) error {
+			// версию мог применить другой процесс после чтения applied
+			if done, err := lockVersion(ctx, tx, dialect, m.Version); err != nil || done {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+
+			if err := checkForeignKeys(ctx, tx, dialect); err != nil {
+				return err
+			}
+
+			_, err := tx.ExecContext(ctx, migrationQueries[dialect].insert,
+				m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("применение миграции %d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// функция MigrateDown откатывает примененные миграции с версией больше target
+// в порядке убывания версий. MigrateDown(ctx, db, dialect, 0) откатывает все миграции.
+// Как и в Migrate, версия, которую уже откатил другой процесс, пропускается
+// Параметры
+// ctx - контекст, отмена которого прерывает миграцию
+// db - указатель на БД
+// dialect - диалект SQL, определяет набор миграций
+// target - версия схемы, к которой нужно вернуться
+func MigrateDown(ctx context.Context, db *sql.DB, dialect Dialect, target int) error {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	applied, err := lockedVersions(ctx, db, dialect)
+	if err != nil {
+		return err
+	}
+
+	conn, release, err := migrationConn(ctx, db, dialect)
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= target || !applied[m.Version] {
+			continue
+		}
+
+		err := inTx(ctx, conn, func(tx *sql.Tx) error {
+			if done, err := lockVersion(ctx, tx, dialect, m.Version); err != nil || !done {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+
+			if err := checkForeignKeys(ctx, tx, dialect); err != nil {
+				return err
+			}
+
+			_, err := tx.ExecContext(ctx, migrationQueries[dialect].delete, m.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("откат миграции %d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// функция SchemaVersion возвращает номер последней примененной миграции
+// (0, если ни одна миграция не применялась)
+// Параметры
+// ctx - контекст запроса
+// db - указатель на БД
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+
+	return version, nil
+}
+
+// функция lockedVersions возвращает множество примененных версий, как appliedVersions,
+// но под блокировкой миграций: параллельные процессы не создают schema_migrations одновременно
+// Параметры
+// ctx - контекст запроса
+// db - указатель на БД
+// dialect - диалект SQL
+func lockedVersions(ctx context.Context, db *sql.DB, dialect Dialect) (map[int]bool, error) {
+	var applied map[int]bool
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		if err := lockMigrations(ctx, tx, dialect); err != nil {
+			return err
+		}
+
+		var err error
+		applied, err = appliedVersions(ctx, tx)
+		return err
+	})
+
+	return applied, err
+}
+
+// функция lockMigrations захватывает в транзакции миграции tx блокировку миграций.
+// В PostgreSQL это рекомендательная блокировка migrationLockKey, которая снимается
+// при завершении транзакции, поэтому транзакции миграций разных процессов выполняются по очереди.
+// В SQLite запись в файл и так выполняет одна транзакция: соединения Open начинают транзакции
+// с блокировкой на запись (_txlock=immediate), а для остальных конфликт записи завершается ошибкой
+// Параметры
+// ctx - контекст запроса
+// tx - транзакция миграции
+// dialect - диалект SQL
+func lockMigrations(ctx context.Context, tx *sql.Tx, dialect Dialect) error {
+	if lock := migrationQueries[dialect].lock; lock != "" {
+		_, err := tx.ExecContext(ctx, lock, migrationLockKey)
+		return err
+	}
+
+	return nil
+}
+
+// функция lockVersion захватывает блокировку миграций (см. lockMigrations)
+// и возвращает, записана ли в schema_migrations версия version
+// Параметры
+// ctx - контекст запроса
+// tx - транзакция миграции
+// dialect - диалект SQL
+// version - проверяемая версия
+func lockVersion(ctx context.Context, tx *sql.Tx, dialect Dialect, version int) (bool, error) {
+	if err := lockMigrations(ctx, tx, dialect); err != nil {
+		return false, err
+	}
+
+	var n int
+	if err := tx.QueryRowContext(ctx, migrationQueries[dialect].applied, version).Scan(&n); err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// функция appliedVersions создает таблицу schema_migrations, если ее еще нет,
+// и возвращает множество примененных версий
+func appliedVersions(ctx context.Context, db dbtx) (map[int]bool, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+					   (
+						   version    INTEGER NOT NULL PRIMARY KEY,
+						   name       TEXT    NOT NULL,
+						   applied_at TEXT    NOT NULL
+					   )`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// функция migrationConn возвращает соединение, на котором выполняются миграции,
+// и функцию, возвращающую его в пул.
+// Чтобы пересоздать таблицу, на которую ссылаются внешние ключи, SQLite требует
+// выключить их проверку, а переключить ее внутри транзакции нельзя. Поэтому для SQLite
+// проверка выключается на отдельном соединении до начала транзакций миграций
+// и восстанавливается перед его возвратом в пул, а целостность данных после
+// каждой миграции проверяет checkForeignKeys
+func migrationConn(ctx context.Context, db *sql.DB, dialect Dialect) (*sql.Conn, func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if dialect != SQLite {
+		return conn, func() { conn.Close() }, nil
+	}
+
+	var enabled bool
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&enabled); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	release := func() {
+		if enabled {
+			// соединение с невосстановленной настройкой не должно вернуться в пул
+			if _, err := conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON"); err != nil {
+				conn.Raw(func(any) error { return driver.ErrBadConn })
+			}
+		}
+		conn.Close()
+	}
+
+	return conn, release, nil
+}
+
+// функция checkForeignKeys проверяет в транзакции миграции SQLite, что все внешние ключи
+// ссылаются на существующие строки. В PostgreSQL проверка внешних ключей не выключается
+func checkForeignKeys(ctx context.Context, tx *sql.Tx, dialect Dialect) error {
+	if dialect != SQLite {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	if rows.Next() {
+		var (
+			table, parent string
+			rowid         sql.NullInt64
+			fkid          int
+		)
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return err
+		}
+		return fmt.Errorf("строка %d таблицы %s ссылается на отсутствующую строку таблицы %s",
+			rowid.Int64, table, parent)
+	}
+
+	return rows.Err()
+}
+
+// txBeginner - общий метод *sql.DB и *sql.Conn, начинающий транзакцию
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// функция inTx выполняет fn в транзакции:
+// при ошибке транзакция откатывается, иначе фиксируется
+func inTx(ctx context.Context, db txBeginner, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,338 @@
+package store
+
+import (
+	// импортируем пакеты standard library
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/clock/clocktest"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+)
+
+// openEmptyDB открывает новый пустой файл SQLite во временном каталоге теста
+func openEmptyDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tracker.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// migratedParcel возвращает посылку для проверки схемы после миграций.
+// Построители storetest здесь недоступны: тесты миграций работают
+// с неэкспортируемыми функциями пакета и находятся внутри него
+func migratedParcel() models.Parcel {
+	return models.Parcel{
+		Client:       1000,
+		Status:       constants.ParcelStatusRegistered,
+		ServiceLevel: constants.ServiceLevelStandard,
+		Weight:       1000,
+		Recipient: models.Party{
+			Name:    "Получатель",
+			Address: models.Address{City: "Псков", Street: "ул. Лесная", House: "1"},
+		},
+		CreatedAt: clocktest.Epoch,
+		UpdatedAt: clocktest.Epoch,
+	}
+}
+
+// TestMigrateFreshDB проверяет, что пустая БД после Migrate
+// получает актуальную схему, с которой работает ParcelStore
+func TestMigrateFreshDB(t *testing.T) {
+	t.Parallel()
+
+	db := openEmptyDB(t)
+
+	require.NoError(t, Migrate(context.Background(), db, SQLite))
+
+	// версия схемы равна последней встроенной миграции
+	migrations, err := Migrations(SQLite)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	version, err := SchemaVersion(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, version)
+
+	// повторный вызов ничего не меняет и не возвращает ошибку
+	require.NoError(t, Migrate(context.Background(), db, SQLite))
+
+	// в новой БД можно сохранить и получить посылку
+	store := NewParcelStore(db)
+	parcel := migratedParcel()
+
+	num, err := store.Add(context.Background(), parcel)
+	require.NoError(t, err)
+
+	parcel.Number = num
+	storedParcel, err := store.Get(context.Background(), num)
+	require.NoError(t, err)
+	assert.Equal(t, parcel, storedParcel)
+}
+
+// TestMigrateDown проверяет откат миграций
+func TestMigrateDown(t *testing.T) {
+	t.Parallel()
+
+	db := openEmptyDB(t)
+
+	require.NoError(t, Migrate(context.Background(), db, SQLite))
+
+	// откатываем все миграции: таблица parcel должна исчезнуть
+	require.NoError(t, MigrateDown(context.Background(), db, SQLite, 0))
+
+	version, err := SchemaVersion(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	_, err = NewParcelStore(db).Get(context.Background(), 1)
+	require.Error(t, err)
+
+	// после отката схему можно снова привести к актуальной версии
+	require.NoError(t, Migrate(context.Background(), db, SQLite))
+	_, err = NewParcelStore(db).Add(context.Background(), migratedParcel())
+	require.NoError(t, err)
+}
+
+// TestMigrateTimestamps проверяет перенос посылок, созданных до появления
+// столбцов updated_at, sent_at и delivered_at: время приводится к формату хранения,
+// а время отправки восстанавливается по истории статусов
+func TestMigrateTimestamps(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openEmptyDB(t)
+
+	// схема до миграции 0005 с данными в прежнем формате RFC 3339
+	require.NoError(t, Migrate(ctx, db, SQLite))
+	require.NoError(t, MigrateDown(ctx, db, SQLite, 4))
+
+	_, err := db.ExecContext(ctx, `INSERT INTO parcel (number, client, status, address, created_at)
+									VALUES (1, 1000, 'sent', 'test', '2024-03-01T12:00:00Z')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO parcel_event (parcel_number, from_status, to_status, occurred_at, actor)
+									VALUES (1, 'registered', 'sent', '2024-03-01T15:30:00+03:00', 'system')`)
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(ctx, db, SQLite))
+
+	parcel, err := NewParcelStore(db).Get(ctx, 1)
+	require.NoError(t, err)
+
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sentAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	assert.Equal(t, createdAt, parcel.CreatedAt)
+	assert.Equal(t, sentAt, parcel.UpdatedAt)
+	require.NotNil(t, parcel.SentAt)
+	assert.Equal(t, sentAt, *parcel.SentAt)
+	assert.Nil(t, parcel.DeliveredAt)
+
+	events, err := NewParcelStore(db).Events(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, sentAt, events[0].At)
+}
+
+// TestMigrateClients проверяет, что для посылок, созданных до появления таблицы client,
+// заводятся клиенты без имени, а таблица parcel получает внешний ключ
+// и сохраняет счетчик номеров
+func TestMigrateClients(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openEmptyDB(t)
+
+	// схема до миграции 0006: номер 3 выдан и удален
+	require.NoError(t, Migrate(ctx, db, SQLite))
+	require.NoError(t, MigrateDown(ctx, db, SQLite, 5))
+
+	for _, p := range []struct {
+		number, client int
+		createdAt      time.Time
+	}{
+		{1, 1000, clocktest.Epoch},
+		{2, 2000, clocktest.Epoch.Add(time.Hour)},
+		{3, 1000, clocktest.Epoch.Add(2 * time.Hour)},
+	} {
+		_, err := db.ExecContext(ctx, `INSERT INTO parcel (number, client, status, address, created_at, updated_at)
+										VALUES ($1, $2, 'registered', 'test', $3, $3)`,
+			p.number, p.client, SQLite.timeArg(p.createdAt))
+		require.NoError(t, err)
+	}
+	_, err := db.ExecContext(ctx, `DELETE FROM parcel WHERE number = 3`)
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(ctx, db, SQLite))
+
+	clients, err := NewClientStore(db, SQLite).List(ctx, models.ClientFilter{})
+	require.NoError(t, err)
+	require.Len(t, clients, 2)
+	assert.Equal(t, 1000, clients[0].ID)
+	assert.Empty(t, clients[0].Name)
+	assert.True(t, clients[0].Active)
+	assert.Equal(t, clocktest.Epoch, clients[0].CreatedAt)
+	assert.Equal(t, 2000, clients[1].ID)
+	assert.Equal(t, clocktest.Epoch.Add(time.Hour), clients[1].CreatedAt)
+
+	// внешний ключ на client
+	var table string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT "table" FROM pragma_foreign_key_list('parcel')`).Scan(&table))
+	assert.Equal(t, "client", table)
+
+	// номер удаленной посылки повторно не выдается
+	store := NewParcelStore(db)
+	num, err := store.Add(ctx, migratedParcel())
+	require.NoError(t, err)
+	assert.Equal(t, 4, num)
+
+	// после отката таблица client удаляется, а посылки сохраняются
+	require.NoError(t, MigrateDown(ctx, db, SQLite, 5))
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM parcel WHERE client = 1000`).Scan(&count))
+	assert.Equal(t, 2, count)
+	_, err = NewClientStore(db, SQLite).Get(ctx, 1000)
+	require.Error(t, err)
+}
+
+// TestMigrateAddressFields проверяет чтение посылок, сохраненных до появления
+// столбца address_fields: части адреса получаются разбором однострочного адреса,
+// а после изменения адреса сохраняются в address_fields
+func TestMigrateAddressFields(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openEmptyDB(t)
+
+	require.NoError(t, Migrate(ctx, db, SQLite))
+	require.NoError(t, MigrateDown(ctx, db, SQLite, 6))
+
+	_, err := db.ExecContext(ctx, `INSERT INTO client (id, name, created_at, updated_at)
+									VALUES (1000, '', '2024-03-01T09:00:00.000Z', '2024-03-01T09:00:00.000Z')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO parcel (number, client, status, address, created_at, updated_at)
+									VALUES (1, 1000, 'registered', 'Псков, Лесная улица, дом 5',
+											'2024-03-01T09:00:00.000Z', '2024-03-01T09:00:00.000Z')`)
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(ctx, db, SQLite))
+
+	store := NewParcelStore(db)
+	parcel, err := store.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.Address{City: "Псков", Street: "ул. Лесная", House: "5"}, parcel.Recipient.Address)
+
+	// после изменения строка адреса записывается в каноническом виде
+	changed := models.Address{City: "Псков", Street: "ул. Лесная", House: "5", Apartment: "1"}
+	require.NoError(t, store.SetAddress(ctx, 1, changed, clocktest.Epoch))
+	var line string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT address FROM parcel WHERE number = 1`).Scan(&line))
+	assert.Equal(t, "Псков, ул. Лесная, д. 5, кв. 1", line)
+
+	parcel, err = store.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, changed, parcel.Recipient.Address)
+}
+
+// TestMigrateParties проверяет, что отправителем посылок, сохраненных
+// до появления сторон посылки, становится клиент, а откат удаляет новые столбцы
+func TestMigrateParties(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openEmptyDB(t)
+
+	require.NoError(t, Migrate(ctx, db, SQLite))
+	require.NoError(t, MigrateDown(ctx, db, SQLite, 7))
+
+	_, err := db.ExecContext(ctx, `INSERT INTO client (id, name, phone, created_at, updated_at)
+									VALUES (1000, 'ООО Ромашка', '+79991234567',
+											'2024-03-01T09:00:00.000Z', '2024-03-01T09:00:00.000Z')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO parcel (number, client, status, address, created_at, updated_at)
+									VALUES (1, 1000, 'registered', 'Псков, ул. Лесная, д. 5',
+											'2024-03-01T09:00:00.000Z', '2024-03-01T09:00:00.000Z')`)
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(ctx, db, SQLite))
+
+	parcel, err := NewParcelStore(db).Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.Party{Name: "ООО Ромашка", Phone: "+79991234567"}, parcel.Sender)
+	assert.Equal(t, models.Party{Address: models.Address{City: "Псков", Street: "ул. Лесная", House: "5"}},
+		parcel.Recipient)
+	assert.Nil(t, parcel.ReturnAddress)
+
+	// после отката столбцы сторон удаляются, а посылка сохраняется
+	require.NoError(t, MigrateDown(ctx, db, SQLite, 7))
+	var line string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT address FROM parcel WHERE number = 1`).Scan(&line))
+	assert.Equal(t, "Псков, ул. Лесная, д. 5", line)
+	_, err = db.ExecContext(ctx, `SELECT sender_name FROM parcel`)
+	require.Error(t, err)
+}
+
+// TestMigratePhysical проверяет, что у посылок, сохраненных до появления
+// физических характеристик, уровень обслуживания стандартный, а вес и габариты не указаны
+func TestMigratePhysical(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openEmptyDB(t)
+
+	require.NoError(t, Migrate(ctx, db, SQLite))
+	require.NoError(t, MigrateDown(ctx, db, SQLite, 8))
+
+	_, err := db.ExecContext(ctx, `INSERT INTO client (id, name, created_at, updated_at)
+									VALUES (1000, '', '2024-03-01T09:00:00.000Z', '2024-03-01T09:00:00.000Z')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO parcel (number, client, status, address, created_at, updated_at)
+									VALUES (1, 1000, 'registered', 'Псков, ул. Лесная, д. 5',
+											'2024-03-01T09:00:00.000Z', '2024-03-01T09:00:00.000Z')`)
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(ctx, db, SQLite))
+
+	parcel, err := NewParcelStore(db).Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ServiceLevelStandard, parcel.ServiceLevel)
+	assert.Zero(t, parcel.Weight)
+	assert.Zero(t, parcel.Dimensions)
+	assert.Zero(t, parcel.DeclaredValue)
+	assert.Empty(t, parcel.TrackingCode)
+}
+
+// TestLoadMigrations проверяет разбор имен файлов миграций
+func TestLoadMigrations(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"m/0002_second.up.sql":   {Data: []byte("up 2")},
+		"m/0002_second.down.sql": {Data: []byte("down 2")},
+		"m/0001_first.up.sql":    {Data: []byte("up 1")},
+		"m/0001_first.down.sql":  {Data: []byte("down 1")},
+	}
+
+	migrations, err := loadMigrations(fsys, "m")
+	require.NoError(t, err)
+	assert.Equal(t, []Migration{
+		{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+		{Version: 2, Name: "second", Up: "up 2", Down: "down 2"},
+	}, migrations)
+
+	// миграция без down-файла считается ошибкой
+	delete(fsys, "m/0002_second.down.sql")
+	_, err = loadMigrations(fsys, "m")
+	require.Error(t, err)
+}
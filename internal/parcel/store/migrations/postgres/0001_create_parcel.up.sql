@@ -0,0 +1,11 @@
+-- таблица посылок
+CREATE TABLE IF NOT EXISTS parcel
+(
+    number     INTEGER GENERATED BY DEFAULT AS IDENTITY
+        CONSTRAINT parcel_pk
+            PRIMARY KEY,
+    client     INTEGER      NOT NULL,
+    status     VARCHAR(128) NOT NULL,
+    address    VARCHAR(512) NOT NULL,
+    created_at TEXT         NOT NULL
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS parcel_client_idx;
@@ -0,0 +1,2 @@
+-- индекс для выборки посылок клиента (ParcelStore.GetByClient)
+CREATE INDEX IF NOT EXISTS parcel_client_idx ON parcel (client);
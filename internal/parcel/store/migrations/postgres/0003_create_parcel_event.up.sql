@@ -0,0 +1,19 @@
+-- история смены статусов посылок
+CREATE TABLE parcel_event
+(
+    id            INTEGER GENERATED BY DEFAULT AS IDENTITY
+        CONSTRAINT parcel_event_pk
+            PRIMARY KEY,
+    parcel_number INTEGER      NOT NULL
+        CONSTRAINT parcel_event_parcel_fk
+            REFERENCES parcel (number) ON DELETE CASCADE,
+    from_status   VARCHAR(128) NOT NULL,
+    to_status     VARCHAR(128) NOT NULL,
+    occurred_at   TEXT         NOT NULL,
+    actor         VARCHAR(128) NOT NULL,
+    location      VARCHAR(512) NOT NULL DEFAULT '',
+    comment       TEXT         NOT NULL DEFAULT ''
+);
+
+-- индекс для выборки истории посылки в хронологическом порядке
+CREATE INDEX parcel_event_parcel_idx ON parcel_event (parcel_number, id);
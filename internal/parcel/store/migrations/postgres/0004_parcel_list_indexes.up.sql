@@ -0,0 +1,5 @@
+-- индексы для списка посылок (ParcelRepository.List):
+-- сортировка по дате создания с номером в качестве второго ключа
+-- и выборка посылок клиента по возрастанию номера
+CREATE INDEX IF NOT EXISTS parcel_created_at_idx ON parcel (created_at, number);
+CREATE INDEX IF NOT EXISTS parcel_client_number_idx ON parcel (client, number);
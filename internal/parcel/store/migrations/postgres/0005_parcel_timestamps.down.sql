@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS parcel_delivered_at_idx;
+
+ALTER TABLE parcel
+    DROP COLUMN delivered_at,
+    DROP COLUMN sent_at,
+    DROP COLUMN updated_at;
+
+ALTER TABLE parcel_event
+    ALTER COLUMN occurred_at TYPE TEXT
+        USING to_char(occurred_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"');
+
+ALTER TABLE parcel
+    ALTER COLUMN created_at TYPE TEXT
+        USING to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"');
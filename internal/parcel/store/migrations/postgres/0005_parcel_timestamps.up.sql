@@ -0,0 +1,29 @@
+-- моменты времени хранятся в столбцах timestamptz вместо текста
+ALTER TABLE parcel
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at::TIMESTAMPTZ;
+
+ALTER TABLE parcel_event
+    ALTER COLUMN occurred_at TYPE TIMESTAMPTZ USING occurred_at::TIMESTAMPTZ;
+
+-- время последнего изменения посылки, отправки и доставки
+ALTER TABLE parcel
+    ADD COLUMN updated_at   TIMESTAMPTZ,
+    ADD COLUMN sent_at      TIMESTAMPTZ,
+    ADD COLUMN delivered_at TIMESTAMPTZ;
+
+-- для существующих посылок время отправки и доставки восстанавливается по истории статусов
+UPDATE parcel
+SET updated_at   = COALESCE((SELECT MAX(occurred_at) FROM parcel_event WHERE parcel_number = parcel.number),
+                            created_at),
+    sent_at      = (SELECT MIN(occurred_at)
+                    FROM parcel_event
+                    WHERE parcel_number = parcel.number AND to_status = 'sent'),
+    delivered_at = (SELECT MIN(occurred_at)
+                    FROM parcel_event
+                    WHERE parcel_number = parcel.number AND to_status = 'delivered');
+
+ALTER TABLE parcel
+    ALTER COLUMN updated_at SET NOT NULL;
+
+-- индекс для отчетов по времени доставки
+CREATE INDEX parcel_delivered_at_idx ON parcel (delivered_at);
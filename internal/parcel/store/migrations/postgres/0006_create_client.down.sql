@@ -0,0 +1,4 @@
+ALTER TABLE parcel
+    DROP CONSTRAINT IF EXISTS parcel_client_fk;
+
+DROP TABLE IF EXISTS client;
@@ -0,0 +1,26 @@
+-- клиенты - владельцы посылок
+CREATE TABLE client
+(
+    id         INTEGER GENERATED BY DEFAULT AS IDENTITY
+        CONSTRAINT client_pk
+            PRIMARY KEY,
+    name       VARCHAR(256) NOT NULL,
+    phone      VARCHAR(32)  NOT NULL DEFAULT '',
+    email      VARCHAR(256) NOT NULL DEFAULT '',
+    active     BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ  NOT NULL,
+    updated_at TIMESTAMPTZ  NOT NULL
+);
+
+-- для каждого идентификатора клиента из существующих посылок заводится клиент без имени,
+-- имя и контакты заполняются позже через изменение клиента
+INSERT INTO client (id, name, created_at, updated_at)
+SELECT client, '', MIN(created_at), MIN(created_at)
+FROM parcel
+GROUP BY client;
+
+-- новые клиенты получают идентификаторы после перенесенных
+SELECT setval(pg_get_serial_sequence('client', 'id'), COALESCE((SELECT MAX(id) FROM client), 0) + 1, false);
+
+ALTER TABLE parcel
+    ADD CONSTRAINT parcel_client_fk FOREIGN KEY (client) REFERENCES client (id);
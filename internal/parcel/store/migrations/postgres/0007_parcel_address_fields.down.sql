@@ -0,0 +1,2 @@
+ALTER TABLE parcel
+    DROP COLUMN address_fields;
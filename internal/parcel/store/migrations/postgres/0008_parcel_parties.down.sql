@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS parcel_recipient_phone_idx;
+
+ALTER TABLE parcel
+    DROP COLUMN return_address,
+    DROP COLUMN recipient_phone,
+    DROP COLUMN recipient_name,
+    DROP COLUMN sender_address,
+    DROP COLUMN sender_phone,
+    DROP COLUMN sender_name;
@@ -0,0 +1,20 @@
+-- отправитель и получатель посылки. Адрес получателя - прежний адрес доставки
+-- в столбцах address и address_fields, адреса отправителя и возврата хранятся в JSON
+-- (см. models.Address), NULL в return_address - возврат по адресу отправителя
+ALTER TABLE parcel
+    ADD COLUMN sender_name     VARCHAR(256) NOT NULL DEFAULT '',
+    ADD COLUMN sender_phone    VARCHAR(32)  NOT NULL DEFAULT '',
+    ADD COLUMN sender_address  JSONB,
+    ADD COLUMN recipient_name  VARCHAR(256) NOT NULL DEFAULT '',
+    ADD COLUMN recipient_phone VARCHAR(32)  NOT NULL DEFAULT '',
+    ADD COLUMN return_address  JSONB;
+
+-- отправителем существующих посылок считается клиент, который их зарегистрировал
+UPDATE parcel
+SET sender_name  = client.name,
+    sender_phone = client.phone
+FROM client
+WHERE client.id = parcel.client;
+
+-- индекс для списка посылок получателя
+CREATE INDEX parcel_recipient_phone_idx ON parcel (recipient_phone, number);
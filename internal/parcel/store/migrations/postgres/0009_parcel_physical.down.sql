@@ -0,0 +1,8 @@
+ALTER TABLE parcel
+    DROP COLUMN declared_value,
+    DROP COLUMN contents,
+    DROP COLUMN height,
+    DROP COLUMN width,
+    DROP COLUMN length,
+    DROP COLUMN weight,
+    DROP COLUMN service_level;
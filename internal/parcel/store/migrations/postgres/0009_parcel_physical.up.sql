@@ -0,0 +1,11 @@
+-- физические характеристики посылки: уровень обслуживания, вес в граммах,
+-- габариты в сантиметрах, описание вложения и объявленная ценность в копейках.
+-- У существующих посылок характеристики неизвестны и остаются нулевыми
+ALTER TABLE parcel
+    ADD COLUMN service_level  VARCHAR(32) NOT NULL DEFAULT 'standard',
+    ADD COLUMN weight         INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN length         INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN width          INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN height         INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN contents       TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN declared_value BIGINT      NOT NULL DEFAULT 0;
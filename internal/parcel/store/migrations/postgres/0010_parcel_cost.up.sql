@@ -0,0 +1,5 @@
+-- стоимость доставки в копейках, рассчитанная по тарифам на момент регистрации:
+-- последующие изменения тарифов не меняют стоимость зарегистрированных посылок.
+-- У существующих посылок стоимость неизвестна и остается нулевой
+ALTER TABLE parcel
+    ADD COLUMN cost BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS parcel_tracking_code_idx;
+
+ALTER TABLE parcel
+    DROP COLUMN tracking_code;
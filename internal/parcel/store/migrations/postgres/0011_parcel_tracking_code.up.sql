@@ -0,0 +1,7 @@
+-- публичный код отслеживания в формате S10 (см. пакет tracking), который сервис
+-- выдает при регистрации. У посылок, зарегистрированных раньше, кода нет (NULL):
+-- их по-прежнему ищут по номеру, а уникальный индекс не учитывает NULL
+ALTER TABLE parcel
+    ADD COLUMN tracking_code VARCHAR(13);
+
+CREATE UNIQUE INDEX parcel_tracking_code_idx ON parcel (tracking_code);
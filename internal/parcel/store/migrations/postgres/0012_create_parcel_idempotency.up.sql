@@ -0,0 +1,20 @@
+-- ключи идемпотентности запросов регистрации: повторный запрос клиента с тем же ключом
+-- возвращает уже зарегистрированную посылку вместо новой. fingerprint - отпечаток данных
+-- запроса (SHA-256), по нему отличается повтор от другого запроса с тем же ключом.
+-- Ключ уникален в пределах клиента и удаляется вместе с посылкой
+CREATE TABLE parcel_idempotency
+(
+    client          INTEGER      NOT NULL
+        CONSTRAINT parcel_idempotency_client_fk
+            REFERENCES client (id),
+    idempotency_key VARCHAR(128) NOT NULL,
+    fingerprint     CHAR(64)     NOT NULL,
+    parcel_number   INTEGER      NOT NULL
+        CONSTRAINT parcel_idempotency_parcel_fk
+            REFERENCES parcel (number) ON DELETE CASCADE,
+    created_at      TIMESTAMPTZ  NOT NULL,
+    CONSTRAINT parcel_idempotency_pk
+        PRIMARY KEY (client, idempotency_key)
+);
+
+CREATE INDEX parcel_idempotency_parcel_idx ON parcel_idempotency (parcel_number);
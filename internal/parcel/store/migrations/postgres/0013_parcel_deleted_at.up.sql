@@ -0,0 +1,7 @@
+-- момент удаления посылки. Удаленная посылка остается в таблице вместе с историей
+-- и может быть восстановлена, пока ее не удалит окончательно очистка (purge)
+-- по истечении срока хранения. NULL - посылка не удалена
+ALTER TABLE parcel
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX parcel_deleted_at_idx ON parcel (deleted_at);
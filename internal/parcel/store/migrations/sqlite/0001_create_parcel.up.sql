@@ -0,0 +1,16 @@
+-- таблица посылок
+-- IF NOT EXISTS позволяет применить миграцию к уже существующему tracker.db,
+-- в котором таблица parcel была создана вручную
+CREATE TABLE IF NOT EXISTS parcel
+(
+    number     INTEGER      NOT NULL
+        CONSTRAINT parcel_pk
+            PRIMARY KEY AUTOINCREMENT,
+    client     INTEGER      NOT NULL,
+    status     VARCHAR(128) NOT NULL,
+    address    VARCHAR(512) NOT NULL,
+    created_at TEXT         NOT NULL
+);
+
+-- удаляем временную таблицу, оставшуюся после ручного изменения схемы
+DROP TABLE IF EXISTS parcel_dg_tmp;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS parcel_client_number_idx;
+DROP INDEX IF EXISTS parcel_created_at_idx;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS parcel_delivered_at_idx;
+ALTER TABLE parcel DROP COLUMN delivered_at;
+ALTER TABLE parcel DROP COLUMN sent_at;
+ALTER TABLE parcel DROP COLUMN updated_at;
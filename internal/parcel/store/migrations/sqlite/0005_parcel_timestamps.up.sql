@@ -0,0 +1,27 @@
+-- моменты времени хранятся текстом в UTC в формате 2006-01-02T15:04:05.000Z:
+-- строки одной длины, поэтому сравнение и сортировка строк совпадают с хронологическими.
+-- Значения, записанные ранее в формате RFC 3339, приводятся к этому формату
+UPDATE parcel
+SET created_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), created_at);
+
+UPDATE parcel_event
+SET occurred_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', occurred_at), occurred_at);
+
+-- время последнего изменения посылки, отправки и доставки
+ALTER TABLE parcel ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+ALTER TABLE parcel ADD COLUMN sent_at TEXT;
+ALTER TABLE parcel ADD COLUMN delivered_at TEXT;
+
+-- для существующих посылок время отправки и доставки восстанавливается по истории статусов
+UPDATE parcel
+SET updated_at   = COALESCE((SELECT MAX(occurred_at) FROM parcel_event WHERE parcel_number = parcel.number),
+                            created_at),
+    sent_at      = (SELECT MIN(occurred_at)
+                    FROM parcel_event
+                    WHERE parcel_number = parcel.number AND to_status = 'sent'),
+    delivered_at = (SELECT MIN(occurred_at)
+                    FROM parcel_event
+                    WHERE parcel_number = parcel.number AND to_status = 'delivered');
+
+-- индекс для отчетов по времени доставки
+CREATE INDEX IF NOT EXISTS parcel_delivered_at_idx ON parcel (delivered_at);
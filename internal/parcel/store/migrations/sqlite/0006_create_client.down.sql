@@ -0,0 +1,31 @@
+-- таблица parcel пересоздается без внешнего ключа на client
+CREATE TABLE parcel_old
+(
+    number       INTEGER      NOT NULL
+        CONSTRAINT parcel_pk
+            PRIMARY KEY AUTOINCREMENT,
+    client       INTEGER      NOT NULL,
+    status       VARCHAR(128) NOT NULL,
+    address      VARCHAR(512) NOT NULL,
+    created_at   TEXT         NOT NULL,
+    updated_at   TEXT         NOT NULL DEFAULT '',
+    sent_at      TEXT,
+    delivered_at TEXT
+);
+
+INSERT INTO parcel_old (number, client, status, address, created_at, updated_at, sent_at, delivered_at)
+SELECT number, client, status, address, created_at, updated_at, sent_at, delivered_at
+FROM parcel;
+
+DELETE FROM sqlite_sequence WHERE name = 'parcel_old';
+UPDATE sqlite_sequence SET name = 'parcel_old' WHERE name = 'parcel';
+
+DROP TABLE parcel;
+ALTER TABLE parcel_old RENAME TO parcel;
+
+CREATE INDEX parcel_client_idx ON parcel (client);
+CREATE INDEX parcel_created_at_idx ON parcel (created_at, number);
+CREATE INDEX parcel_client_number_idx ON parcel (client, number);
+CREATE INDEX parcel_delivered_at_idx ON parcel (delivered_at);
+
+DROP TABLE client;
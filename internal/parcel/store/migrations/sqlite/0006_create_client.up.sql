@@ -0,0 +1,56 @@
+-- клиенты - владельцы посылок
+CREATE TABLE client
+(
+    id         INTEGER      NOT NULL
+        CONSTRAINT client_pk
+            PRIMARY KEY AUTOINCREMENT,
+    name       VARCHAR(256) NOT NULL,
+    phone      VARCHAR(32)  NOT NULL DEFAULT '',
+    email      VARCHAR(256) NOT NULL DEFAULT '',
+    active     INTEGER      NOT NULL DEFAULT 1,
+    created_at TEXT         NOT NULL,
+    updated_at TEXT         NOT NULL
+);
+
+-- для каждого идентификатора клиента из существующих посылок заводится клиент без имени,
+-- имя и контакты заполняются позже через изменение клиента
+INSERT INTO client (id, name, created_at, updated_at)
+SELECT client, '', MIN(created_at), MIN(created_at)
+FROM parcel
+GROUP BY client;
+
+-- SQLite не умеет добавлять ограничение к существующей таблице,
+-- поэтому таблица parcel пересоздается с внешним ключом на client.
+-- Миграция выполняется с выключенной проверкой внешних ключей (см. Migrate),
+-- иначе удаление parcel затронуло бы историю parcel_event
+CREATE TABLE parcel_new
+(
+    number       INTEGER      NOT NULL
+        CONSTRAINT parcel_pk
+            PRIMARY KEY AUTOINCREMENT,
+    client       INTEGER      NOT NULL
+        CONSTRAINT parcel_client_fk
+            REFERENCES client (id),
+    status       VARCHAR(128) NOT NULL,
+    address      VARCHAR(512) NOT NULL,
+    created_at   TEXT         NOT NULL,
+    updated_at   TEXT         NOT NULL DEFAULT '',
+    sent_at      TEXT,
+    delivered_at TEXT
+);
+
+INSERT INTO parcel_new (number, client, status, address, created_at, updated_at, sent_at, delivered_at)
+SELECT number, client, status, address, created_at, updated_at, sent_at, delivered_at
+FROM parcel;
+
+-- счетчик автоинкремента переносится, чтобы номера удаленных посылок не выдавались повторно
+DELETE FROM sqlite_sequence WHERE name = 'parcel_new';
+UPDATE sqlite_sequence SET name = 'parcel_new' WHERE name = 'parcel';
+
+DROP TABLE parcel;
+ALTER TABLE parcel_new RENAME TO parcel;
+
+CREATE INDEX parcel_client_idx ON parcel (client);
+CREATE INDEX parcel_created_at_idx ON parcel (created_at, number);
+CREATE INDEX parcel_client_number_idx ON parcel (client, number);
+CREATE INDEX parcel_delivered_at_idx ON parcel (delivered_at);
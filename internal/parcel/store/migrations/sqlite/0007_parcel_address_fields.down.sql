@@ -0,0 +1 @@
+ALTER TABLE parcel DROP COLUMN address_fields;
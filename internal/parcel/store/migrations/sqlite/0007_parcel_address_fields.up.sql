@@ -0,0 +1,5 @@
+-- адрес посылки по частям в JSON (см. models.Address), столбец address
+-- остается однострочной формой адреса для поиска по подстроке.
+-- У существующих посылок части не заполняются: хранилище разбирает
+-- строку address при чтении, а следующее изменение адреса сохраняет части
+ALTER TABLE parcel ADD COLUMN address_fields TEXT;
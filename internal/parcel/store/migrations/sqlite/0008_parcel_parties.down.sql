@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS parcel_recipient_phone_idx;
+ALTER TABLE parcel DROP COLUMN return_address;
+ALTER TABLE parcel DROP COLUMN recipient_phone;
+ALTER TABLE parcel DROP COLUMN recipient_name;
+ALTER TABLE parcel DROP COLUMN sender_address;
+ALTER TABLE parcel DROP COLUMN sender_phone;
+ALTER TABLE parcel DROP COLUMN sender_name;
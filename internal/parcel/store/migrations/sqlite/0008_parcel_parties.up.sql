@@ -0,0 +1,17 @@
+-- отправитель и получатель посылки. Адрес получателя - прежний адрес доставки
+-- в столбцах address и address_fields, адреса отправителя и возврата хранятся в JSON
+-- (см. models.Address), NULL в return_address - возврат по адресу отправителя
+ALTER TABLE parcel ADD COLUMN sender_name VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE parcel ADD COLUMN sender_phone VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE parcel ADD COLUMN sender_address TEXT;
+ALTER TABLE parcel ADD COLUMN recipient_name VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE parcel ADD COLUMN recipient_phone VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE parcel ADD COLUMN return_address TEXT;
+
+-- отправителем существующих посылок считается клиент, который их зарегистрировал
+UPDATE parcel
+SET sender_name  = (SELECT name FROM client WHERE id = parcel.client),
+    sender_phone = (SELECT phone FROM client WHERE id = parcel.client);
+
+-- индекс для списка посылок получателя
+CREATE INDEX parcel_recipient_phone_idx ON parcel (recipient_phone, number);
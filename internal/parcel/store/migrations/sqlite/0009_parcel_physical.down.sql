@@ -0,0 +1,7 @@
+ALTER TABLE parcel DROP COLUMN declared_value;
+ALTER TABLE parcel DROP COLUMN contents;
+ALTER TABLE parcel DROP COLUMN height;
+ALTER TABLE parcel DROP COLUMN width;
+ALTER TABLE parcel DROP COLUMN length;
+ALTER TABLE parcel DROP COLUMN weight;
+ALTER TABLE parcel DROP COLUMN service_level;
@@ -0,0 +1,10 @@
+-- физические характеристики посылки: уровень обслуживания, вес в граммах,
+-- габариты в сантиметрах, описание вложения и объявленная ценность в копейках.
+-- У существующих посылок характеристики неизвестны и остаются нулевыми
+ALTER TABLE parcel ADD COLUMN service_level VARCHAR(32) NOT NULL DEFAULT 'standard';
+ALTER TABLE parcel ADD COLUMN weight INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE parcel ADD COLUMN length INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE parcel ADD COLUMN width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE parcel ADD COLUMN height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE parcel ADD COLUMN contents TEXT NOT NULL DEFAULT '';
+ALTER TABLE parcel ADD COLUMN declared_value INTEGER NOT NULL DEFAULT 0;
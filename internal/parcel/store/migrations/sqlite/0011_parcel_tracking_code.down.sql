@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS parcel_tracking_code_idx;
+ALTER TABLE parcel DROP COLUMN tracking_code;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS parcel_deleted_at_idx;
+ALTER TABLE parcel DROP COLUMN deleted_at;
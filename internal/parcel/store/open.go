@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	// регистрируем драйверы database/sql для поддерживаемых СУБД
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect - диалект SQL, на котором работает хранилище
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"   // файл SQLite, драйвер modernc.org/sqlite
+	Postgres Dialect = "postgres" // PostgreSQL, драйвер pgx
+)
+
+// определяем структурный тип Database - подключение к БД,
+// выбранной по DSN, вместе с ее диалектом
+type Database struct {
+	DB      *sql.DB // указатель на БД
+	Dialect Dialect // диалект SQL подключенной СУБД
+}
+
+// функция ParseDSN определяет диалект по строке подключения
+// и возвращает имя драйвера database/sql и DSN для него.
+// Строки вида postgres://... и postgresql://... относятся к PostgreSQL,
+// все остальные считаются путем к файлу SQLite (префикс sqlite:// отбрасывается,
+// параметры подключения дополняются sqliteParams)
+// Параметры
+// dsn - строка подключения
+func ParseDSN(dsn string) (dialect Dialect, driver string, source string, err error) {
+	switch {
+	case dsn == "":
+		return "", "", "", fmt.Errorf("не задана строка подключения к базе данных")
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return Postgres, "pgx", dsn, nil
+	default:
+		return SQLite, "sqlite", withSQLiteParams(strings.TrimPrefix(dsn, "sqlite://")), nil
+	}
+}
+
+// sqliteParams - параметры подключения к SQLite, нужные для конкурентной записи:
+// транзакции сразу захватывают блокировку на запись (_txlock=immediate),
+// а ожидающие ее соединения ждут до 5 секунд вместо немедленной ошибки SQLITE_BUSY.
+// Проверка внешних ключей (foreign_keys) в SQLite по умолчанию выключена
+// и включается для каждого соединения
+var sqliteParams = []string{"_pragma=busy_timeout(5000)", "_pragma=foreign_keys(1)", "_txlock=immediate"}
+
+// функция withSQLiteParams добавляет к пути SQLite параметры sqliteParams,
+// которые не заданы в нем явно. Параметры _pragma различаются по имени прагмы
+func withSQLiteParams(source string) string {
+	for _, param := range sqliteParams {
+		name, value, _ := strings.Cut(param, "=")
+		key := name + "="
+		if name == "_pragma" {
+			pragma, _, _ := strings.Cut(value, "(")
+			key = name + "=" + pragma
+		}
+		if strings.Contains(source, key) {
+			continue
+		}
+
+		sep := "?"
+		if strings.Contains(source, "?") {
+			sep = "&"
+		}
+		source += sep + param
+	}
+
+	return source
+}
+
+// функция Open подключается к БД по строке подключения dsn,
+// проверяет соединение и приводит схему к актуальной версии
+// Параметры
+// ctx - контекст, ограничивающий время подключения и миграции
+// dsn - путь к файлу SQLite или URL PostgreSQL
+func Open(ctx context.Context, dsn string) (Database, error) {
+	dialect, driver, source, err := ParseDSN(dsn)
+	if err != nil {
+		return Database{}, err
+	}
+
+	db, err := sql.Open(driver, source)
+	if err != nil {
+		return Database{}, err
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return Database{}, err
+	}
+
+	if err := Migrate(ctx, db, dialect); err != nil {
+		db.Close()
+		return Database{}, err
+	}
+
+	return Database{DB: db, Dialect: dialect}, nil
+}
+
+// Метод Parcels типа Database возвращает хранилище посылок
+// для диалекта подключенной БД
+func (d Database) Parcels() ParcelRepository {
+	if d.Dialect == Postgres {
+		return NewPostgresStore(d.DB)
+	}
+
+	return NewParcelStore(d.DB)
+}
+
+// Метод Clients типа Database возвращает хранилище клиентов
+func (d Database) Clients() ClientStore {
+	return NewClientStore(d.DB, d.Dialect)
+}
+
+// Метод Close типа Database закрывает подключение к БД
+func (d Database) Close() error {
+	return d.DB.Close()
+}
@@ -0,0 +1,606 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// определяем структурный тип PostgresStore для работы с БД PostgreSQL.
+// Повторяет поведение ParcelStore, но использует позиционные параметры $1, $2, ...
+// и получает номер новой посылки через RETURNING
+type PostgresStore struct {
+	db   dbtx    // выполняет запросы: указатель на БД, открытую драйвером pgx, или транзакция
+	root *sql.DB // БД, в которой WithTx начинает транзакции; nil внутри транзакции
+}
+
+// функция NewPostgresStore для создания нового экземпляра PostgresStore
+// Параметры
+// db - указатель на БД PostgreSQL
+func NewPostgresStore(db *sql.DB) PostgresStore {
+	return PostgresStore{db: db, root: db}
+}
+
+// postgresStatusTimestamps - присваивания в UPDATE при смене статуса,
+// см. sqliteStatusTimestamps. Параметры: $1 - новый статус, $2 - момент изменения,
+// $3 и $4 - статусы `отправлена` и `доставлена`
+const postgresStatusTimestamps = `updated_at = $2,
+	sent_at = CASE WHEN $1 = $3 THEN COALESCE(sent_at, $2) ELSE sent_at END,
+	delivered_at = CASE WHEN $1 = $4 THEN $2 ELSE delivered_at END`
+
+// Метод WithTx типа PostgresStore
+// выполняет fn в транзакции, см. ParcelStore.WithTx
+// Параметры
+// ctx - контекст запроса
+// fn - функция, выполняющая операции с хранилищем tx
+func (s PostgresStore) WithTx(ctx context.Context, fn func(tx ParcelRepository) error) error {
+	return withTx(ctx, s.root, s.db, func(db dbtx) error {
+		return fn(PostgresStore{db: db})
+	})
+}
+
+// Метод Add типа PostgresStore добавляет
+// в таблицу parcel запись для новой посылки
+// Параметры
+// ctx - контекст запроса
+// p - экземпляр типа Parcel
+// возвращает номер, присвоенный посылке
+func (s PostgresStore) Add(ctx context.Context, p models.Parcel) (int, error) {
+	args, err := postgresParcelArgs(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	if err := s.db.QueryRowContext(ctx, postgresInsertParcel, args...).Scan(&id); err != nil {
+		return 0, trackingCodeErr(err, p.TrackingCode)
+	}
+
+	return id, nil
+}
+
+// postgresInsertParcel - запрос, добавляющий посылку, параметры - postgresParcelArgs
+const postgresInsertParcel = `INSERT INTO parcel (client, status, tracking_code, sender_name, sender_phone, sender_address,
+												  recipient_name, recipient_phone, address, address_fields, return_address,
+												  service_level, weight, length, width, height, contents, declared_value, cost,
+												  created_at, updated_at, sent_at, delivered_at)
+							  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+									  $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+							  RETURNING number`
+
+// функция postgresParcelArgs возвращает параметры запроса postgresInsertParcel для посылки p,
+// см. sqliteParcelArgs
+func postgresParcelArgs(p models.Parcel) ([]any, error) {
+	updatedAt := p.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = p.CreatedAt
+	}
+
+	addresses, err := addressArgs(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		p.Client, p.Status, nullStringArg(p.TrackingCode), p.Sender.Name, p.Sender.Phone, addresses.sender,
+		p.Recipient.Name, p.Recipient.Phone, p.Recipient.Address.String(), addresses.recipient, addresses.ret,
+		p.ServiceLevel, p.Weight, p.Dimensions.Length, p.Dimensions.Width, p.Dimensions.Height,
+		p.Contents, p.DeclaredValue, p.Cost,
+		Postgres.timeArg(p.CreatedAt), Postgres.timeArg(updatedAt),
+		Postgres.nullTimeArg(p.SentAt), Postgres.nullTimeArg(p.DeliveredAt),
+	}, nil
+}
+
+// Метод AddBatch типа PostgresStore
+// добавляет посылки в одной транзакции одним подготовленным запросом,
+// см. ParcelStore.AddBatch
+// Параметры
+// ctx - контекст запроса
+// parcels - новые посылки
+func (s PostgresStore) AddBatch(ctx context.Context, parcels []models.Parcel) ([]int, error) {
+	numbers := make([]int, 0, len(parcels))
+	err := withTx(ctx, s.root, s.db, func(db dbtx) error {
+		stmt, err := db.PrepareContext(ctx, postgresInsertParcel)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, p := range parcels {
+			args, err := postgresParcelArgs(p)
+			if err != nil {
+				return err
+			}
+			var id int
+			if err := stmt.QueryRowContext(ctx, args...).Scan(&id); err != nil {
+				return trackingCodeErr(err, p.TrackingCode)
+			}
+			numbers = append(numbers, id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return numbers, nil
+}
+
+// Метод Get типа PostgresStore
+// получает данные о посылке по номеру
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+func (s PostgresStore) Get(ctx context.Context, number int) (models.Parcel, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+parcelColumns+`
+									  FROM parcel
+									  WHERE number = $1 AND
+											deleted_at IS NULL`, number)
+
+	p, err := scanParcel(row)
+	if err == sql.ErrNoRows {
+		return p, errors.NotFound(entityParcel, number)
+	}
+	if err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// Метод GetByTrackingCode типа PostgresStore
+// получает данные о посылке по коду отслеживания
+// Параметры
+// ctx - контекст запроса
+// code - код отслеживания
+func (s PostgresStore) GetByTrackingCode(ctx context.Context, code string) (models.Parcel, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+parcelColumns+`
+									  FROM parcel
+									  WHERE tracking_code = $1 AND
+											deleted_at IS NULL`, code)
+
+	p, err := scanParcel(row)
+	if err == sql.ErrNoRows {
+		return p, errors.NotFound(entityParcel, code)
+	}
+	if err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// Метод GetByClient типа PostgresStore
+// возвращает все посылки интересующего клиента
+// Параметры
+// ctx - контекст запроса
+// client - идентификатор клиента
+func (s PostgresStore) GetByClient(ctx context.Context, client int) ([]models.Parcel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+parcelColumns+`
+										 FROM parcel
+										 WHERE client = $1 AND
+											   deleted_at IS NULL
+										 ORDER BY number`, client)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var res = make([]models.Parcel, 0)
+
+	for rows.Next() {
+		p, err := scanParcel(rows)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// Метод List типа PostgresStore
+// возвращает страницу посылок, удовлетворяющих условиям f.
+// Условия и сортировка выполняются в БД, в память загружается только одна страница
+// Параметры
+// ctx - контекст запроса
+// f - условия выборки, сортировка и курсор страницы
+func (s PostgresStore) List(ctx context.Context, f models.ParcelFilter) (models.ParcelPage, error) {
+	return listParcels(ctx, s.db, Postgres, f)
+}
+
+// Метод Iterate типа PostgresStore
+// передает в fn все посылки, удовлетворяющие условиям f, как и ParcelStore.Iterate
+// Параметры
+// ctx - контекст запроса
+// f - условия выборки и сортировка
+// fn - функция, которая получает посылки; ошибка fn прекращает обход и возвращается
+func (s PostgresStore) Iterate(ctx context.Context, f models.ParcelFilter, fn func(models.Parcel) error) error {
+	return iterateParcels(ctx, s.db, Postgres, f, fn)
+}
+
+// метод SetStatus типа PostgresStore
+// изменяет статус у заданной посылки
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// status - новый статус посылки
+// at - момент изменения
+func (s PostgresStore) SetStatus(ctx context.Context, number int, status string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, postgresUpdateStatus,
+		status, Postgres.timeArg(at), constants.ParcelStatusSent, constants.ParcelStatusDelivered, number)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.NotFound(entityParcel, number)
+	}
+
+	return nil
+}
+
+// postgresUpdateStatus - запрос, изменяющий статус посылки $5 на $1 в момент $2
+const postgresUpdateStatus = `UPDATE parcel
+							  SET status = $1, ` + postgresStatusTimestamps + `
+							  WHERE number = $5 AND
+									deleted_at IS NULL`
+
+// postgresCompareAndSetStatus - запрос, изменяющий статус посылки, как и postgresUpdateStatus,
+// если ее текущий статус равен $6
+const postgresCompareAndSetStatus = `UPDATE parcel
+									 SET status = $1, ` + postgresStatusTimestamps + `
+									 WHERE number = $5 AND
+										   status = $6 AND
+										   deleted_at IS NULL`
+
+// postgresInsertEvent - запрос, добавляющий событие в историю
+const postgresInsertEvent = `INSERT INTO parcel_event (parcel_number, from_status, to_status,
+												occurred_at, actor, location, comment)
+							 VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+// метод SetStatusBatch типа PostgresStore
+// переводит посылки в статус to в одной транзакции одним подготовленным условным запросом,
+// см. ParcelStore.SetStatusBatch
+// Параметры
+// ctx - контекст запроса
+// changes - номера посылок и их ожидаемые текущие статусы
+// to - новый статус посылок
+// at - момент изменения
+func (s PostgresStore) SetStatusBatch(ctx context.Context, changes []StatusChange, to string, at time.Time) ([]error, error) {
+	failures := make([]error, len(changes))
+	err := withTx(ctx, s.root, s.db, func(db dbtx) error {
+		stmt, err := db.PrepareContext(ctx, postgresCompareAndSetStatus)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i, c := range changes {
+			res, err := stmt.ExecContext(ctx,
+				to, Postgres.timeArg(at), constants.ParcelStatusSent, constants.ParcelStatusDelivered, c.Number, c.From)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				failures[i] = PostgresStore{db: db}.explainFailure(ctx, c.Number, opSetStatus)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return failures, nil
+}
+
+// метод SetAddress типа PostgresStore
+// изменяет адрес у посылки со статусом `зарегистрирована`
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// address - новый адрес
+// at - момент изменения
+func (s PostgresStore) SetAddress(ctx context.Context, number int, address models.Address, at time.Time) error {
+	fields, err := addressArg(address)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE parcel
+									   SET address = $1, address_fields = $5, updated_at = $4
+									   WHERE number = $2 AND
+											 status = $3 AND
+											 deleted_at IS NULL`,
+		address.String(), number, constants.ParcelStatusRegistered, Postgres.timeArg(at), fields)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// посылки нет или ее статус не равен `зарегистрирована`
+	if rowsAffected == 0 {
+		return s.explainFailure(ctx, number, opSetAddress)
+	}
+
+	return nil
+}
+
+// Метод Delete типа PostgresStore
+// удаляет посылку со статусом `зарегистрирована`, отмечая момент удаления,
+// см. ParcelStore.Delete
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// at - момент удаления
+func (s PostgresStore) Delete(ctx context.Context, number int, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE parcel
+									   SET deleted_at = $3, updated_at = $3
+									   WHERE number = $1 AND
+											 status = $2 AND
+											 deleted_at IS NULL`,
+		number, constants.ParcelStatusRegistered, Postgres.timeArg(at))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// посылки нет или ее статус не равен `зарегистрирована`
+	if rowsAffected == 0 {
+		return s.explainFailure(ctx, number, opDelete)
+	}
+
+	return nil
+}
+
+// метод Restore типа PostgresStore
+// восстанавливает удаленную посылку, см. ParcelStore.Restore
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// at - момент восстановления
+func (s PostgresStore) Restore(ctx context.Context, number int, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE parcel
+									   SET deleted_at = NULL, updated_at = $2
+									   WHERE number = $1 AND
+											 deleted_at IS NOT NULL`,
+		number, Postgres.timeArg(at))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// посылки нет или она не удалена
+	if rowsAffected == 0 {
+		return s.explainFailure(ctx, number, opRestore)
+	}
+
+	return nil
+}
+
+// метод Purge типа PostgresStore
+// окончательно удаляет посылки, удаленные раньше момента before,
+// см. ParcelStore.Purge
+// Параметры
+// ctx - контекст запроса
+// before - граница момента удаления
+func (s PostgresStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	var n int64
+	err := withTx(ctx, s.root, s.db, func(db dbtx) error {
+		for _, table := range []string{"parcel_event", "parcel_idempotency"} {
+			_, err := db.ExecContext(ctx, `DELETE FROM `+table+`
+										   WHERE parcel_number IN (SELECT number FROM parcel
+																   WHERE deleted_at IS NOT NULL AND
+																		 deleted_at < $1)`,
+				Postgres.timeArg(before))
+			if err != nil {
+				return err
+			}
+		}
+
+		res, err := db.ExecContext(ctx, `DELETE FROM parcel
+										 WHERE deleted_at IS NOT NULL AND
+											   deleted_at < $1`,
+			Postgres.timeArg(before))
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// метод explainFailure типа PostgresStore
+// определяет причину неудачного условного изменения посылки,
+// см. ParcelStore.explainFailure
+func (s PostgresStore) explainFailure(ctx context.Context, number int, op string) error {
+	var status string
+	err := s.db.QueryRowContext(ctx, "SELECT status FROM parcel WHERE number = $1 AND deleted_at IS NULL", number).Scan(&status)
+	if err == sql.ErrNoRows {
+		return errors.NotFound(entityParcel, number)
+	}
+	if err != nil {
+		return err
+	}
+
+	return errors.InvalidState(number, status, op)
+}
+
+// метод CompareAndSetStatus типа PostgresStore
+// переводит посылку из статуса from в статус to, если ее статус все еще равен from,
+// см. ParcelStore.CompareAndSetStatus
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// from - ожидаемый текущий статус
+// to - новый статус
+// at - момент изменения
+func (s PostgresStore) CompareAndSetStatus(ctx context.Context, number int, from string, to string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, postgresCompareAndSetStatus,
+		to, Postgres.timeArg(at), constants.ParcelStatusSent, constants.ParcelStatusDelivered, number, from)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// посылки нет или ее статус уже изменился
+	if rowsAffected == 0 {
+		return s.explainFailure(ctx, number, opSetStatus)
+	}
+
+	return nil
+}
+
+// метод AddEvent типа PostgresStore
+// записывает событие смены статуса в историю
+// Параметры
+// ctx - контекст запроса
+// ev - событие смены статуса
+func (s PostgresStore) AddEvent(ctx context.Context, ev models.StatusEvent) error {
+	_, err := s.db.ExecContext(ctx, postgresInsertEvent,
+		ev.Parcel, ev.From, ev.To, Postgres.timeArg(ev.At), ev.Actor, ev.Location, ev.Comment)
+
+	return err
+}
+
+// метод AddEvents типа PostgresStore
+// записывает события в историю в одной транзакции одним подготовленным запросом
+// Параметры
+// ctx - контекст запроса
+// events - события смены статуса
+func (s PostgresStore) AddEvents(ctx context.Context, events []models.StatusEvent) error {
+	return withTx(ctx, s.root, s.db, func(db dbtx) error {
+		stmt, err := db.PrepareContext(ctx, postgresInsertEvent)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, ev := range events {
+			_, err := stmt.ExecContext(ctx,
+				ev.Parcel, ev.From, ev.To, Postgres.timeArg(ev.At), ev.Actor, ev.Location, ev.Comment)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// метод AddIdempotencyKey типа PostgresStore
+// сохраняет ключ идемпотентности, см. ParcelStore.AddIdempotencyKey
+// Параметры
+// ctx - контекст запроса
+// k - ключ идемпотентности и посылка, зарегистрированная по нему
+func (s PostgresStore) AddIdempotencyKey(ctx context.Context, k models.IdempotencyKey) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO parcel_idempotency (client, idempotency_key, fingerprint, parcel_number, created_at)
+									 VALUES ($1, $2, $3, $4, $5)`,
+		k.Client, k.Key, k.Fingerprint, k.Parcel, Postgres.timeArg(k.CreatedAt))
+
+	return err
+}
+
+// Метод GetIdempotencyKey типа PostgresStore
+// возвращает ключ идемпотентности клиента или ошибку errors.NotFoundError
+// Параметры
+// ctx - контекст запроса
+// client - идентификатор клиента
+// key - ключ идемпотентности
+func (s PostgresStore) GetIdempotencyKey(ctx context.Context, client int, key string) (models.IdempotencyKey, error) {
+	k := models.IdempotencyKey{}
+	err := s.db.QueryRowContext(ctx, `SELECT client, idempotency_key, fingerprint, parcel_number, created_at
+									  FROM parcel_idempotency
+									  WHERE client = $1 AND
+											idempotency_key = $2`, client, key).
+		Scan(&k.Client, &k.Key, &k.Fingerprint, &k.Parcel, scanTime(&k.CreatedAt))
+	if err == sql.ErrNoRows {
+		return k, errors.NotFound(entityKey, key)
+	}
+	if err != nil {
+		return k, err
+	}
+
+	return k, nil
+}
+
+// Метод Events типа PostgresStore
+// возвращает историю смены статусов посылки в хронологическом порядке,
+// см. ParcelStore.Events
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+func (s PostgresStore) Events(ctx context.Context, number int) ([]models.StatusEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.parcel_number, e.from_status, e.to_status,
+												e.occurred_at, e.actor, e.location, e.comment
+										 FROM parcel_event e
+										 JOIN parcel p ON p.number = e.parcel_number
+										 WHERE e.parcel_number = $1 AND
+											   p.deleted_at IS NULL
+										 ORDER BY e.id`, number)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var res = make([]models.StatusEvent, 0)
+
+	for rows.Next() {
+		ev := models.StatusEvent{}
+		err := rows.Scan(&ev.ID, &ev.Parcel, &ev.From, &ev.To, scanTime(&ev.At), &ev.Actor, &ev.Location, &ev.Comment)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, ev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
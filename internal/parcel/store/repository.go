@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+)
+
+// ParcelRepository описывает хранилище посылок, с которым работает ParcelService.
+// Интерфейс реализуют ParcelStore (БД SQLite), PostgresStore (БД PostgreSQL)
+// и MemoryStore (память процесса),
+// поэтому логику сервиса можно проверять без обращения к диску.
+// Все методы принимают контекст: его отмена или истечение дедлайна прерывает операцию.
+// Методы, изменяющие посылку, принимают момент изменения at и сами обновляют
+// UpdatedAt, а при переходе в статусы `отправлена` и `доставлена` - SentAt и DeliveredAt
+type ParcelRepository interface {
+	// Add добавляет посылку и возвращает ее номер,
+	// незаполненное время изменения считается равным времени создания.
+	// Если код отслеживания уже принадлежит другой посылке (в том числе удаленной),
+	// возвращается ошибка ErrTrackingCodeTaken
+	Add(ctx context.Context, p models.Parcel) (int, error)
+	// AddBatch добавляет посылки в одной транзакции и возвращает их номера в порядке parcels.
+	// Если какую-либо посылку добавить не удалось, не добавляется ни одна;
+	// занятый код отслеживания, как и в Add, возвращается как ErrTrackingCodeTaken
+	AddBatch(ctx context.Context, parcels []models.Parcel) ([]int, error)
+	// Get возвращает посылку по номеру или ошибку errors.NotFoundError.
+	// Удаленные посылки этот и остальные методы чтения и изменения не видят,
+	// их возвращают только List и Iterate с условием ParcelFilter.IncludeDeleted
+	Get(ctx context.Context, number int) (models.Parcel, error)
+	// GetByTrackingCode возвращает посылку по коду отслеживания или ошибку errors.NotFoundError.
+	// Код сохраняется при добавлении посылки и уникален
+	GetByTrackingCode(ctx context.Context, code string) (models.Parcel, error)
+	// GetByClient возвращает все посылки клиента в порядке возрастания номеров
+	GetByClient(ctx context.Context, client int) ([]models.Parcel, error)
+	// List возвращает страницу посылок, удовлетворяющих условиям f, в порядке f.OrderBy.
+	// Некорректные условия или курсор возвращаются как errors.ValidationError
+	List(ctx context.Context, f models.ParcelFilter) (models.ParcelPage, error)
+	// Iterate передает в fn все посылки, удовлетворяющие условиям f, в порядке f.OrderBy,
+	// не загружая выборку в память целиком; размер страницы и курсор f не используются.
+	// Ошибка fn прекращает обход и возвращается. БД-хранилища держат открытым результат запроса
+	// до конца обхода, поэтому fn не должна обращаться к хранилищу
+	Iterate(ctx context.Context, f models.ParcelFilter, fn func(models.Parcel) error) error
+	// SetStatus изменяет статус посылки или возвращает ошибку errors.NotFoundError
+	SetStatus(ctx context.Context, number int, status string, at time.Time) error
+	// SetStatusBatch переводит посылки в статус to в одной транзакции: как и в CompareAndSetStatus,
+	// статус посылки меняется, только если ее текущий статус равен StatusChange.From.
+	// Возвращает ошибки посылок в порядке changes: nil, если статус изменен, иначе
+	// errors.NotFoundError или errors.StateError с текущим статусом. Такая ошибка не мешает
+	// перевести остальные посылки, а ошибка вызова означает, что не изменена ни одна
+	SetStatusBatch(ctx context.Context, changes []StatusChange, to string, at time.Time) ([]error, error)
+	// SetAddress изменяет адрес посылки со статусом `зарегистрирована`,
+	// для несуществующей посылки возвращает errors.NotFoundError,
+	// для посылки в другом статусе - errors.StateError
+	SetAddress(ctx context.Context, number int, address models.Address, at time.Time) error
+	// Delete удаляет посылку со статусом `зарегистрирована` в момент at, ошибки - как у SetAddress.
+	// Посылка остается в хранилище вместе с историей, пока ее не удалит Purge
+	Delete(ctx context.Context, number int, at time.Time) error
+	// Restore восстанавливает удаленную посылку, для несуществующей посылки
+	// возвращает errors.NotFoundError, для неудаленной - errors.StateError
+	Restore(ctx context.Context, number int, at time.Time) error
+	// Purge окончательно удаляет посылки, удаленные раньше момента before,
+	// вместе с историей и ключами идемпотентности и возвращает их число
+	Purge(ctx context.Context, before time.Time) (int, error)
+	// CompareAndSetStatus переводит посылку из статуса from в статус to,
+	// если ее текущий статус равен from, иначе возвращает errors.StateError
+	CompareAndSetStatus(ctx context.Context, number int, from string, to string, at time.Time) error
+	// AddEvent записывает событие смены статуса в историю
+	AddEvent(ctx context.Context, ev models.StatusEvent) error
+	// AddEvents записывает события в историю в одной транзакции
+	AddEvents(ctx context.Context, events []models.StatusEvent) error
+	// Events возвращает историю смены статусов посылки в хронологическом порядке;
+	// для удаленной или несуществующей посылки история пуста
+	Events(ctx context.Context, number int) ([]models.StatusEvent, error)
+	// AddIdempotencyKey сохраняет ключ идемпотентности зарегистрированной посылки.
+	// Ключ уникален в пределах клиента: повторное сохранение возвращает ошибку
+	AddIdempotencyKey(ctx context.Context, k models.IdempotencyKey) error
+	// GetIdempotencyKey возвращает ключ идемпотентности клиента или ошибку errors.NotFoundError
+	GetIdempotencyKey(ctx context.Context, client int, key string) (models.IdempotencyKey, error)
+	// WithTx выполняет fn в транзакции: операции хранилища tx либо применяются все,
+	// если fn вернула nil, либо не применяется ни одна
+	WithTx(ctx context.Context, fn func(tx ParcelRepository) error) error
+}
+
+// ClientRepository описывает хранилище клиентов.
+// Интерфейс реализуют ClientStore (БД SQLite или PostgreSQL) и MemoryClientStore (память процесса).
+// Посылка ссылается на клиента внешним ключом parcel.client, поэтому клиентов
+// не удаляют, а деактивируют: их посылки остаются в истории
+type ClientRepository interface {
+	// Create добавляет клиента и возвращает его идентификатор
+	Create(ctx context.Context, c models.Client) (int, error)
+	// Get возвращает клиента по идентификатору или ошибку errors.NotFoundError
+	Get(ctx context.Context, id int) (models.Client, error)
+	// Update изменяет имя и контакты клиента или возвращает ошибку errors.NotFoundError
+	Update(ctx context.Context, c models.Client) error
+	// List возвращает клиентов, удовлетворяющих условиям f, по возрастанию идентификатора.
+	// Некорректные условия возвращаются как errors.ValidationError
+	List(ctx context.Context, f models.ClientFilter) ([]models.Client, error)
+	// Deactivate деактивирует клиента в момент at или возвращает ошибку errors.NotFoundError.
+	// Повторная деактивация не считается ошибкой
+	Deactivate(ctx context.Context, id int, at time.Time) error
+}
+
+// названия объекта и операций, которые хранилища подставляют в тексты ошибок
+const (
+	entityParcel = "посылка"
+	entityClient = "клиент"
+	entityKey    = "ключ идемпотентности"
+	opSetAddress = "изменение адреса"
+	opDelete     = "удаление"
+	opRestore    = "восстановление"
+	opSetStatus  = "смена статуса"
+)
+
+// определяем структурный тип StatusChange - условная смена статуса посылки в SetStatusBatch
+type StatusChange struct {
+	Number int    // номер посылки
+	From   string // статус, который посылка должна иметь, чтобы его можно было изменить
+}
+
+// ErrTrackingCodeTaken - ошибка добавления посылки с кодом отслеживания,
+// который уже принадлежит другой посылке: нарушен уникальный индекс parcel_tracking_code_idx.
+// Сервис получает ее, если параллельная регистрация заняла код после его проверки,
+// и повторяет добавление с новым кодом
+var ErrTrackingCodeTaken = stderrors.New("код отслеживания уже используется")
+
+// проверяем на этапе компиляции, что хранилища реализуют интерфейсы ParcelRepository и ClientRepository
+var (
+	_ ParcelRepository = ParcelStore{}
+	_ ParcelRepository = PostgresStore{}
+	_ ParcelRepository = (*MemoryStore)(nil)
+
+	_ ClientRepository = ClientStore{}
+	_ ClientRepository = (*MemoryClientStore)(nil)
+)
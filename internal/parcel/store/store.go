@@ -1,8 +1,9 @@
 package store
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"time"
 
 	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
 	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
@@ -11,7 +12,8 @@ import (
 
 // определяем структурный тип ParcelStore для работы с БД
 type ParcelStore struct {
-	db *sql.DB // единственное поле db - указатель на БД
+	db   dbtx    // выполняет запросы: указатель на БД или открытая транзакция
+	root *sql.DB // БД, в которой WithTx начинает транзакции; nil внутри транзакции
 }
 
 // функция NewParcelStore для создания нового экземпляра ParcelStore
@@ -19,25 +21,116 @@ type ParcelStore struct {
 // db - указатель на БД
 // возвращает новый экземпляр ParcelStore
 func NewParcelStore(db *sql.DB) ParcelStore {
-	return ParcelStore{db: db}
+	return ParcelStore{db: db, root: db}
+}
+
+// sqliteStatusTimestamps - присваивания в UPDATE при смене статуса на :to в момент :at:
+// время изменения обновляется всегда, время отправки - при первой отправке,
+// время доставки - при доставке
+const sqliteStatusTimestamps = `updated_at = :at,
+	sent_at = CASE WHEN :to = :sent THEN COALESCE(sent_at, :at) ELSE sent_at END,
+	delivered_at = CASE WHEN :to = :delivered THEN :at ELSE delivered_at END`
+
+// sqliteInsertParcel - запрос, добавляющий посылку, параметры - sqliteParcelArgs
+const sqliteInsertParcel = `INSERT INTO parcel (client, status, tracking_code, sender_name, sender_phone, sender_address,
+												recipient_name, recipient_phone, address, address_fields, return_address,
+												service_level, weight, length, width, height, contents, declared_value, cost,
+												created_at, updated_at, sent_at, delivered_at)
+						 VALUES (:client, :status, :tracking_code, :sender_name, :sender_phone, :sender_address,
+								 :recipient_name, :recipient_phone, :address, :address_fields, :return_address,
+								 :service_level, :weight, :length, :width, :height, :contents, :declared_value, :cost,
+								 :created_at, :updated_at, :sent_at, :delivered_at)`
+
+// sqliteUpdateStatus - запрос, изменяющий статус посылки :number на :to в момент :at
+const sqliteUpdateStatus = `UPDATE parcel
+						SET status = :to, ` + sqliteStatusTimestamps + `
+						WHERE number = :number AND
+							  deleted_at IS NULL`
+
+// sqliteCompareAndSetStatus - запрос, изменяющий статус посылки, как и sqliteUpdateStatus,
+// если ее текущий статус равен :from
+const sqliteCompareAndSetStatus = `UPDATE parcel
+						SET status = :to, ` + sqliteStatusTimestamps + `
+						WHERE number = :number AND
+							  status = :from AND
+							  deleted_at IS NULL`
+
+// sqliteInsertEvent - запрос, добавляющий событие в историю, параметры - sqliteEventArgs
+const sqliteInsertEvent = `INSERT INTO parcel_event (parcel_number, from_status, to_status,
+												occurred_at, actor, location, comment)
+							 VALUES (:parcel, :from, :to, :at, :actor, :location, :comment)`
+
+// функция sqliteParcelArgs возвращает параметры запроса sqliteInsertParcel для посылки p.
+// Время последнего изменения новой посылки по умолчанию совпадает со временем создания
+func sqliteParcelArgs(p models.Parcel) ([]any, error) {
+	updatedAt := p.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = p.CreatedAt
+	}
+
+	addresses, err := addressArgs(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		sql.Named("client", p.Client), sql.Named("status", p.Status),
+		sql.Named("tracking_code", nullStringArg(p.TrackingCode)),
+		sql.Named("sender_name", p.Sender.Name), sql.Named("sender_phone", p.Sender.Phone),
+		sql.Named("sender_address", addresses.sender),
+		sql.Named("recipient_name", p.Recipient.Name), sql.Named("recipient_phone", p.Recipient.Phone),
+		sql.Named("address", p.Recipient.Address.String()), sql.Named("address_fields", addresses.recipient),
+		sql.Named("return_address", addresses.ret),
+		sql.Named("service_level", p.ServiceLevel), sql.Named("weight", p.Weight),
+		sql.Named("length", p.Dimensions.Length), sql.Named("width", p.Dimensions.Width),
+		sql.Named("height", p.Dimensions.Height), sql.Named("contents", p.Contents),
+		sql.Named("declared_value", p.DeclaredValue), sql.Named("cost", p.Cost),
+		sql.Named("created_at", SQLite.timeArg(p.CreatedAt)), sql.Named("updated_at", SQLite.timeArg(updatedAt)),
+		sql.Named("sent_at", SQLite.nullTimeArg(p.SentAt)), sql.Named("delivered_at", SQLite.nullTimeArg(p.DeliveredAt)),
+	}, nil
+}
+
+// функция sqliteStatusArgs возвращает параметры запроса sqliteUpdateStatus,
+// для sqliteCompareAndSetStatus к ним добавляется ожидаемый статус :from
+func sqliteStatusArgs(number int, status string, at time.Time) []any {
+	return []any{
+		sql.Named("to", status), sql.Named("number", number), sql.Named("at", SQLite.timeArg(at)),
+		sql.Named("sent", constants.ParcelStatusSent), sql.Named("delivered", constants.ParcelStatusDelivered),
+	}
+}
+
+// Метод WithTx типа ParcelStore
+// выполняет fn в транзакции: все методы хранилища tx, переданного в fn,
+// работают в одной транзакции, которая фиксируется, если fn вернула nil,
+// и откатывается в противном случае. Внутри транзакции WithTx не начинает новую
+// Параметры
+// ctx - контекст запроса
+// fn - функция, выполняющая операции с хранилищем tx
+func (s ParcelStore) WithTx(ctx context.Context, fn func(tx ParcelRepository) error) error {
+	return withTx(ctx, s.root, s.db, func(db dbtx) error {
+		return fn(ParcelStore{db: db})
+	})
 }
 
 // Метод Add типа ParcelStore добавляет
 // в таблицу parcel в БД запись для новой посылки
 // Параметры
+// ctx - контекст запроса, отмена контекста прерывает запрос к БД
 // p - экземпляр типа Parcel
 // поля данной переменной будут использоваться
 // для заполнения соответствующих атрибутов в таблице parcel
 // возвращает идентификатор последней добавленной записи
-func (s ParcelStore) Add(p models.Parcel) (int, error) {
-	res, err := s.db.Exec(`INSERT INTO parcel (client, status, address, created_at)
-						 VALUES (:client, :status, :address, :created_at)`,
-		sql.Named("client", p.Client), sql.Named("status", p.Status),
-		sql.Named("address", p.Address), sql.Named("created_at", p.CreatedAt))
+func (s ParcelStore) Add(ctx context.Context, p models.Parcel) (int, error) {
+	args, err := sqliteParcelArgs(p)
 	if err != nil {
 		return 0, err
 	}
 
+	res, err := s.db.ExecContext(ctx, sqliteInsertParcel, args...)
+	if err != nil {
+		return 0, trackingCodeErr(err, p.TrackingCode)
+	}
+
 	// получаем id последней добавленной записи
 	id, err := res.LastInsertId()
 	if err != nil {
@@ -48,22 +141,94 @@ func (s ParcelStore) Add(p models.Parcel) (int, error) {
 	return int(id), nil
 }
 
+// Метод AddBatch типа ParcelStore
+// добавляет посылки в одной транзакции одним подготовленным запросом:
+// запрос разбирается один раз, а транзакция фиксируется один раз на весь пакет.
+// Если какую-либо посылку добавить не удалось, не добавляется ни одна
+// Параметры
+// ctx - контекст запроса
+// parcels - новые посылки
+// возвращает номера, присвоенные посылкам, в порядке parcels
+func (s ParcelStore) AddBatch(ctx context.Context, parcels []models.Parcel) ([]int, error) {
+	numbers := make([]int, 0, len(parcels))
+	err := withTx(ctx, s.root, s.db, func(db dbtx) error {
+		stmt, err := db.PrepareContext(ctx, sqliteInsertParcel)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, p := range parcels {
+			args, err := sqliteParcelArgs(p)
+			if err != nil {
+				return err
+			}
+			res, err := stmt.ExecContext(ctx, args...)
+			if err != nil {
+				return trackingCodeErr(err, p.TrackingCode)
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			numbers = append(numbers, int(id))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return numbers, nil
+}
+
 // Метод Get типа ParcelStore
-// получает данные о посылке из БД по идентификатору посылки
+// получает данные о посылке из БД по идентификатору посылки,
+// для несуществующей посылки возвращает ошибку errors.NotFoundError
 // Параметры
+// ctx - контекст запроса
 // number - идентификатор посылки
 // возвращает экземпляр типа Parcel
 // и ошибку, если она возникла в ходе выполнения функции
-func (s ParcelStore) Get(number int) (models.Parcel, error) {
+func (s ParcelStore) Get(ctx context.Context, number int) (models.Parcel, error) {
 	// из таблицы возвращается только одна строка
-	row := s.db.QueryRow(`SELECT number, client, status, address, created_at
+	row := s.db.QueryRowContext(ctx, `SELECT `+parcelColumns+`
 						  FROM parcel
-						  WHERE number = :number`,
+						  WHERE number = :number AND
+								deleted_at IS NULL`,
 		sql.Named("number", number))
 
 	// заполняем объект Parcel полученными данными
-	p := models.Parcel{}
-	err := row.Scan(&p.Number, &p.Client, &p.Status, &p.Address, &p.CreatedAt)
+	p, err := scanParcel(row)
+	if err == sql.ErrNoRows {
+		// вместо ошибки драйвера возвращаем ошибку отсутствия посылки
+		return p, errors.NotFound(entityParcel, number)
+	}
+	if err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// Метод GetByTrackingCode типа ParcelStore
+// получает данные о посылке из БД по коду отслеживания,
+// для неизвестного кода возвращает ошибку errors.NotFoundError
+// Параметры
+// ctx - контекст запроса
+// code - код отслеживания в каноническом виде, см. tracking.Normalize
+func (s ParcelStore) GetByTrackingCode(ctx context.Context, code string) (models.Parcel, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+parcelColumns+`
+						  FROM parcel
+						  WHERE tracking_code = :tracking_code AND
+								deleted_at IS NULL`,
+		sql.Named("tracking_code", code))
+
+	p, err := scanParcel(row)
+	if err == sql.ErrNoRows {
+		return p, errors.NotFound(entityParcel, code)
+	}
 	if err != nil {
 		return p, err
 	}
@@ -74,14 +239,17 @@ func (s ParcelStore) Get(number int) (models.Parcel, error) {
 // Метод GetByClient типа ParcelStore
 // применяется для получения всех посылок интересующего клиента из БД
 // Параметры
+// ctx - контекст запроса
 // client - идентификатор клиента, посылки которого мы хотим получить
 // возвращает слайс из структур типа Parcel
 // и ошибку, если она возникла в ходе выполнения функции
-func (s ParcelStore) GetByClient(client int) ([]models.Parcel, error) {
+func (s ParcelStore) GetByClient(ctx context.Context, client int) ([]models.Parcel, error) {
 	// здесь из таблицы может вернуться несколько строк
-	rows, err := s.db.Query(`SELECT number, client, status, address, created_at
+	rows, err := s.db.QueryContext(ctx, `SELECT `+parcelColumns+`
 							 FROM parcel
-							 WHERE client = :client`, sql.Named("client", client))
+							 WHERE client = :client AND
+								   deleted_at IS NULL
+							 ORDER BY number`, sql.Named("client", client))
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +260,7 @@ func (s ParcelStore) GetByClient(client int) ([]models.Parcel, error) {
 	var res = make([]models.Parcel, 0)
 
 	for rows.Next() {
-		p := models.Parcel{}
-		err := rows.Scan(&p.Number, &p.Client, &p.Status, &p.Address, &p.CreatedAt)
+		p, err := scanParcel(rows)
 		if err != nil {
 			return res, err
 		}
@@ -107,36 +274,120 @@ func (s ParcelStore) GetByClient(client int) ([]models.Parcel, error) {
 	return res, nil
 }
 
+// Метод List типа ParcelStore
+// возвращает страницу посылок, удовлетворяющих условиям f.
+// Условия и сортировка выполняются в БД, в память загружается только одна страница
+// Параметры
+// ctx - контекст запроса
+// f - условия выборки, сортировка и курсор страницы
+func (s ParcelStore) List(ctx context.Context, f models.ParcelFilter) (models.ParcelPage, error) {
+	return listParcels(ctx, s.db, SQLite, f)
+}
+
+// метод Iterate типа ParcelStore
+// передает в fn все посылки, удовлетворяющие условиям f, в порядке f.OrderBy,
+// читая их из БД по одной. Размер страницы и курсор f не используются
+// Параметры
+// ctx - контекст запроса
+// f - условия выборки и сортировка
+// fn - функция, которая получает посылки; ошибка fn прекращает обход и возвращается
+func (s ParcelStore) Iterate(ctx context.Context, f models.ParcelFilter, fn func(models.Parcel) error) error {
+	return iterateParcels(ctx, s.db, SQLite, f, fn)
+}
+
 // метод SetStatus типа ParcelStore
 // позволяет изменить статус у заданной посылки
 // Параметры
+// ctx - контекст запроса
 // number - номер посылки
 // status - новый статус посылки
-// возвращает ошибку
-func (s ParcelStore) SetStatus(number int, status string) error {
-	_, err := s.db.Exec("UPDATE parcel SET status = :status WHERE number = :number",
-		sql.Named("status", status), sql.Named("number", number))
+// at - момент изменения, по нему обновляются updated_at, sent_at и delivered_at
+// возвращает ошибку, для несуществующей посылки - errors.NotFoundError
+func (s ParcelStore) SetStatus(ctx context.Context, number int, status string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, sqliteUpdateStatus, sqliteStatusArgs(number, status, at)...)
 	if err != nil {
 		return err
 	}
 
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.NotFound(entityParcel, number)
+	}
+
 	return nil
 }
 
+// метод SetStatusBatch типа ParcelStore
+// переводит посылки в статус to в одной транзакции одним подготовленным условным запросом
+// (см. CompareAndSetStatus). Посылка, которой нет или статус которой не равен ожидаемому,
+// получает свою ошибку и не мешает перевести остальные
+// Параметры
+// ctx - контекст запроса
+// changes - номера посылок и их ожидаемые текущие статусы
+// to - новый статус посылок
+// at - момент изменения
+// возвращает ошибки посылок в порядке changes: nil, errors.NotFoundError или errors.StateError
+func (s ParcelStore) SetStatusBatch(ctx context.Context, changes []StatusChange, to string, at time.Time) ([]error, error) {
+	failures := make([]error, len(changes))
+	err := withTx(ctx, s.root, s.db, func(db dbtx) error {
+		stmt, err := db.PrepareContext(ctx, sqliteCompareAndSetStatus)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i, c := range changes {
+			args := append(sqliteStatusArgs(c.Number, to, at), sql.Named("from", c.From))
+			res, err := stmt.ExecContext(ctx, args...)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				failures[i] = ParcelStore{db: db}.explainFailure(ctx, c.Number, opSetStatus)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return failures, nil
+}
+
 // метод SetAddress типа ParcelStore
 // изменяет адрес у посылки с заданным идентификатором
 // Изменение адреса возможно, только если
 // статус посылки равен `зарегистрирована`
 // Параметры
+// ctx - контекст запроса
 // number - идентификатор посылки
 // address - новый адрес
-// возвращает ошибку
-func (s ParcelStore) SetAddress(number int, address string) error {
-	res, err := s.db.Exec(`UPDATE parcel
-						SET address = :address
+// at - момент изменения
+// возвращает ошибку errors.NotFoundError, если посылки нет,
+// и errors.StateError, если ее статус не `зарегистрирована`
+func (s ParcelStore) SetAddress(ctx context.Context, number int, address models.Address, at time.Time) error {
+	fields, err := addressArg(address)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE parcel
+						SET address = :address, address_fields = :address_fields, updated_at = :at
 						WHERE number = :number AND
-							  status = :registered`,
-		sql.Named("address", address),
+							  status = :registered AND
+							  deleted_at IS NULL`,
+		sql.Named("address", address.String()), sql.Named("address_fields", fields),
+		sql.Named("at", SQLite.timeArg(at)),
 		sql.Named("number", number),
 		sql.Named("registered", constants.ParcelStatusRegistered))
 
@@ -153,28 +404,36 @@ func (s ParcelStore) SetAddress(number int, address string) error {
 		return err
 	}
 
-	// информируем о неудачной операции
+	// выясняем причину неудачной операции
 	if rowsAffected == 0 {
-		return errors.ErrUnsuccessful
+		return s.explainFailure(ctx, number, opSetAddress)
 	}
 
 	return nil
 }
 
 // Метод Delete типа ParcelStore
-// удаляет посылку из БД (таблицы parcel)
-// удалить посылку можно, только если ее статус
+// удаляет посылку: строка остается в таблице parcel вместе с историей,
+// в ней только отмечается момент удаления deleted_at. Удаленная посылка
+// не видна остальным методам, ее можно восстановить (Restore)
+// до окончательного удаления (Purge).
+// Удалить посылку можно, только если ее статус
 // равен `зарегистрирована`
 // Параметры
+// ctx - контекст запроса
 // number - номер посылки, которую требуется удалить
-func (s ParcelStore) Delete(number int) error {
-	res, err := s.db.Exec(`DELETE FROM parcel
+// at - момент удаления
+// возвращает те же ошибки, что и SetAddress
+func (s ParcelStore) Delete(ctx context.Context, number int, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE parcel
+						SET deleted_at = :at, updated_at = :at
 						WHERE number = :number AND
-						status = :registered`,
+							  status = :registered AND
+							  deleted_at IS NULL`,
+		sql.Named("at", SQLite.timeArg(at)),
 		sql.Named("number", number),
 		sql.Named("registered", constants.ParcelStatusRegistered))
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
@@ -187,10 +446,258 @@ func (s ParcelStore) Delete(number int) error {
 		return err
 	}
 
-	// информируем о неудачной операции
+	// выясняем причину неудачной операции
 	if rowsAffected == 0 {
-		return errors.ErrUnsuccessful
+		return s.explainFailure(ctx, number, opDelete)
 	}
 
 	return nil
 }
+
+// метод Restore типа ParcelStore
+// восстанавливает удаленную посылку: сбрасывает момент удаления deleted_at
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// at - момент восстановления
+// возвращает ошибку errors.NotFoundError, если посылки нет (в том числе после Purge),
+// и errors.StateError, если посылка не удалена
+func (s ParcelStore) Restore(ctx context.Context, number int, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE parcel
+						SET deleted_at = NULL, updated_at = :at
+						WHERE number = :number AND
+							  deleted_at IS NOT NULL`,
+		sql.Named("at", SQLite.timeArg(at)), sql.Named("number", number))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// посылки нет или она не удалена: в последнем случае explainFailure
+	// находит посылку и возвращает ее статус
+	if rowsAffected == 0 {
+		return s.explainFailure(ctx, number, opRestore)
+	}
+
+	return nil
+}
+
+// метод Purge типа ParcelStore
+// окончательно удаляет из БД посылки, удаленные раньше момента before,
+// вместе с их историей и ключами идемпотентности в одной транзакции.
+// Записи истории и ключей удаляются явно, а не через ON DELETE CASCADE:
+// SQLite проверяет внешние ключи, только если соединение открыто с foreign_keys(1) (см. Open),
+// а хранилище может получить БД, открытую без этого параметра
+// Параметры
+// ctx - контекст запроса
+// before - граница: удаляются посылки с deleted_at < before
+// возвращает число удаленных посылок
+func (s ParcelStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	var n int64
+	err := withTx(ctx, s.root, s.db, func(db dbtx) error {
+		arg := sql.Named("before", SQLite.timeArg(before))
+		for _, table := range []string{"parcel_event", "parcel_idempotency"} {
+			_, err := db.ExecContext(ctx, `DELETE FROM `+table+`
+								WHERE parcel_number IN (SELECT number FROM parcel
+														WHERE deleted_at IS NOT NULL AND
+															  deleted_at < :before)`, arg)
+			if err != nil {
+				return err
+			}
+		}
+
+		res, err := db.ExecContext(ctx, `DELETE FROM parcel
+							WHERE deleted_at IS NOT NULL AND
+								  deleted_at < :before`, arg)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// метод explainFailure типа ParcelStore
+// определяет, почему условный UPDATE не затронул ни одной строки:
+// посылки с таким номером нет или она удалена (errors.NotFoundError)
+// или ее статус не допускает операцию (errors.StateError с текущим статусом)
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// op - название операции для текста ошибки
+func (s ParcelStore) explainFailure(ctx context.Context, number int, op string) error {
+	var status string
+	err := s.db.QueryRowContext(ctx, "SELECT status FROM parcel WHERE number = :number AND deleted_at IS NULL",
+		sql.Named("number", number)).Scan(&status)
+	if err == sql.ErrNoRows {
+		return errors.NotFound(entityParcel, number)
+	}
+	if err != nil {
+		return err
+	}
+
+	return errors.InvalidState(number, status, op)
+}
+
+// метод CompareAndSetStatus типа ParcelStore
+// переводит посылку из статуса from в статус to одним условным UPDATE.
+// Если статус посылки к моменту обновления уже не равен from
+// (например, его изменил параллельный запрос), статус не меняется
+// и возвращается errors.StateError с текущим статусом
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+// from - ожидаемый текущий статус
+// to - новый статус
+// at - момент изменения, по нему обновляются updated_at, sent_at и delivered_at
+func (s ParcelStore) CompareAndSetStatus(ctx context.Context, number int, from string, to string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, sqliteCompareAndSetStatus,
+		append(sqliteStatusArgs(number, to, at), sql.Named("from", from))...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// посылки нет или ее статус уже изменился
+	if rowsAffected == 0 {
+		return s.explainFailure(ctx, number, opSetStatus)
+	}
+
+	return nil
+}
+
+// метод AddEvent типа ParcelStore
+// записывает событие смены статуса в историю (таблицу parcel_event).
+// Чтобы событие и смена статуса сохранялись атомарно, оба вызова выполняются в WithTx
+// Параметры
+// ctx - контекст запроса
+// ev - событие смены статуса
+func (s ParcelStore) AddEvent(ctx context.Context, ev models.StatusEvent) error {
+	_, err := s.db.ExecContext(ctx, sqliteInsertEvent, sqliteEventArgs(ev)...)
+
+	return err
+}
+
+// метод AddEvents типа ParcelStore
+// записывает события в историю в одной транзакции одним подготовленным запросом
+// Параметры
+// ctx - контекст запроса
+// events - события смены статуса
+func (s ParcelStore) AddEvents(ctx context.Context, events []models.StatusEvent) error {
+	return withTx(ctx, s.root, s.db, func(db dbtx) error {
+		stmt, err := db.PrepareContext(ctx, sqliteInsertEvent)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, ev := range events {
+			if _, err := stmt.ExecContext(ctx, sqliteEventArgs(ev)...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// функция sqliteEventArgs возвращает параметры запроса sqliteInsertEvent для события ev
+func sqliteEventArgs(ev models.StatusEvent) []any {
+	return []any{
+		sql.Named("parcel", ev.Parcel), sql.Named("from", ev.From), sql.Named("to", ev.To),
+		sql.Named("at", SQLite.timeArg(ev.At)), sql.Named("actor", ev.Actor),
+		sql.Named("location", ev.Location), sql.Named("comment", ev.Comment),
+	}
+}
+
+// метод AddIdempotencyKey типа ParcelStore
+// сохраняет ключ идемпотентности в таблице parcel_idempotency,
+// ключ, уже использованный клиентом, нарушает первичный ключ таблицы
+// Параметры
+// ctx - контекст запроса
+// k - ключ идемпотентности и посылка, зарегистрированная по нему
+func (s ParcelStore) AddIdempotencyKey(ctx context.Context, k models.IdempotencyKey) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO parcel_idempotency (client, idempotency_key, fingerprint, parcel_number, created_at)
+						 VALUES (:client, :key, :fingerprint, :parcel, :created_at)`,
+		sql.Named("client", k.Client), sql.Named("key", k.Key), sql.Named("fingerprint", k.Fingerprint),
+		sql.Named("parcel", k.Parcel), sql.Named("created_at", SQLite.timeArg(k.CreatedAt)))
+
+	return err
+}
+
+// Метод GetIdempotencyKey типа ParcelStore
+// возвращает ключ идемпотентности клиента,
+// для неизвестного ключа возвращает ошибку errors.NotFoundError
+// Параметры
+// ctx - контекст запроса
+// client - идентификатор клиента
+// key - ключ идемпотентности
+func (s ParcelStore) GetIdempotencyKey(ctx context.Context, client int, key string) (models.IdempotencyKey, error) {
+	k := models.IdempotencyKey{}
+	err := s.db.QueryRowContext(ctx, `SELECT client, idempotency_key, fingerprint, parcel_number, created_at
+						  FROM parcel_idempotency
+						  WHERE client = :client AND
+								idempotency_key = :key`,
+		sql.Named("client", client), sql.Named("key", key)).
+		Scan(&k.Client, &k.Key, &k.Fingerprint, &k.Parcel, scanTime(&k.CreatedAt))
+	if err == sql.ErrNoRows {
+		return k, errors.NotFound(entityKey, key)
+	}
+	if err != nil {
+		return k, err
+	}
+
+	return k, nil
+}
+
+// Метод Events типа ParcelStore
+// возвращает историю смены статусов посылки в хронологическом порядке.
+// История удаленной посылки, как и сама посылка, не видна: возвращается пустой слайс
+// Параметры
+// ctx - контекст запроса
+// number - номер посылки
+func (s ParcelStore) Events(ctx context.Context, number int) ([]models.StatusEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT e.id, e.parcel_number, e.from_status, e.to_status,
+												e.occurred_at, e.actor, e.location, e.comment
+										 FROM parcel_event e
+										 JOIN parcel p ON p.number = e.parcel_number
+										 WHERE e.parcel_number = :number AND
+											   p.deleted_at IS NULL
+										 ORDER BY e.id`, sql.Named("number", number))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var res = make([]models.StatusEvent, 0)
+
+	for rows.Next() {
+		ev := models.StatusEvent{}
+		err := rows.Scan(&ev.ID, &ev.Parcel, &ev.From, &ev.To, scanTime(&ev.At), &ev.Actor, &ev.Location, &ev.Comment)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, ev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
@@ -1,64 +1,271 @@
-package store
+package store_test
 
 import (
 	// импортируем пакеты standard library
+	"context"
 	"database/sql"
-	"math/rand"
+	stderrors "errors"
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
 	// импортируем пакеты third-party
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	_ "modernc.org/sqlite"
 
 	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/clock/clocktest"
 	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
 	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
 	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store/storetest"
 )
 
-var (
-	// randSource источник псевдо случайных чисел.
-	// в качестве seed используется текущее время
-	// в unix формате
-	randSource = rand.NewSource(time.Now().UnixNano())
-	// randRange использует randSource для генерации случайных чисел
-	randRange = rand.New(randSource)
-)
+// runParcelRepositoryTests запускает набор тестов, который должна проходить
+// каждая реализация ParcelRepository.
+// newStores возвращает хранилища посылок и клиентов, подготовленные для очередного теста.
+// Каждый тест получает собственные хранилища, поэтому тесты выполняются параллельно
+func runParcelRepositoryTests(t *testing.T, newStores func(t *testing.T) storetest.Stores) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository)
+	}{
+		{"AddGetDelete", testAddGetDelete},
+		{"RestorePurge", testRestorePurge},
+		{"SetAddress", testSetAddress},
+		{"SetStatus", testSetStatus},
+		{"GetByClient", testGetByClient},
+		{"List", testList},
+		{"Iterate", testIterate},
+		{"RecipientParcels", testRecipientParcels},
+		{"TrackingCode", testTrackingCode},
+		{"IdempotencyKey", testIdempotencyKey},
+		{"AddBatch", testAddBatch},
+		{"SetStatusBatch", testSetStatusBatch},
+		{"StatusEvents", testStatusEvents},
+		{"DeletedEvents", testDeletedEvents},
+		{"CompareAndSetStatus", testCompareAndSetStatus},
+		{"WithTxRollback", testWithTxRollback},
+	}
 
-// getTestParcel возвращает тестовую посылку
-func getTestParcel() models.Parcel {
-	return models.Parcel{
-		Client:    1000,
-		Status:    constants.ParcelStatusRegistered,
-		Address:   "test",
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newStores(t)
+			tt.run(t, s.Parcels, s.Clients)
+		})
 	}
 }
 
-// TestAddGetDelete проверяет добавление, получение и удаление посылки
-func TestAddGetDelete(t *testing.T) {
-	// подключаемся к БД
-	db, err := sql.Open("sqlite", "../../../tracker.db")
+// TestParcelStore проверяет хранилище SQLite.
+// Каждый тест получает новую БД со схемой актуальной версии,
+// поэтому результаты не зависят от предыдущих запусков и других тестов
+func TestParcelStore(t *testing.T) {
+	t.Parallel()
+
+	runParcelRepositoryTests(t, func(t *testing.T) storetest.Stores {
+		return storetest.NewSQLite(t)
+	})
+}
+
+// testTrackingCode проверяет поиск посылки по коду отслеживания и уникальность кода
+func testTrackingCode(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+
+	parcel := storetest.Parcel().Client(client).TrackingCode("RR123456785RU").Add(t, repo)
+	storedParcel, err := repo.GetByTrackingCode(ctx, "RR123456785RU")
 	require.NoError(t, err)
+	assert.Equal(t, parcel, storedParcel)
 
-	defer db.Close()
+	_, err = repo.GetByTrackingCode(ctx, "RR000000005RU")
+	require.ErrorIs(t, err, errors.ErrNotFound)
 
-	// получаем экземпляр ParcelStore
-	store := NewParcelStore(db)
-	// получаем тестовый экземпляр посылки
-	parcel := getTestParcel()
+	// код не может принадлежать двум посылкам
+	_, err = repo.Add(ctx, storetest.Parcel().Client(client).TrackingCode("RR123456785RU").Build())
+	require.ErrorIs(t, err, store.ErrTrackingCodeTaken)
+	_, err = repo.AddBatch(ctx, []models.Parcel{storetest.Parcel().Client(client).TrackingCode("RR123456785RU").Build()})
+	require.ErrorIs(t, err, store.ErrTrackingCodeTaken)
+
+	// посылок без кода может быть сколько угодно, и по пустому коду они не находятся
+	storetest.Parcel().Client(client).Add(t, repo)
+	storetest.Parcel().Client(client).Add(t, repo)
+	_, err = repo.GetByTrackingCode(ctx, "")
+	require.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// testIdempotencyKey проверяет сохранение ключей идемпотентности:
+// ключ уникален в пределах клиента и удаляется вместе с посылкой
+func testIdempotencyKey(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+	other := storetest.Client().Add(t, clients).ID
+	num := storetest.Parcel().Client(client).Add(t, repo).Number
+
+	key := models.IdempotencyKey{Client: client, Key: "order-1", Fingerprint: "abc", Parcel: num, CreatedAt: clocktest.Epoch}
+	require.NoError(t, repo.AddIdempotencyKey(ctx, key))
+
+	stored, err := repo.GetIdempotencyKey(ctx, client, "order-1")
+	require.NoError(t, err)
+	assert.Equal(t, key, stored)
+
+	// у другого клиента такого ключа нет, и он может использовать его сам
+	_, err = repo.GetIdempotencyKey(ctx, other, "order-1")
+	require.ErrorIs(t, err, errors.ErrNotFound)
+	otherNum := storetest.Parcel().Client(other).Add(t, repo).Number
+	require.NoError(t, repo.AddIdempotencyKey(ctx, models.IdempotencyKey{
+		Client: other, Key: "order-1", Fingerprint: "def", Parcel: otherNum, CreatedAt: clocktest.Epoch,
+	}))
+
+	// повторное сохранение ключа клиента отклоняется
+	require.Error(t, repo.AddIdempotencyKey(ctx, key))
+
+	// ключ удаленной посылки остается занятым и удаляется вместе с ней при окончательном удалении
+	deletedAt := clocktest.Epoch.Add(time.Hour)
+	require.NoError(t, repo.Delete(ctx, num, deletedAt))
+	_, err = repo.GetIdempotencyKey(ctx, client, "order-1")
+	require.NoError(t, err)
+	_, err = repo.Purge(ctx, deletedAt.Add(time.Second))
+	require.NoError(t, err)
+	_, err = repo.GetIdempotencyKey(ctx, client, "order-1")
+	require.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// testAddBatch проверяет пакетное добавление посылок: все или ни одной
+func testAddBatch(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+
+	parcels := []models.Parcel{
+		storetest.Parcel().Client(client).TrackingCode("RR000000015RU").Build(),
+		storetest.Parcel().Client(client).Address("Москва, ул. Тверская, д. 1").Build(),
+		storetest.Parcel().Client(client).Weight(2500).Build(),
+	}
+	numbers, err := repo.AddBatch(ctx, parcels)
+	require.NoError(t, err)
+	require.Len(t, numbers, len(parcels))
+
+	for i, number := range numbers {
+		parcels[i].Number = number
+		storedParcel, err := repo.Get(ctx, number)
+		require.NoError(t, err)
+		assert.Equal(t, parcels[i], storedParcel)
+	}
+
+	// посылка с занятым кодом отслеживания отменяет добавление всего пакета
+	_, err = repo.AddBatch(ctx, []models.Parcel{
+		storetest.Parcel().Client(client).Build(),
+		storetest.Parcel().Client(client).TrackingCode("RR000000015RU").Build(),
+	})
+	require.Error(t, err)
+
+	stored, err := repo.GetByClient(ctx, client)
+	require.NoError(t, err)
+	assert.Len(t, stored, len(parcels))
+
+	// пустой пакет
+	numbers, err = repo.AddBatch(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, numbers)
+}
+
+// testSetStatusBatch проверяет пакетную условную смену статуса: посылка, которой нет
+// или статус которой не равен ожидаемому, получает свою ошибку, остальные переводятся
+func testSetStatusBatch(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+	first := storetest.Parcel().Client(client).Add(t, repo).Number
+	second := storetest.Parcel().Client(client).Add(t, repo).Number
+	sentAt := clocktest.Epoch.Add(time.Hour)
+
+	failures, err := repo.SetStatusBatch(ctx, []store.StatusChange{
+		{Number: first, From: constants.ParcelStatusRegistered},
+		{Number: second, From: constants.ParcelStatusRegistered},
+	}, constants.ParcelStatusSent, sentAt)
+	require.NoError(t, err)
+	assert.Equal(t, []error{nil, nil}, failures)
+	for _, number := range []int{first, second} {
+		storedParcel, err := repo.Get(ctx, number)
+		require.NoError(t, err)
+		assert.Equal(t, constants.ParcelStatusSent, storedParcel.Status)
+		require.NotNil(t, storedParcel.SentAt)
+		assert.Equal(t, sentAt, *storedParcel.SentAt)
+	}
+
+	// посылка с другим текущим статусом и несуществующая посылка не мешают перевести остальные
+	deliveredAt := sentAt.Add(time.Hour)
+	failures, err = repo.SetStatusBatch(ctx, []store.StatusChange{
+		{Number: first, From: constants.ParcelStatusRegistered},
+		{Number: first + second + 100, From: constants.ParcelStatusSent},
+		{Number: second, From: constants.ParcelStatusSent},
+	}, constants.ParcelStatusDelivered, deliveredAt)
+	require.NoError(t, err)
+	require.Len(t, failures, 3)
+	var stateErr *errors.StateError
+	require.ErrorAs(t, failures[0], &stateErr)
+	assert.Equal(t, constants.ParcelStatusSent, stateErr.Status)
+	assert.ErrorIs(t, failures[1], errors.ErrNotFound)
+	assert.NoError(t, failures[2])
+
+	storedParcel, err := repo.Get(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusSent, storedParcel.Status)
+	assert.Nil(t, storedParcel.DeliveredAt)
+	storedParcel, err = repo.Get(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusDelivered, storedParcel.Status)
+	require.NotNil(t, storedParcel.DeliveredAt)
+	assert.Equal(t, deliveredAt, *storedParcel.DeliveredAt)
+
+	// события пакета записываются в историю по порядку
+	require.NoError(t, repo.AddEvents(ctx, []models.StatusEvent{
+		{Parcel: second, From: constants.ParcelStatusRegistered, To: constants.ParcelStatusSent, At: sentAt},
+		{Parcel: second, From: constants.ParcelStatusSent, To: constants.ParcelStatusDelivered, At: deliveredAt},
+	}))
+	events, err := repo.Events(ctx, second)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, constants.ParcelStatusSent, events[0].To)
+	assert.Equal(t, constants.ParcelStatusDelivered, events[1].To)
+}
+
+// testAddGetDelete проверяет добавление, получение и удаление посылки
+func testAddGetDelete(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+	// получаем тестовый экземпляр посылки со всеми сторонами и адресом возврата
+	parcel := storetest.Parcel().Client(client).
+		Sender(models.Party{
+			Name:    "ООО Ромашка",
+			Phone:   "+79991234567",
+			Address: models.Address{PostalCode: "180000", City: "Псков", Street: "ул. Садовая", House: "2"},
+		}).
+		Recipient(models.Party{
+			Name:    "Иванов Иван",
+			Phone:   "+79997654321",
+			Address: models.Address{City: "Москва", Street: "ул. Тверская", House: "1", Apartment: "5"},
+		}).
+		ReturnAddress("Псков, ул. Садовая, д. 3").
+		TrackingCode("RR123456785RU").
+		ServiceLevel(constants.ServiceLevelExpress).
+		Weight(2500).
+		Dimensions(40, 30, 20).
+		Build()
+	parcel.Contents = "книги"
+	parcel.DeclaredValue = 150000
+	parcel.Cost = 50000
 
 	// add
 	// добавляем новую посылку в БД, проверяем отсутствие ошибки и наличие идентификатора
-	num, err := store.Add(parcel)
+	num, err := repo.Add(ctx, parcel)
 	require.NoError(t, err)  // убеждаемся в отсутствии ошибки
 	require.NotEmpty(t, num) // убеждаемся в наличии идентификатора
 
 	// get
 	// получаем только что добавленную посылку, проверяем отсутствие ошибки
-	storedParcel, err := store.Get(num)
+	storedParcel, err := repo.Get(ctx, num)
 	require.NoError(t, err) // убеждаемся в отсутствии ошибки
 
 	// проверяем, что значения всех полей в полученном объекте совпадают со значениями полей в переменной parcel
@@ -71,97 +278,177 @@ func TestAddGetDelete(t *testing.T) {
 
 	// delete
 	// удалите добавленную посылку, убедитесь в отсутствии ошибки
-	err = store.Delete(num)
+	err = repo.Delete(ctx, num, clocktest.Epoch.Add(time.Hour))
 	require.NoError(t, err) // убеждаемся в отсутствии ошибки
 
 	// проверьте, что посылку больше нельзя получить из БД
-	_, err = store.Get(num)
-	require.Error(t, err)                 // если мы запрашиваем из БД несуществующую посылку, должна вернуться ошибка
-	assert.ErrorIs(t, err, sql.ErrNoRows) // проверяем, что по крайней мере одна ошибка из соответствующей цепи ошибок err равна sql.ErrNoRows
+	_, err = repo.Get(ctx, num)
+	require.Error(t, err)                      // если мы запрашиваем из БД несуществующую посылку, должна вернуться ошибка
+	assert.ErrorIs(t, err, errors.ErrNotFound) // проверяем, что по крайней мере одна ошибка из соответствующей цепи ошибок err равна ErrNotFound
+
+	// повторное удаление и изменение удаленной посылки также сообщают об ее отсутствии
+	at := clocktest.Epoch.Add(time.Hour)
+	assert.ErrorIs(t, repo.Delete(ctx, num, at), errors.ErrNotFound)
+	assert.ErrorIs(t, repo.SetAddress(ctx, num, parcel.Recipient.Address, at), errors.ErrNotFound)
+	assert.ErrorIs(t, repo.SetStatus(ctx, num, constants.ParcelStatusSent, at), errors.ErrNotFound)
 
 }
 
-// TestSetAddress проверяет обновление адреса
-func TestSetAddress(t *testing.T) {
-	// подключаемся к БД
-	db, err := sql.Open("sqlite", "../../../tracker.db")
+// testRestorePurge проверяет, что удаленная посылка видна только в выборке
+// с IncludeDeleted, восстанавливается и окончательно удаляется по истечении срока хранения
+func testRestorePurge(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+
+	first := storetest.Parcel().Client(client).TrackingCode("RR123456785RU").Add(t, repo)
+	second := storetest.Parcel().Client(client).Add(t, repo)
+	sent := storetest.Parcel().Client(client).Status(constants.ParcelStatusSent).Add(t, repo)
+	require.NoError(t, repo.AddEvent(ctx, models.StatusEvent{Parcel: first.Number, To: constants.ParcelStatusRegistered,
+		At: clocktest.Epoch}))
+
+	firstDeleted := clocktest.Epoch.Add(time.Hour)
+	secondDeleted := clocktest.Epoch.Add(2 * time.Hour)
+	require.NoError(t, repo.Delete(ctx, first.Number, firstDeleted))
+	require.NoError(t, repo.Delete(ctx, second.Number, secondDeleted))
+
+	// удаленные посылки не видны при поиске и в выборке по умолчанию
+	_, err := repo.Get(ctx, first.Number)
+	require.ErrorIs(t, err, errors.ErrNotFound)
+	_, err = repo.GetByTrackingCode(ctx, "RR123456785RU")
+	require.ErrorIs(t, err, errors.ErrNotFound)
+	byClient, err := repo.GetByClient(ctx, client)
+	require.NoError(t, err)
+	require.Len(t, byClient, 1)
+	assert.Equal(t, sent.Number, byClient[0].Number)
+
+	page, err := repo.List(ctx, models.ParcelFilter{Client: client})
+	require.NoError(t, err)
+	require.Len(t, page.Parcels, 1)
+	assert.Equal(t, sent.Number, page.Parcels[0].Number)
+
+	page, err = repo.List(ctx, models.ParcelFilter{Client: client, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, page.Parcels, 3)
+	require.NotNil(t, page.Parcels[0].DeletedAt)
+	assert.Equal(t, firstDeleted, *page.Parcels[0].DeletedAt)
+	assert.Equal(t, firstDeleted, page.Parcels[0].UpdatedAt)
+	assert.Nil(t, page.Parcels[2].DeletedAt)
+
+	// код отслеживания удаленной посылки остается занятым
+	_, err = repo.Add(ctx, storetest.Parcel().Client(client).TrackingCode("RR123456785RU").Build())
+	require.Error(t, err)
+
+	// восстановить можно только удаленную посылку
+	assert.ErrorIs(t, repo.Restore(ctx, sent.Number, secondDeleted), errors.ErrInvalidState)
+	assert.ErrorIs(t, repo.Restore(ctx, 100500, secondDeleted), errors.ErrNotFound)
+
+	restoredAt := clocktest.Epoch.Add(3 * time.Hour)
+	require.NoError(t, repo.Restore(ctx, second.Number, restoredAt))
+	restored, err := repo.Get(ctx, second.Number)
 	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, restoredAt, restored.UpdatedAt)
 
-	defer db.Close()
+	// окончательно удаляются только посылки, удаленные раньше границы, вместе с историей
+	require.NoError(t, repo.Delete(ctx, second.Number, restoredAt))
+	n, err := repo.Purge(ctx, secondDeleted)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.ErrorIs(t, repo.Restore(ctx, first.Number, restoredAt), errors.ErrNotFound)
+	events, err := repo.Events(ctx, first.Number)
+	require.NoError(t, err)
+	assert.Empty(t, events)
 
-	// получаем экземпляр ParcelStore
-	store := NewParcelStore(db)
+	page, err = repo.List(ctx, models.ParcelFilter{Client: client, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, page.Parcels, 2)
+	assert.Equal(t, second.Number, page.Parcels[0].Number)
+
+	n, err = repo.Purge(ctx, secondDeleted)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+// testSetAddress проверяет обновление адреса
+func testSetAddress(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
 	// получаем тестовый экземпляр посылки
-	parcel := getTestParcel()
+	parcel := storetest.Parcel().Client(client).Build()
 
 	// add
 	// добавляем новую посылку в БД, проверяем отсутствие ошибки и наличие идентификатора
-	num, err := store.Add(parcel)
+	num, err := repo.Add(ctx, parcel)
 	require.NoError(t, err)  // убеждаемся в отсутствии ошибки
 	require.NotEmpty(t, num) // убеждаемся в наличии идентификатора
 
 	// set address
 	// обновите адрес, убедитесь в отсутствии ошибки
-	newAddress := "new test address"
-	err = store.SetAddress(num, newAddress)
+	newAddress := models.Address{PostalCode: "180000", City: "Псков", Street: "ул. Новая", House: "7", Apartment: "3"}
+	at := parcel.CreatedAt.Add(time.Minute)
+	err = repo.SetAddress(ctx, num, newAddress, at)
 	require.NoError(t, err) // убеждаемся в отсутствии ошибки
 
 	// check
-	// получаем добавленную посылку, проверяем, что адрес обновился
-	storedParcel, err := store.Get(num)
-	require.NoError(t, err)                           // проверяем, что при получении посылки не возникло ошибки
-	assert.Equal(t, newAddress, storedParcel.Address) // проверяем, что адрес посылки изменился на предполагаемый
+	// получаем добавленную посылку, проверяем, что адрес и время изменения обновились
+	storedParcel, err := repo.Get(ctx, num)
+	require.NoError(t, err)                                     // проверяем, что при получении посылки не возникло ошибки
+	assert.Equal(t, newAddress, storedParcel.Recipient.Address) // проверяем, что адрес посылки изменился на предполагаемый
+	assert.Equal(t, at, storedParcel.UpdatedAt)
+	assert.Equal(t, parcel.CreatedAt, storedParcel.CreatedAt)
 }
 
-// TestSetStatus проверяет обновление статуса, а также
+// testSetStatus проверяет обновление статуса, а также
 // то, что мы не можем изменить адрес посылки или удалить ее,
 // если статус посылки не равен `зарегистрирована`
-func TestSetStatus(t *testing.T) {
-	// подключаемся к БД
-	db, err := sql.Open("sqlite", "../../../tracker.db")
-	require.NoError(t, err)
-
-	defer db.Close()
-
-	// получаем экземпляр ParcelStore
-	store := NewParcelStore(db)
+func testSetStatus(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
 	// получаем тестовый экземпляр посылки
-	parcel := getTestParcel()
+	parcel := storetest.Parcel().Client(client).Build()
 
 	// add
 	// добавляем новую посылку в БД, проверяем отсутствие ошибки и наличие идентификатора
-	num, err := store.Add(parcel)
+	num, err := repo.Add(ctx, parcel)
 	require.NoError(t, err)  // убеждаемся в отсутствии ошибки
 	require.NotEmpty(t, num) // убеждаемся в наличии идентификатора
 
 	// set status
 	// обновляем статус, проверяем отсутствие ошибки
-	err = store.SetStatus(num, constants.ParcelStatusSent)
+	sentAt := parcel.CreatedAt.Add(time.Hour)
+	err = repo.SetStatus(ctx, num, constants.ParcelStatusSent, sentAt)
 	require.NoError(t, err) // убеждаемся в отсутствии ошибки
 
 	// check
-	// получаем добавленную посылку и убеждаемся, что статус обновился
-	storedParcel, err := store.Get(num)
+	// получаем добавленную посылку и убеждаемся, что статус и время отправки обновились
+	storedParcel, err := repo.Get(ctx, num)
 	require.NoError(t, err)                                           // убеждаемся в отсутствии ошибки
 	require.Equal(t, constants.ParcelStatusSent, storedParcel.Status) // проверяем, что статус обновился
+	require.NotNil(t, storedParcel.SentAt)
+	assert.Equal(t, sentAt, *storedParcel.SentAt)
+	assert.Nil(t, storedParcel.DeliveredAt)
 
 	// проверяем, что нельзя изменить адрес, если статус посылки не равен `зарегистрирована`
-	newAddress := "new test address"
-	oldAddress := "test"
-	err = store.SetAddress(num, newAddress)
-	// убеждаемся, что вернулась ошибка
-	// и она равна ErrUnsuccessful
-	assert.ErrorIs(t, err, errors.ErrUnsuccessful)
+	newAddress := models.Address{City: "Псков", Street: "ул. Новая", House: "7"}
+	oldAddress := parcel.Recipient.Address
+	err = repo.SetAddress(ctx, num, newAddress, sentAt.Add(time.Minute))
+	// убеждаемся, что вернулась ошибка недопустимой операции
+	// и в ней указан текущий статус посылки
+	var stateErr *errors.StateError
+	require.ErrorAs(t, err, &stateErr)
+	assert.Equal(t, constants.ParcelStatusSent, stateErr.Status)
+	assert.ErrorIs(t, err, errors.ErrInvalidState)
 	// проверяем, что адрес посылки не изменился
-	storedParcel, err = store.Get(num)
-	require.NoError(t, err)                            // убеждаемся в отсутствии ошибки
-	require.Equal(t, oldAddress, storedParcel.Address) // убеждаемся, что адрес не изменился
+	storedParcel, err = repo.Get(ctx, num)
+	require.NoError(t, err)                                      // убеждаемся в отсутствии ошибки
+	require.Equal(t, oldAddress, storedParcel.Recipient.Address) // убеждаемся, что адрес не изменился
 
 	// проверяем, что мы не можем удалить посылку, если ее статус не равен `зарегистрирована`
-	err = store.Delete(num)
+	err = repo.Delete(ctx, num, sentAt.Add(time.Minute))
 	// убеждаемся, что вернулась ошибка
-	// и она равна ErrUnsuccessful
-	assert.ErrorIs(t, err, errors.ErrUnsuccessful)
+	// и она равна ErrInvalidState
+	assert.ErrorIs(t, err, errors.ErrInvalidState)
 	// проверяем, что мы по-прежнему можем получить посылку из БД
 	testParcel := parcel
 	// устанавливаем значение поля Number посылки testParcel
@@ -169,51 +456,417 @@ func TestSetStatus(t *testing.T) {
 	testParcel.Number = num
 	// устанавиливаем статус testParcel равным ParcelStatusSent
 	testParcel.Status = constants.ParcelStatusSent
+	testParcel.UpdatedAt = sentAt
+	testParcel.SentAt = &sentAt
 
-	storedParcel, err = store.Get(num)
+	storedParcel, err = repo.Get(ctx, num)
 	require.NoError(t, err)                   // убеждаемся в отсутствии ошибки
 	assert.Equal(t, testParcel, storedParcel) // проверяем, что поля посылки не изменились
 
 }
 
-// TestGetByClient проверяет получение посылок по идентификатору клиента
-func TestGetByClient(t *testing.T) {
-	// подключаемся к БД
-	db, err := sql.Open("sqlite", "../../../tracker.db")
+// testGetByClient проверяет получение посылок по идентификатору клиента
+func testGetByClient(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	// задаём всем посылкам один и тот же идентификатор клиента
+	client := storetest.Client().Add(t, clients).ID
+
+	// посылка другого клиента не должна попасть в выборку
+	other := storetest.Client().Name("other client").Add(t, clients).ID
+	storetest.Parcel().Client(other).Add(t, repo)
+
+	// add
+	// добавляем посылки клиента, построитель заполняет их номера
+	var parcels []models.Parcel
+	for i := 0; i < 10; i++ {
+		parcels = append(parcels, storetest.Parcel().Client(client).Add(t, repo))
+	}
+
+	// get by client
+	storedParcels, err := repo.GetByClient(ctx, client) // получаем список посылок по идентификатору клиента, сохранённому в переменной client
+	require.NoError(t, err)                             // проверяем отсутствие ошибки
+
+	// проверяем, что получены все посылки клиента в порядке номеров
+	// и значения полей полученных посылок заполнены верно
+	assert.Equal(t, parcels, storedParcels)
+}
+
+// testList проверяет условия выборки, сортировку и постраничный обход списка посылок
+func testList(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+
+	// add
+	// посылки отдельного клиента с разными датами создания, статусами и адресами
+	// и посылка другого клиента, которая не должна попадать в выборку
+	other := storetest.Client().Name("other client").Add(t, clients).ID
+	storetest.Parcel().Client(other).Add(t, repo)
+
+	start := clocktest.Epoch
+	statuses := []string{constants.ParcelStatusRegistered, constants.ParcelStatusSent, constants.ParcelStatusDelivered}
+	base := storetest.Parcel().Client(client)
+	var numbers []int
+	for i := 0; i < 7; i++ {
+		p := base.
+			Status(statuses[i%len(statuses)]).
+			Address(fmt.Sprintf("г. Псков, ул. Лесная, д. %d", i)).
+			// посылки 5 и 6 созданы в один момент: порядок между ними определяет номер
+			CreatedAt(start.Add(time.Duration(7-min(i, 5))*time.Hour)).
+			Add(t, repo)
+		numbers = append(numbers, p.Number)
+	}
+
+	// collect обходит все страницы выборки и возвращает номера посылок
+	collect := func(f models.ParcelFilter) []int {
+		var res []int
+		for {
+			page, err := repo.List(ctx, f)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(page.Parcels), f.Limit)
+			for _, p := range page.Parcels {
+				res = append(res, p.Number)
+			}
+			if page.NextCursor == "" {
+				return res
+			}
+			f.Cursor = page.NextCursor
+		}
+	}
+
+	// по номеру, страницами по 3
+	assert.Equal(t, numbers, collect(models.ParcelFilter{Client: client, Limit: 3}))
+
+	// по убыванию номера
+	desc := collect(models.ParcelFilter{Client: client, Desc: true, Limit: 2})
+	assert.Equal(t, []int{numbers[6], numbers[5], numbers[4], numbers[3], numbers[2], numbers[1], numbers[0]}, desc)
+
+	// по дате создания: посылки созданы в обратном порядке номеров, 5 и 6 - одновременно
+	byDate := collect(models.ParcelFilter{Client: client, OrderBy: models.OrderByCreatedAt, Limit: 2})
+	assert.Equal(t, []int{numbers[5], numbers[6], numbers[4], numbers[3], numbers[2], numbers[1], numbers[0]}, byDate)
+
+	byDateDesc := collect(models.ParcelFilter{Client: client, OrderBy: models.OrderByCreatedAt, Desc: true, Limit: 4})
+	assert.Equal(t, []int{numbers[0], numbers[1], numbers[2], numbers[3], numbers[4], numbers[6], numbers[5]}, byDateDesc)
+
+	// по набору статусов
+	assert.Equal(t, []int{numbers[1], numbers[2], numbers[4], numbers[5]}, collect(models.ParcelFilter{
+		Client:   client,
+		Statuses: []string{constants.ParcelStatusSent, constants.ParcelStatusDelivered},
+		Limit:    10,
+	}))
+
+	// по периоду создания [start+3h, start+5h)
+	assert.Equal(t, []int{numbers[3], numbers[4]}, collect(models.ParcelFilter{
+		Client:      client,
+		CreatedFrom: start.Add(3 * time.Hour),
+		CreatedTo:   start.Add(5 * time.Hour),
+		Limit:       10,
+	}))
+
+	// по подстроке адреса
+	assert.Equal(t, []int{numbers[3]}, collect(models.ParcelFilter{Client: client, AddressContains: "д. 3", Limit: 10}))
+	assert.Empty(t, collect(models.ParcelFilter{Client: client, AddressContains: "%", Limit: 10}))
+
+	// некорректные условия
+	for _, f := range []models.ParcelFilter{
+		{OrderBy: "address"},
+		{Limit: -1},
+		{Limit: store.MaxListLimit + 1},
+		{Cursor: "not a cursor"},
+	} {
+		_, err := repo.List(ctx, f)
+		assert.ErrorIs(t, err, errors.ErrValidation, "%+v", f)
+	}
+
+	// курсор нельзя использовать с другим порядком сортировки
+	page, err := repo.List(ctx, models.ParcelFilter{Client: client, Limit: 1})
 	require.NoError(t, err)
+	require.NotEmpty(t, page.NextCursor)
+	_, err = repo.List(ctx, models.ParcelFilter{Client: client, OrderBy: models.OrderByCreatedAt, Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+}
+
+// testIterate проверяет обход всех посылок выборки без постраничного чтения
+func testIterate(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+	other := storetest.Client().Name("other client").Add(t, clients).ID
 
-	defer db.Close()
+	// посылок больше наибольшего размера страницы List
+	var numbers []int
+	for i := 0; i < store.MaxListLimit+5; i++ {
+		numbers = append(numbers, storetest.Parcel().Client(client).Add(t, repo).Number)
+	}
+	storetest.Parcel().Client(other).Add(t, repo)
+
+	// iterate возвращает номера посылок выборки в порядке обхода
+	iterate := func(f models.ParcelFilter) []int {
+		var res []int
+		require.NoError(t, repo.Iterate(ctx, f, func(p models.Parcel) error {
+			res = append(res, p.Number)
+			return nil
+		}))
+		return res
+	}
 
-	// получаем экземпляр ParcelStore
-	store := NewParcelStore(db)
+	// размер страницы и курсор не ограничивают обход
+	assert.Equal(t, numbers, iterate(models.ParcelFilter{Client: client, Limit: 1, Cursor: "not a cursor"}))
+	desc := iterate(models.ParcelFilter{Client: client, Desc: true})
+	require.Len(t, desc, len(numbers))
+	assert.Equal(t, numbers[len(numbers)-1], desc[0])
+
+	// ошибка fn прекращает обход
+	errStop := stderrors.New("stop")
+	visited := 0
+	err := repo.Iterate(ctx, models.ParcelFilter{}, func(p models.Parcel) error {
+		visited++
+		if visited == 3 {
+			return errStop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+	assert.Equal(t, 3, visited)
+
+	// некорректные условия
+	err = repo.Iterate(ctx, models.ParcelFilter{OrderBy: "address"}, func(models.Parcel) error { return nil })
+	assert.ErrorIs(t, err, errors.ErrValidation)
+}
 
-	parcels := []models.Parcel{
-		getTestParcel(),
-		getTestParcel(),
-		getTestParcel(),
+// testRecipientParcels проверяет выборку посылок получателя по телефону:
+// посылки одного получателя могут быть зарегистрированы разными клиентами
+func testRecipientParcels(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	first := storetest.Client().Add(t, clients).ID
+	second := storetest.Client().Name("second client").Add(t, clients).ID
+
+	recipient := models.Party{
+		Name:    "Иванов Иван",
+		Phone:   "+79991234567",
+		Address: models.Address{City: "Псков", Street: "ул. Лесная", House: "1"},
 	}
-	// задаём всем посылкам один и тот же идентификатор клиента
-	client := randRange.Intn(10_000_000)
+	other := recipient
+	other.Phone = "+79997654321"
+
+	p1 := storetest.Parcel().Client(first).Recipient(recipient).Add(t, repo)
+	storetest.Parcel().Client(first).Recipient(other).Add(t, repo)
+	p3 := storetest.Parcel().Client(second).Recipient(recipient).Add(t, repo)
+
+	page, err := repo.List(ctx, models.ParcelFilter{RecipientPhone: recipient.Phone})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Parcel{p1, p3}, page.Parcels)
+
+	// вместе с другими условиями
+	page, err = repo.List(ctx, models.ParcelFilter{Client: second, RecipientPhone: recipient.Phone})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Parcel{p3}, page.Parcels)
+
+	page, err = repo.List(ctx, models.ParcelFilter{RecipientPhone: "+70000000000"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Parcels)
+}
+
+// testStatusEvents проверяет смену статуса с записью события в историю в одной транзакции
+func testStatusEvents(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
 
 	// add
-	for i := 0; i < len(parcels); i++ {
-		// задаём всем посылкам один и тот же идентификатор клиента
-		parcels[i].Client = client
+	num := storetest.Parcel().Client(client).Add(t, repo).Number
 
-		num, err := store.Add(parcels[i]) // добавляем новую посылку в БД, убеждаемся в отсутствии ошибки и наличии идентификатора
-		require.NoError(t, err)           // убеждаемся в отсутствии ошибки
-		require.NotEmpty(t, num)          // убеждаемся в наличии идентификатора
+	// у новой посылки история пустая
+	events, err := repo.Events(ctx, num)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// record status
+	// переводим посылку по двум статусам, записывая события
+	recorded := []models.StatusEvent{
+		{
+			Parcel: num,
+			From:   constants.ParcelStatusRegistered,
+			To:     constants.ParcelStatusSent,
+			At:     clocktest.Epoch.Add(time.Hour),
+			Actor:  "operator",
+		},
+		{
+			Parcel:   num,
+			From:     constants.ParcelStatusSent,
+			To:       constants.ParcelStatusInTransit,
+			At:       clocktest.Epoch.Add(26 * time.Hour),
+			Actor:    "courier",
+			Location: "сортировочный центр",
+			Comment:  "принята к перевозке",
+		},
+	}
+	for _, ev := range recorded {
+		err := repo.WithTx(ctx, func(tx store.ParcelRepository) error {
+			if err := tx.CompareAndSetStatus(ctx, ev.Parcel, ev.From, ev.To, ev.At); err != nil {
+				return err
+			}
+			return tx.AddEvent(ctx, ev)
+		})
+		require.NoError(t, err)
+	}
 
-		// обновляем идентификатор у добавленной посылки
-		parcels[i].Number = num
+	// check
+	// статус посылки изменился, события вернулись в хронологическом порядке
+	storedParcel, err := repo.Get(ctx, num)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusInTransit, storedParcel.Status)
+
+	events, err = repo.Events(ctx, num)
+	require.NoError(t, err)
+	require.Len(t, events, len(recorded))
+	for i := range events {
+		assert.NotEmpty(t, events[i].ID)
+		recorded[i].ID = events[i].ID
+		assert.Equal(t, recorded[i], events[i])
 	}
+}
 
-	// get by client
-	storedParcels, err := store.GetByClient(client) // получаем список посылок по идентификатору клиента, сохранённому в переменной client
-	require.NoError(t, err)                         // проверяем отсутствие ошибки
+// testDeletedEvents проверяет, что история удаленной посылки не видна, пока посылку не восстановят
+func testDeletedEvents(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+	num := storetest.Parcel().Client(client).Add(t, repo).Number
+	require.NoError(t, repo.AddEvent(ctx, models.StatusEvent{Parcel: num, To: constants.ParcelStatusRegistered,
+		At: clocktest.Epoch}))
+
+	deletedAt := clocktest.Epoch.Add(time.Hour)
+	require.NoError(t, repo.Delete(ctx, num, deletedAt))
+	events, err := repo.Events(ctx, num)
+	require.NoError(t, err)
+	assert.Empty(t, events)
 
-	// проверяем, что количество элементов в слайсах parcels и storedParcels равно, и каждому элементу из одного слайса
-	// есть соответсвующий равный элемент из другого слайса, то есть все посылки из storedParcels есть в parcels
-	// и значения полей полученных посылок заполнены верно
-	assert.ElementsMatch(t, parcels, storedParcels)
+	require.NoError(t, repo.Restore(ctx, num, deletedAt.Add(time.Hour)))
+	events, err = repo.Events(ctx, num)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, constants.ParcelStatusRegistered, events[0].To)
+}
+
+// testCompareAndSetStatus проверяет, что статус меняется, только если текущий статус совпадает с ожидаемым
+func testCompareAndSetStatus(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+
+	// add
+	num := storetest.Parcel().Client(client).Add(t, repo).Number
+
+	// compare and set
+	at := clocktest.Epoch.Add(time.Hour)
+	require.NoError(t, repo.CompareAndSetStatus(ctx, num, constants.ParcelStatusRegistered, constants.ParcelStatusSent, at))
+
+	// повторный перевод из прежнего статуса не выполняется, ошибка сообщает текущий статус
+	err := repo.CompareAndSetStatus(ctx, num, constants.ParcelStatusRegistered, constants.ParcelStatusCancelled, at)
+	require.ErrorIs(t, err, errors.ErrInvalidState)
+	var stateErr *errors.StateError
+	require.ErrorAs(t, err, &stateErr)
+	assert.Equal(t, constants.ParcelStatusSent, stateErr.Status)
+
+	storedParcel, err := repo.Get(ctx, num)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusSent, storedParcel.Status)
+
+	// несуществующая посылка
+	err = repo.CompareAndSetStatus(ctx, num+1_000_000, constants.ParcelStatusRegistered, constants.ParcelStatusSent, at)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// testWithTxRollback проверяет, что ошибка в WithTx отменяет все изменения транзакции
+func testWithTxRollback(t *testing.T, repo store.ParcelRepository, clients store.ClientRepository) {
+	ctx := context.Background()
+	client := storetest.Client().Add(t, clients).ID
+
+	// add
+	num := storetest.Parcel().Client(client).Add(t, repo).Number
+
+	// меняем статус и записываем событие, после чего транзакция завершается ошибкой
+	errAbort := stderrors.New("abort")
+	err := repo.WithTx(ctx, func(tx store.ParcelRepository) error {
+		if err := tx.CompareAndSetStatus(ctx, num, constants.ParcelStatusRegistered, constants.ParcelStatusSent,
+			clocktest.Epoch.Add(time.Hour)); err != nil {
+			return err
+		}
+		if err := tx.AddEvent(ctx, models.StatusEvent{
+			Parcel: num,
+			From:   constants.ParcelStatusRegistered,
+			To:     constants.ParcelStatusSent,
+			At:     clocktest.Epoch.Add(time.Hour),
+			Actor:  "operator",
+		}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	// check
+	// ни статус, ни история не изменились
+	storedParcel, err := repo.Get(ctx, num)
+	require.NoError(t, err)
+	assert.Equal(t, constants.ParcelStatusRegistered, storedParcel.Status)
+
+	events, err := repo.Events(ctx, num)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+// TestCanceledContext проверяет, что отмененный контекст прерывает запросы к БД
+func TestCanceledContext(t *testing.T) {
+	t.Parallel()
+
+	// получаем экземпляры хранилищ поверх новой БД
+	s := storetest.NewSQLite(t)
+	repo := s.Parcels
+
+	// отменяем контекст до выполнения запросов
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.Add(ctx, storetest.Parcel().Build())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = repo.Get(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = repo.GetByClient(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = s.Clients.Create(ctx, storetest.Client().Build())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = repo.SetStatus(ctx, 1, constants.ParcelStatusSent, clocktest.Epoch)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestPurgeWithoutForeignKeys проверяет, что Purge удаляет историю и ключи идемпотентности
+// посылок и в БД, открытой без проверки внешних ключей, где ON DELETE CASCADE не действует
+func TestPurgeWithoutForeignKeys(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tracker.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, store.Migrate(ctx, db, store.SQLite))
+
+	repo := store.NewParcelStore(db)
+	client := storetest.Client().Add(t, store.NewClientStore(db, store.SQLite)).ID
+	parcel := storetest.Parcel().Client(client).Add(t, repo)
+	require.NoError(t, repo.AddEvent(ctx, models.StatusEvent{Parcel: parcel.Number, To: constants.ParcelStatusRegistered,
+		At: clocktest.Epoch}))
+	require.NoError(t, repo.AddIdempotencyKey(ctx, models.IdempotencyKey{Client: client, Key: "order-1",
+		Fingerprint: "abc", Parcel: parcel.Number, CreatedAt: clocktest.Epoch}))
+	require.NoError(t, repo.Delete(ctx, parcel.Number, clocktest.Epoch))
+
+	n, err := repo.Purge(ctx, clocktest.Epoch.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	for _, table := range []string{"parcel_event", "parcel_idempotency"} {
+		var rows int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&rows))
+		assert.Zero(t, rows, table)
+	}
 }
@@ -0,0 +1,242 @@
+package storetest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/address"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/clock/clocktest"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store"
+)
+
+// в пакете собраны помощники для тестов хранилища и сервисов поверх него:
+// отдельная БД SQLite со схемой актуальной версии на каждый тест
+// и построители тестовых данных. Тесты не разделяют БД между собой,
+// поэтому могут выполняться параллельно (t.Parallel) и не оставляют после себя строк
+
+// функция OpenSQLite создает новую БД SQLite во временном каталоге теста,
+// приводит ее схему к актуальной версии и возвращает подключение.
+// Подключение закрывается, а файл удаляется по завершении теста
+// Параметры
+// t - тест, которому принадлежит БД
+func OpenSQLite(t testing.TB) store.Database {
+	t.Helper()
+
+	db, err := store.Open(context.Background(), filepath.Join(t.TempDir(), "tracker.db"))
+	if err != nil {
+		t.Fatalf("не удалось открыть тестовую БД: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// определяем структурный тип Stores - хранилища посылок и клиентов с общими данными
+type Stores struct {
+	Parcels store.ParcelRepository // хранилище посылок
+	Clients store.ClientRepository // хранилище клиентов, на которых ссылаются посылки
+}
+
+// функция NewSQLite возвращает хранилища поверх новой БД OpenSQLite
+// Параметры
+// t - тест, которому принадлежит БД
+func NewSQLite(t testing.TB) Stores {
+	t.Helper()
+
+	db := OpenSQLite(t)
+
+	return Stores{Parcels: db.Parcels(), Clients: db.Clients()}
+}
+
+// функция NewMemory возвращает новые хранилища в памяти процесса
+func NewMemory() Stores {
+	m := store.NewMemoryStore()
+
+	return Stores{Parcels: m, Clients: m.Clients()}
+}
+
+// определяем структурный тип ClientBuilder - построитель тестового клиента,
+// методы возвращают измененную копию, как и у ParcelBuilder
+type ClientBuilder struct {
+	client models.Client
+}
+
+// функция Client возвращает построитель активного клиента "test client"
+// без контактов, созданного в момент clocktest.Epoch
+func Client() ClientBuilder {
+	return ClientBuilder{client: models.Client{
+		Name:      "test client",
+		Active:    true,
+		CreatedAt: clocktest.Epoch,
+		UpdatedAt: clocktest.Epoch,
+	}}
+}
+
+// Метод Name типа ClientBuilder задает имя клиента
+func (b ClientBuilder) Name(name string) ClientBuilder {
+	b.client.Name = name
+	return b
+}
+
+// Метод Phone типа ClientBuilder задает телефон клиента
+func (b ClientBuilder) Phone(phone string) ClientBuilder {
+	b.client.Phone = phone
+	return b
+}
+
+// Метод Email типа ClientBuilder задает адрес электронной почты клиента
+func (b ClientBuilder) Email(email string) ClientBuilder {
+	b.client.Email = email
+	return b
+}
+
+// Метод Inactive типа ClientBuilder делает клиента деактивированным
+func (b ClientBuilder) Inactive() ClientBuilder {
+	b.client.Active = false
+	return b
+}
+
+// Метод Build типа ClientBuilder возвращает клиента без идентификатора
+func (b ClientBuilder) Build() models.Client {
+	return b.client
+}
+
+// Метод Add типа ClientBuilder добавляет клиента в хранилище repo
+// и возвращает его с присвоенным идентификатором
+// Параметры
+// t - тест, ошибка добавления завершает его
+// repo - хранилище клиентов
+func (b ClientBuilder) Add(t testing.TB, repo store.ClientRepository) models.Client {
+	t.Helper()
+
+	c := b.Build()
+	id, err := repo.Create(context.Background(), c)
+	if err != nil {
+		t.Fatalf("не удалось добавить тестового клиента: %v", err)
+	}
+	c.ID = id
+
+	return c
+}
+
+// определяем структурный тип ParcelBuilder - построитель тестовой посылки.
+// Методы возвращают измененную копию, поэтому один построитель
+// можно использовать как основу для нескольких посылок
+type ParcelBuilder struct {
+	parcel models.Parcel
+}
+
+// функция Parcel возвращает построитель зарегистрированной посылки стандартной доставки весом 1 кг
+// для получателя "Получатель" по адресу "Псков, ул. Лесная, д. 1", созданной в момент clocktest.Epoch.
+// Клиента посылки задает метод Client: хранилища не принимают посылки несуществующих клиентов
+func Parcel() ParcelBuilder {
+	return ParcelBuilder{parcel: models.Parcel{
+		Status:       constants.ParcelStatusRegistered,
+		ServiceLevel: constants.ServiceLevelStandard,
+		Weight:       1000,
+		Recipient: models.Party{
+			Name:    "Получатель",
+			Address: models.Address{City: "Псков", Street: "ул. Лесная", House: "1"},
+		},
+		CreatedAt: clocktest.Epoch,
+		UpdatedAt: clocktest.Epoch,
+	}}
+}
+
+// Метод Client типа ParcelBuilder задает клиента посылки
+func (b ParcelBuilder) Client(client int) ParcelBuilder {
+	b.parcel.Client = client
+	return b
+}
+
+// Метод TrackingCode типа ParcelBuilder задает код отслеживания посылки
+func (b ParcelBuilder) TrackingCode(code string) ParcelBuilder {
+	b.parcel.TrackingCode = code
+	return b
+}
+
+// Метод Status типа ParcelBuilder задает статус посылки
+func (b ParcelBuilder) Status(status string) ParcelBuilder {
+	b.parcel.Status = status
+	return b
+}
+
+// Метод Address типа ParcelBuilder задает адрес получателя посылки одной строкой,
+// части адреса получаются разбором строки address.Parse
+func (b ParcelBuilder) Address(line string) ParcelBuilder {
+	b.parcel.Recipient.Address = address.Parse(line)
+	return b
+}
+
+// Метод Recipient типа ParcelBuilder задает получателя посылки
+func (b ParcelBuilder) Recipient(p models.Party) ParcelBuilder {
+	b.parcel.Recipient = p
+	return b
+}
+
+// Метод Sender типа ParcelBuilder задает отправителя посылки
+func (b ParcelBuilder) Sender(p models.Party) ParcelBuilder {
+	b.parcel.Sender = p
+	return b
+}
+
+// Метод ReturnAddress типа ParcelBuilder задает адрес возврата одной строкой
+func (b ParcelBuilder) ReturnAddress(line string) ParcelBuilder {
+	a := address.Parse(line)
+	b.parcel.ReturnAddress = &a
+	return b
+}
+
+// Метод ServiceLevel типа ParcelBuilder задает уровень обслуживания посылки
+func (b ParcelBuilder) ServiceLevel(level string) ParcelBuilder {
+	b.parcel.ServiceLevel = level
+	return b
+}
+
+// Метод Weight типа ParcelBuilder задает вес посылки в граммах
+func (b ParcelBuilder) Weight(grams int) ParcelBuilder {
+	b.parcel.Weight = grams
+	return b
+}
+
+// Метод Dimensions типа ParcelBuilder задает габариты посылки в сантиметрах
+func (b ParcelBuilder) Dimensions(length, width, height int) ParcelBuilder {
+	b.parcel.Dimensions = models.Dimensions{Length: length, Width: width, Height: height}
+	return b
+}
+
+// Метод CreatedAt типа ParcelBuilder задает момент создания посылки,
+// момент изменения совпадает с ним
+func (b ParcelBuilder) CreatedAt(at time.Time) ParcelBuilder {
+	b.parcel.CreatedAt = at
+	b.parcel.UpdatedAt = at
+	return b
+}
+
+// Метод Build типа ParcelBuilder возвращает посылку без номера
+func (b ParcelBuilder) Build() models.Parcel {
+	return b.parcel
+}
+
+// Метод Add типа ParcelBuilder добавляет посылку в хранилище repo
+// и возвращает ее с присвоенным номером
+// Параметры
+// t - тест, ошибка добавления завершает его
+// repo - хранилище
+func (b ParcelBuilder) Add(t testing.TB, repo store.ParcelRepository) models.Parcel {
+	t.Helper()
+
+	p := b.Build()
+	num, err := repo.Add(context.Background(), p)
+	if err != nil {
+		t.Fatalf("не удалось добавить тестовую посылку: %v", err)
+	}
+	p.Number = num
+
+	return p
+}
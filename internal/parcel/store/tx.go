@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// dbtx - общие методы *sql.DB и *sql.Tx, через которые хранилища выполняют запросы.
+// Благодаря ему одни и те же методы хранилища работают как вне транзакции,
+// так и внутри нее (см. WithTx)
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// функция withTx начинает транзакцию в root и передает ее в fn.
+// Если root равен nil, хранилище уже работает внутри транзакции:
+// вложенный вызов выполняется в ней же, без новой транзакции
+// Параметры
+// ctx - контекст запроса
+// root - БД, в которой начинается транзакция, или nil внутри транзакции
+// current - открытая транзакция, в которой выполняется вложенный вызов
+// fn - функция, выполняющая запросы через переданный dbtx
+func withTx(ctx context.Context, root *sql.DB, current dbtx, fn func(db dbtx) error) error {
+	if root == nil {
+		return fn(current)
+	}
+
+	return inTx(ctx, root, func(tx *sql.Tx) error {
+		return fn(tx)
+	})
+}
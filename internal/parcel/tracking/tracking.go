@@ -0,0 +1,196 @@
+package tracking
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// в пакете определены коды отслеживания посылок в формате S10 Всемирного почтового союза:
+// две буквы вида отправления, восемь цифр серийного номера, контрольная цифра
+// и двухбуквенный код страны, например RR123456785RU.
+// В отличие от номера посылки код не последовательный: по нему нельзя угадать
+// коды других посылок или оценить число зарегистрированных отправлений.
+// Контрольная цифра защищает от опечаток: код с ошибкой в одной цифре
+// отклоняется Validate без обращения к БД
+
+// Length - длина кода отслеживания
+const Length = 13
+
+// вид отправления и страна кодов, которые выдает сервис посылок по умолчанию
+const (
+	DefaultService = "RR"
+	DefaultCountry = "RU"
+)
+
+// FieldCode - поле, которое указывается в ошибке проверки кода
+const FieldCode = "tracking_code"
+
+// maxSerial - наибольший серийный номер: восемь цифр
+const maxSerial = 99999999
+
+// weights - веса цифр серийного номера при расчете контрольной цифры
+var weights = [8]int{8, 6, 4, 2, 3, 5, 9, 7}
+
+// функция CheckDigit возвращает контрольную цифру серийного номера по модулю 11:
+// взвешенная сумма цифр вычитается из 11, результат 10 заменяется на 0, а 11 - на 5
+// Параметры
+// serial - серийный номер от 0 до 99999999
+func CheckDigit(serial int) int {
+	sum := 0
+	for i := len(weights) - 1; i >= 0; i-- {
+		sum += serial % 10 * weights[i]
+		serial /= 10
+	}
+
+	switch d := 11 - sum%11; d {
+	case 10:
+		return 0
+	case 11:
+		return 5
+	default:
+		return d
+	}
+}
+
+// функция Format возвращает код отслеживания с серийным номером serial
+// Параметры
+// service - две латинские буквы вида отправления, например DefaultService
+// serial - серийный номер от 0 до 99999999
+// country - двухбуквенный код страны, например DefaultCountry
+func Format(service string, serial int, country string) (string, error) {
+	if !isLetters(service) || !isLetters(country) {
+		return "", fmt.Errorf("вид отправления %q и страна %q должны состоять из двух латинских букв", service, country)
+	}
+	if serial < 0 || serial > maxSerial {
+		return "", fmt.Errorf("серийный номер %d вне диапазона от 0 до %d", serial, maxSerial)
+	}
+
+	return fmt.Sprintf("%s%08d%d%s", service, serial, CheckDigit(serial), country), nil
+}
+
+// функция Normalize приводит введенный код к каноническому виду:
+// убирает пробелы и дефисы, которыми код разбивают на группы, и переводит буквы в верхний регистр
+func Normalize(code string) string {
+	code = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+
+	return strings.ToUpper(code)
+}
+
+// функция Validate проверяет код в каноническом виде (см. Normalize):
+// формат S10 и контрольную цифру. Некорректный код отклоняется с ошибкой errors.ValidationError
+func Validate(code string) error {
+	if len(code) != Length {
+		return errors.Validation(FieldCode,
+			fmt.Sprintf("код должен состоять из %d символов, например RR123456785RU", Length))
+	}
+	if !isLetters(code[:2]) || !isDigits(code[2:11]) || !isLetters(code[11:]) {
+		return errors.Validation(FieldCode, "ожидаются две буквы, девять цифр и две буквы, например RR123456785RU")
+	}
+
+	serial := 0
+	for _, r := range code[2:10] {
+		serial = serial*10 + int(r-'0')
+	}
+	if int(code[10]-'0') != CheckDigit(serial) {
+		return errors.Validation(FieldCode, "неверная контрольная цифра, проверьте код")
+	}
+
+	return nil
+}
+
+// функция isLetters проверяет, что s - две латинские буквы в верхнем регистре
+func isLetters(s string) bool {
+	if len(s) != 2 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// функция isDigits проверяет, что s состоит только из цифр
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Generator выдает коды отслеживания новым посылкам.
+// Генератор не проверяет, занят ли код: это делает сервис посылок
+type Generator interface {
+	Next() (string, error)
+}
+
+// определяем структурный тип randomGenerator - генератор случайных кодов
+type randomGenerator struct {
+	service string // вид отправления
+	country string // код страны
+}
+
+// функция Random возвращает генератор кодов со случайными серийными номерами из crypto/rand
+// Параметры
+// service - вид отправления, например DefaultService
+// country - код страны, например DefaultCountry
+func Random(service string, country string) Generator {
+	return randomGenerator{service: service, country: country}
+}
+
+// Метод Next типа randomGenerator возвращает код со случайным серийным номером
+func (g randomGenerator) Next() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(maxSerial+1))
+	if err != nil {
+		return "", err
+	}
+
+	return Format(g.service, int(n.Int64()), g.country)
+}
+
+// определяем структурный тип sequenceGenerator - генератор кодов с последовательными номерами
+type sequenceGenerator struct {
+	mu      *sync.Mutex // защищает next при конкурентной регистрации
+	next    *int        // серийный номер следующего кода
+	service string      // вид отправления
+	country string      // код страны
+}
+
+// функция Sequence возвращает генератор кодов с последовательными серийными номерами,
+// начиная с first. Коды такого генератора предсказуемы, поэтому он предназначен для тестов
+// Параметры
+// service - вид отправления
+// country - код страны
+// first - серийный номер первого кода
+func Sequence(service string, country string, first int) Generator {
+	return sequenceGenerator{mu: &sync.Mutex{}, next: &first, service: service, country: country}
+}
+
+// Метод Next типа sequenceGenerator возвращает код со следующим серийным номером
+func (g sequenceGenerator) Next() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	code, err := Format(g.service, *g.next, g.country)
+	if err != nil {
+		return "", err
+	}
+	*g.next++
+
+	return code, nil
+}
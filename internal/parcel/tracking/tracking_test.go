@@ -0,0 +1,91 @@
+package tracking
+
+import (
+	// импортируем пакеты standard library
+	"testing"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+)
+
+// TestCheckDigit проверяет контрольную цифру, в том числе замену 10 и 11
+func TestCheckDigit(t *testing.T) {
+	assert.Equal(t, 5, CheckDigit(12345678))
+	assert.Equal(t, 4, CheckDigit(1))
+	assert.Equal(t, 5, CheckDigit(0)) // взвешенная сумма 0: 11 заменяется на 5
+	assert.Equal(t, 0, CheckDigit(8)) // взвешенная сумма 56: 10 заменяется на 0
+}
+
+// TestFormat проверяет построение кода
+func TestFormat(t *testing.T) {
+	code, err := Format(DefaultService, 12345678, DefaultCountry)
+	require.NoError(t, err)
+	assert.Equal(t, "RR123456785RU", code)
+	require.NoError(t, Validate(code))
+
+	code, err = Format("CP", 42, "RU")
+	require.NoError(t, err)
+	assert.Equal(t, "CP00000042", code[:10])
+	require.NoError(t, Validate(code))
+
+	_, err = Format("R", 1, "RU")
+	assert.Error(t, err)
+	_, err = Format("RR", 1, "ru")
+	assert.Error(t, err)
+	_, err = Format("RR", 100000000, "RU")
+	assert.Error(t, err)
+	_, err = Format("RR", -1, "RU")
+	assert.Error(t, err)
+}
+
+// TestValidate проверяет отклонение некорректных кодов
+func TestValidate(t *testing.T) {
+	assert.Equal(t, "RR123456785RU", Normalize(" rr 1234-5678-5 ru"))
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"пустой", ""},
+		{"короткий", "RR12345678RU"},
+		{"цифры вместо букв", "12123456785RU"},
+		{"буквы вместо цифр", "RR1234567A5RU"},
+		{"строчные буквы", "rr123456785ru"},
+		{"опечатка в цифре", "RR123456795RU"},
+		{"перестановка цифр", "RR213456785RU"},
+		{"неверная контрольная цифра", "RR123456784RU"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.code)
+			var validationErr *errors.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, FieldCode, validationErr.Field)
+		})
+	}
+}
+
+// TestGenerators проверяет генераторы кодов
+func TestGenerators(t *testing.T) {
+	random := Random(DefaultService, DefaultCountry)
+	for i := 0; i < 100; i++ {
+		code, err := random.Next()
+		require.NoError(t, err)
+		require.NoError(t, Validate(code), code)
+	}
+
+	sequence := Sequence("CP", "RU", 12345678)
+	code, err := sequence.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "CP123456785RU", code)
+	code, err = sequence.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "CP12345679", code[:10])
+
+	_, err = Sequence("CP", "RU", 100000000).Next()
+	assert.Error(t, err)
+}
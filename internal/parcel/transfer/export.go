@@ -0,0 +1,74 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+)
+
+// функция Export выгружает посылки, удовлетворяющие условиям f, в w в формате format
+// и возвращает число выгруженных посылок. Посылки читаются из хранилища и записываются
+// по одной, поэтому выгрузка не зависит от размера выборки.
+// При ошибке в w может остаться начало выгрузки
+// Параметры
+// ctx - контекст, отмена которого прерывает выгрузку
+// service - сервис посылок
+// f - условия выборки и сортировка, размер страницы и курсор не используются
+// format - FormatCSV или FormatNDJSON
+// w - поток, в который записывается файл
+func Export(ctx context.Context, service serv.ParcelService, f models.ParcelFilter, format string, w io.Writer) (int, error) {
+	if err := CheckFormat(format); err != nil {
+		return 0, err
+	}
+
+	buf := bufio.NewWriter(w)
+
+	var (
+		n     int
+		write func(p models.Parcel) error
+		flush func() error
+	)
+	if format == FormatNDJSON {
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		write = func(p models.Parcel) error { return encoder.Encode(p) }
+		flush = buf.Flush
+	} else {
+		cw := csv.NewWriter(buf)
+		if err := cw.Write(Header()); err != nil {
+			return 0, err
+		}
+		record := make([]string, len(columns))
+		write = func(p models.Parcel) error {
+			for i, c := range columns {
+				record[i] = c.get(p)
+			}
+			return cw.Write(record)
+		}
+		flush = func() error {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			return buf.Flush()
+		}
+	}
+
+	err := service.Iterate(ctx, f, func(p models.Parcel) error {
+		if err := write(p); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	return n, flush()
+}
@@ -0,0 +1,353 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+)
+
+// DefaultBatchSize - число записей в пакете регистрации при загрузке
+const DefaultBatchSize = 100
+
+// наибольшая длина строки файла NDJSON в байтах
+const maxLineSize = 1 << 20
+
+// определяем структурный тип ImportOptions - параметры загрузки посылок
+type ImportOptions struct {
+	Format    string    // FormatCSV или FormatNDJSON
+	DryRun    bool      // только проверить записи, не регистрируя посылки
+	BatchSize int       // число записей в пакете регистрации, 0 - DefaultBatchSize
+	Report    io.Writer // поток для отчета об ошибках в формате CSV, nil - отчет не нужен
+}
+
+// определяем структурный тип ImportResult - итог загрузки посылок
+type ImportResult struct {
+	Records  int `json:"records"`  // прочитано записей
+	Imported int `json:"imported"` // зарегистрировано посылок, при пробной загрузке - прошло проверку
+	Failed   int `json:"failed"`   // записей с ошибками, они перечислены в отчете
+}
+
+// функция Import загружает посылки из r и регистрирует их пакетами (см. ParcelService.RegisterBatch).
+// Запись, которую не удалось разобрать или которая не прошла проверку сервиса, не регистрируется
+// и попадает в отчет об ошибках: CSV с колонками line (номер строки файла), field (поле) и error.
+// Остальные записи загружаются. При пробной загрузке записи только проверяются (см. ParcelService.CheckBatch).
+// Некорректный заголовок CSV и ошибки хранилища прерывают загрузку: посылки предыдущих пакетов
+// остаются зарегистрированными, их число - в ImportResult.Imported
+// Параметры
+// ctx - контекст, отмена которого прерывает загрузку
+// service - сервис посылок
+// r - файл в формате opts.Format
+// opts - параметры загрузки
+func Import(ctx context.Context, service serv.ParcelService, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	if err := CheckFormat(opts.Format); err != nil {
+		return ImportResult{}, err
+	}
+	if opts.BatchSize < 0 {
+		return ImportResult{}, errors.Validation("batch_size", "размер пакета не может быть отрицательным")
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	register := service.RegisterBatch
+	if opts.DryRun {
+		register = service.CheckBatch
+	}
+
+	var records recordReader
+	if opts.Format == FormatNDJSON {
+		records = newNDJSONReader(r)
+	} else {
+		var err error
+		if records, err = newCSVReader(r); err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	rep, err := newReport(opts.Report)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	var (
+		res     ImportResult
+		pending []record
+	)
+	// flush регистрирует накопленный пакет и записывает ошибки его записей в отчет
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+
+		reqs := make([]serv.RegisterRequest, len(pending))
+		for i, rec := range pending {
+			reqs[i] = rec.req
+		}
+
+		results, err := register(ctx, reqs)
+		if err != nil {
+			return err
+		}
+
+		for i, r := range results {
+			if r.Err != nil {
+				res.Failed++
+				rep.add(pending[i].line, r.Err)
+				continue
+			}
+			res.Imported++
+		}
+		pending = pending[:0]
+
+		return nil
+	}
+
+	// отчет дописывается и в том случае, если загрузка прервана
+	err = func() error {
+		for {
+			rec, err := records.next()
+			if err == io.EOF {
+				break
+			}
+
+			var recErr *recordError
+			if stderrors.As(err, &recErr) {
+				res.Records++
+				res.Failed++
+				rep.add(recErr.line, recErr.err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			res.Records++
+			pending = append(pending, rec)
+			if len(pending) == opts.BatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return flush()
+	}()
+	if closeErr := rep.close(); err == nil {
+		err = closeErr
+	}
+
+	return res, err
+}
+
+// определяем структурный тип record - запись файла
+type record struct {
+	line int                  // номер строки файла, с которой начинается запись
+	req  serv.RegisterRequest // запрос на регистрацию посылки
+}
+
+// recordReader читает записи файла
+type recordReader interface {
+	// next возвращает следующую запись, *recordError для записи,
+	// которую не удалось разобрать, и io.EOF в конце файла
+	next() (record, error)
+}
+
+// определяем структурный тип recordError - ошибка разбора одной записи файла,
+// загрузка после нее продолжается
+type recordError struct {
+	line int   // номер строки файла
+	err  error // причина, errors.ValidationError
+}
+
+// Метод Error типа recordError возвращает текст ошибки
+func (e *recordError) Error() string {
+	return fmt.Sprintf("строка %d: %v", e.line, e.err)
+}
+
+// определяем структурный тип csvReader - чтение записей файла CSV
+type csvReader struct {
+	r      *csv.Reader
+	header []column // колонки файла в порядке заголовка
+}
+
+// функция newCSVReader читает заголовок файла CSV и возвращает чтение записей.
+// Пустой файл, неизвестные и повторяющиеся колонки возвращаются как errors.ValidationError
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	names, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.Validation("header", "файл пуст, ожидается строка заголовка")
+	}
+	var parseErr *csv.ParseError
+	if stderrors.As(err, &parseErr) {
+		return nil, errors.Validation("header", parseErr.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &csvReader{r: cr, header: make([]column, len(names))}
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		// табличные редакторы записывают в начало файла метку порядка байтов
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		col, ok := findColumn(name)
+		if !ok {
+			return nil, errors.Validation("header", fmt.Sprintf("неизвестная колонка %q", name))
+		}
+		if seen[name] {
+			return nil, errors.Validation("header", fmt.Sprintf("колонка %q указана дважды", name))
+		}
+		seen[name] = true
+		c.header[i] = col
+	}
+
+	return c, nil
+}
+
+// Метод next типа csvReader реализует recordReader.
+// Число полей каждой записи должно совпадать с числом колонок заголовка
+func (c *csvReader) next() (record, error) {
+	fields, err := c.r.Read()
+	if err == io.EOF {
+		return record{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if stderrors.As(err, &parseErr) {
+		return record{}, &recordError{line: parseErr.StartLine, err: errors.Validation("record", parseErr.Err.Error())}
+	}
+	if err != nil {
+		return record{}, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	rec := record{line: line}
+	for i, col := range c.header {
+		if col.set == nil {
+			continue
+		}
+		if err := col.set(&rec.req, strings.TrimSpace(fields[i])); err != nil {
+			return record{}, &recordError{line: line, err: err}
+		}
+	}
+
+	return rec, nil
+}
+
+// определяем структурный тип ndjsonReader - чтение записей файла NDJSON
+type ndjsonReader struct {
+	s    *bufio.Scanner
+	line int // номер последней прочитанной строки
+}
+
+// функция newNDJSONReader возвращает чтение записей файла NDJSON
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	return &ndjsonReader{s: s}
+}
+
+// определяем структурный тип ndjsonParcel - запись файла NDJSON:
+// посылка в представлении HTTP API вместе с вычисляемым объемным весом,
+// который добавляет models.Parcel.MarshalJSON
+type ndjsonParcel struct {
+	models.Parcel
+	VolumetricWeight int `json:"volumetric_weight"`
+}
+
+// Метод next типа ndjsonReader реализует recordReader. Пустые строки пропускаются
+func (n *ndjsonReader) next() (record, error) {
+	for n.s.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.s.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var p ndjsonParcel
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&p); err != nil {
+			return record{}, &recordError{line: n.line, err: errors.Validation("record", err.Error())}
+		}
+
+		return record{line: n.line, req: serv.RegisterRequest{
+			Client:        p.Client,
+			Sender:        p.Sender,
+			Recipient:     p.Recipient,
+			ReturnAddress: p.ReturnAddress,
+			ServiceLevel:  p.ServiceLevel,
+			Weight:        p.Weight,
+			Dimensions:    p.Dimensions,
+			Contents:      p.Contents,
+			DeclaredValue: p.DeclaredValue,
+		}}, nil
+	}
+
+	if err := n.s.Err(); err != nil {
+		return record{}, err
+	}
+
+	return record{}, io.EOF
+}
+
+// определяем структурный тип report - отчет об ошибках загрузки в формате CSV
+type report struct {
+	w *csv.Writer // nil - отчет не нужен
+}
+
+// функция newReport создает отчет в w и записывает его заголовок, для nil - пустой отчет
+func newReport(w io.Writer) (*report, error) {
+	if w == nil {
+		return &report{}, nil
+	}
+
+	r := &report{w: csv.NewWriter(w)}
+	if err := r.w.Write([]string{"line", "field", "error"}); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// метод add типа report записывает ошибку записи, начинающейся в строке line.
+// Ошибки записи в поток накапливаются и возвращаются методом close
+func (r *report) add(line int, err error) {
+	if r.w == nil {
+		return
+	}
+
+	field, reason := "", err.Error()
+	var validationErr *errors.ValidationError
+	if stderrors.As(err, &validationErr) {
+		field, reason = validationErr.Field, validationErr.Reason
+	}
+
+	r.w.Write([]string{strconv.Itoa(line), field, reason})
+}
+
+// метод close типа report дописывает отчет в поток и возвращает ошибку записи
+func (r *report) close() error {
+	if r.w == nil {
+		return nil
+	}
+
+	r.w.Flush()
+
+	return r.w.Error()
+}
@@ -0,0 +1,256 @@
+package transfer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/address"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+)
+
+// в пакете реализованы выгрузка посылок в файлы CSV и NDJSON и загрузка посылок из них.
+// Выгрузка читает посылки из хранилища по одной (см. ParcelService.Iterate),
+// загрузка регистрирует посылки пакетами через ParcelService с той же проверкой данных,
+// что и у команды register.
+//
+// Формат CSV: первая строка - заголовок с именами колонок, далее по строке на посылку.
+// Колонки выгрузки по порядку:
+//
+//	number             номер посылки                                 только выгрузка
+//	tracking_code      код отслеживания S10                          только выгрузка
+//	client             идентификатор клиента                         обязательна при загрузке
+//	status             статус посылки                                только выгрузка
+//	service_level      уровень обслуживания: standard или express    по умолчанию standard
+//	weight             вес в граммах                                 обязательна при загрузке
+//	length             длина в сантиметрах
+//	width              ширина в сантиметрах
+//	height             высота в сантиметрах
+//	contents           описание вложения
+//	declared_value     объявленная ценность в копейках
+//	cost               стоимость доставки в копейках                 только выгрузка
+//	sender_name        имя отправителя                               по умолчанию имя клиента
+//	sender_phone       телефон отправителя
+//	sender_address     адрес отправителя одной строкой
+//	recipient_name     имя получателя                                обязательна при загрузке
+//	recipient_phone    телефон получателя
+//	recipient_address  адрес доставки одной строкой                  обязательна при загрузке
+//	return_address     адрес возврата одной строкой
+//	created_at         дата и время создания, RFC 3339 (UTC)         только выгрузка
+//	updated_at         дата и время последнего изменения             только выгрузка
+//	sent_at            дата и время отправки, пусто - не отправлена  только выгрузка
+//	delivered_at       дата и время доставки, пусто - не доставлена  только выгрузка
+//
+// Адреса записываются так же, как их выводит models.Address.String, и разбираются
+// address.Parse. Колонки "только выгрузка" при загрузке допускаются и пропускаются:
+// выгруженный файл можно загрузить в другую БД, посылки получат новые номера,
+// коды отслеживания и стоимость по действующим тарифам. Порядок колонок при загрузке
+// не важен, отсутствующие колонки считаются пустыми, неизвестные - ошибкой.
+//
+// Формат NDJSON: по объекту JSON на строку, объект - как посылка в HTTP API (см. models.Parcel).
+// При загрузке используются те же поля, что и колонки CSV, остальные поля посылки пропускаются
+
+// форматы файлов
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// функция CheckFormat проверяет название формата файла
+func CheckFormat(format string) error {
+	if format != FormatCSV && format != FormatNDJSON {
+		return errors.Validation("format", fmt.Sprintf("ожидается %s или %s, получено %q", FormatCSV, FormatNDJSON, format))
+	}
+
+	return nil
+}
+
+// функция FormatOf возвращает формат файла по его расширению:
+// .ndjson и .jsonl - FormatNDJSON, остальные - FormatCSV
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	default:
+		return FormatCSV
+	}
+}
+
+// определяем структурный тип column - колонка файла CSV
+type column struct {
+	name string                                          // имя колонки в заголовке
+	get  func(p models.Parcel) string                    // значение колонки для выгрузки
+	set  func(req *serv.RegisterRequest, v string) error // перенос значения в запрос на регистрацию, nil - только выгрузка
+}
+
+// columns - колонки файла CSV в порядке выгрузки, см. описание пакета
+var columns = []column{
+	{name: "number", get: func(p models.Parcel) string { return strconv.Itoa(p.Number) }},
+	{name: "tracking_code", get: func(p models.Parcel) string { return p.TrackingCode }},
+	{
+		name: "client",
+		get:  func(p models.Parcel) string { return strconv.Itoa(p.Client) },
+		set:  func(req *serv.RegisterRequest, v string) error { return setInt(&req.Client, "client", v) },
+	},
+	{name: "status", get: func(p models.Parcel) string { return p.Status }},
+	{
+		name: "service_level",
+		get:  func(p models.Parcel) string { return p.ServiceLevel },
+		set:  func(req *serv.RegisterRequest, v string) error { req.ServiceLevel = v; return nil },
+	},
+	{
+		name: "weight",
+		get:  func(p models.Parcel) string { return strconv.Itoa(p.Weight) },
+		set:  func(req *serv.RegisterRequest, v string) error { return setInt(&req.Weight, "weight", v) },
+	},
+	{
+		name: "length",
+		get:  func(p models.Parcel) string { return optionalInt(p.Dimensions.Length) },
+		set:  func(req *serv.RegisterRequest, v string) error { return setInt(&req.Dimensions.Length, "length", v) },
+	},
+	{
+		name: "width",
+		get:  func(p models.Parcel) string { return optionalInt(p.Dimensions.Width) },
+		set:  func(req *serv.RegisterRequest, v string) error { return setInt(&req.Dimensions.Width, "width", v) },
+	},
+	{
+		name: "height",
+		get:  func(p models.Parcel) string { return optionalInt(p.Dimensions.Height) },
+		set:  func(req *serv.RegisterRequest, v string) error { return setInt(&req.Dimensions.Height, "height", v) },
+	},
+	{
+		name: "contents",
+		get:  func(p models.Parcel) string { return p.Contents },
+		set:  func(req *serv.RegisterRequest, v string) error { req.Contents = v; return nil },
+	},
+	{
+		name: "declared_value",
+		get:  func(p models.Parcel) string { return strconv.FormatInt(p.DeclaredValue, 10) },
+		set: func(req *serv.RegisterRequest, v string) error {
+			return setInt64(&req.DeclaredValue, "declared_value", v)
+		},
+	},
+	{name: "cost", get: func(p models.Parcel) string { return strconv.FormatInt(p.Cost, 10) }},
+	{
+		name: "sender_name",
+		get:  func(p models.Parcel) string { return p.Sender.Name },
+		set:  func(req *serv.RegisterRequest, v string) error { req.Sender.Name = v; return nil },
+	},
+	{
+		name: "sender_phone",
+		get:  func(p models.Parcel) string { return p.Sender.Phone },
+		set:  func(req *serv.RegisterRequest, v string) error { req.Sender.Phone = v; return nil },
+	},
+	{
+		name: "sender_address",
+		get:  func(p models.Parcel) string { return p.Sender.Address.String() },
+		set:  func(req *serv.RegisterRequest, v string) error { req.Sender.Address = address.Parse(v); return nil },
+	},
+	{
+		name: "recipient_name",
+		get:  func(p models.Parcel) string { return p.Recipient.Name },
+		set:  func(req *serv.RegisterRequest, v string) error { req.Recipient.Name = v; return nil },
+	},
+	{
+		name: "recipient_phone",
+		get:  func(p models.Parcel) string { return p.Recipient.Phone },
+		set:  func(req *serv.RegisterRequest, v string) error { req.Recipient.Phone = v; return nil },
+	},
+	{
+		name: "recipient_address",
+		get:  func(p models.Parcel) string { return p.Recipient.Address.String() },
+		set:  func(req *serv.RegisterRequest, v string) error { req.Recipient.Address = address.Parse(v); return nil },
+	},
+	{
+		name: "return_address",
+		get: func(p models.Parcel) string {
+			if p.ReturnAddress == nil {
+				return ""
+			}
+			return p.ReturnAddress.String()
+		},
+		set: func(req *serv.RegisterRequest, v string) error {
+			if v != "" {
+				a := address.Parse(v)
+				req.ReturnAddress = &a
+			}
+			return nil
+		},
+	},
+	{name: "created_at", get: func(p models.Parcel) string { return formatTime(&p.CreatedAt) }},
+	{name: "updated_at", get: func(p models.Parcel) string { return formatTime(&p.UpdatedAt) }},
+	{name: "sent_at", get: func(p models.Parcel) string { return formatTime(p.SentAt) }},
+	{name: "delivered_at", get: func(p models.Parcel) string { return formatTime(p.DeliveredAt) }},
+}
+
+// функция Header возвращает имена колонок файла CSV в порядке выгрузки
+func Header() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+
+	return names
+}
+
+// функция findColumn возвращает колонку по имени
+func findColumn(name string) (column, bool) {
+	for _, c := range columns {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return column{}, false
+}
+
+// функция optionalInt возвращает пустую строку для 0, иначе число:
+// нулевые габариты означают, что габариты не указаны
+func optionalInt(v int) string {
+	if v == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(v)
+}
+
+// функция formatTime возвращает время в формате RFC 3339 (UTC) или пустую строку для nil
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// функция setInt разбирает целое число колонки name в dst, пустое значение - 0
+func setInt(dst *int, name string, v string) error {
+	var n int64
+	if err := setInt64(&n, name, v); err != nil {
+		return err
+	}
+	*dst = int(n)
+
+	return nil
+}
+
+// функция setInt64 разбирает целое число колонки name в dst, пустое значение - 0
+func setInt64(dst *int64, name string, v string) error {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		*dst = 0
+		return nil
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return errors.Validation(name, fmt.Sprintf("ожидается целое число, получено %q", v))
+	}
+	*dst = n
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package transfer
+
+import (
+	// импортируем пакеты standard library
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	// импортируем пакеты third-party
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// импортируем локальные пакеты проекта
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/clock/clocktest"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/constants"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/models"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/errors"
+	serv "github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/service"
+	"github.com/Yandex-Practicum/go-db-sql-final/internal/parcel/store/storetest"
+)
+
+// newTestService возвращает сервис поверх хранилища в памяти с активным клиентом 1
+func newTestService(t *testing.T) serv.ParcelService {
+	stores := storetest.NewMemory()
+	client := storetest.Client().Add(t, stores.Clients)
+	require.Equal(t, 1, client.ID)
+
+	return serv.NewParcelService(stores.Parcels, stores.Clients,
+		serv.WithClock(clocktest.NewTicking(clocktest.Epoch, time.Hour)))
+}
+
+// функция registerParcels регистрирует посылки для выгрузки: с габаритами, отправителем
+// и адресом возврата, и отправленную посылку
+func registerParcels(t *testing.T, service serv.ParcelService) []models.Parcel {
+	ctx := context.Background()
+
+	returnAddress := models.Address{City: "Псков", Street: "ул. Почтовая", House: "3"}
+	first, err := service.Register(ctx, serv.RegisterRequest{
+		Client: 1,
+		Sender: models.Party{Name: "Склад, ООО", Phone: "+79990001122",
+			Address: models.Address{PostalCode: "180000", City: "Псков", Street: "ул. Лесная", House: "1"}},
+		Recipient: models.Party{Name: "Иванов \"мл.\"", Phone: "+79991234567",
+			Address: models.Address{City: "Москва", Street: "ул. Тверская", House: "7", Apartment: "12"}},
+		ReturnAddress: &returnAddress,
+		ServiceLevel:  constants.ServiceLevelExpress,
+		Weight:        1500,
+		Dimensions:    models.Dimensions{Length: 30, Width: 20, Height: 10},
+		Contents:      "книги",
+		DeclaredValue: 150000,
+	})
+	require.NoError(t, err)
+
+	second, err := service.Register(ctx, serv.RegisterRequest{
+		Client:    1,
+		Recipient: models.Party{Name: "Петров", Address: models.Address{City: "Псков", Street: "ул. Новая", House: "5"}},
+		Weight:    500,
+	})
+	require.NoError(t, err)
+	second, err = service.NextStatus(ctx, second.Number)
+	require.NoError(t, err)
+
+	return []models.Parcel{first, second}
+}
+
+// TestExportCSV проверяет колонки выгрузки в формате CSV
+func TestExportCSV(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+	parcels := registerParcels(t, service)
+
+	var buf bytes.Buffer
+	n, err := Export(ctx, service, models.ParcelFilter{}, FormatCSV, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, Header(), records[0])
+
+	// row возвращает значения строки по именам колонок
+	row := func(record []string) map[string]string {
+		m := make(map[string]string, len(record))
+		for i, name := range records[0] {
+			m[name] = record[i]
+		}
+		return m
+	}
+
+	first := row(records[1])
+	assert.Equal(t, "1", first["number"])
+	assert.Equal(t, parcels[0].TrackingCode, first["tracking_code"])
+	assert.Equal(t, "express", first["service_level"])
+	assert.Equal(t, "30", first["length"])
+	assert.Equal(t, "150000", first["declared_value"])
+	assert.Equal(t, "Иванов \"мл.\"", first["recipient_name"])
+	assert.Equal(t, "Москва, ул. Тверская, д. 7, кв. 12", first["recipient_address"])
+	assert.Equal(t, "Псков, ул. Почтовая, д. 3", first["return_address"])
+	assert.Equal(t, parcels[0].CreatedAt.UTC().Format(time.RFC3339), first["created_at"])
+	assert.Empty(t, first["sent_at"])
+
+	second := row(records[2])
+	assert.Equal(t, constants.ParcelStatusSent, second["status"])
+	assert.Empty(t, second["length"])
+	assert.Empty(t, second["return_address"])
+	assert.Equal(t, parcels[1].SentAt.UTC().Format(time.RFC3339), second["sent_at"])
+
+	// условия выборки
+	buf.Reset()
+	n, err = Export(ctx, service, models.ParcelFilter{Statuses: []string{constants.ParcelStatusSent}}, FormatCSV, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = Export(ctx, service, models.ParcelFilter{}, "xml", &buf)
+	require.ErrorIs(t, err, errors.ErrValidation)
+}
+
+// TestRoundTrip проверяет, что выгруженный файл загружается в другую БД
+// с теми же данными посылок
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			ctx := context.Background()
+			source := newTestService(t)
+			parcels := registerParcels(t, source)
+
+			var buf bytes.Buffer
+			_, err := Export(ctx, source, models.ParcelFilter{}, format, &buf)
+			require.NoError(t, err)
+
+			target := newTestService(t)
+			res, err := Import(ctx, target, &buf, ImportOptions{Format: format})
+			require.NoError(t, err)
+			assert.Equal(t, ImportResult{Records: 2, Imported: 2}, res)
+
+			page, err := target.List(ctx, models.ParcelFilter{})
+			require.NoError(t, err)
+			require.Len(t, page.Parcels, 2)
+			for i, p := range page.Parcels {
+				// загруженные посылки регистрируются заново
+				assert.Equal(t, constants.ParcelStatusRegistered, p.Status)
+				assert.Equal(t, parcels[i].Sender, p.Sender)
+				assert.Equal(t, parcels[i].Recipient, p.Recipient)
+				assert.Equal(t, parcels[i].ReturnAddress, p.ReturnAddress)
+				assert.Equal(t, parcels[i].ServiceLevel, p.ServiceLevel)
+				assert.Equal(t, parcels[i].Weight, p.Weight)
+				assert.Equal(t, parcels[i].Dimensions, p.Dimensions)
+				assert.Equal(t, parcels[i].Contents, p.Contents)
+				assert.Equal(t, parcels[i].DeclaredValue, p.DeclaredValue)
+				assert.Equal(t, parcels[i].Cost, p.Cost)
+			}
+		})
+	}
+}
+
+// TestImportErrors проверяет отчет об ошибках и пробную загрузку
+func TestImportErrors(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+
+	file := "client,recipient_name,recipient_address,weight\n" +
+		"1,Иванов,\"Псков, ул. Лесная, д. 1\",1000\n" +
+		"1,Петров,\"Псков, ул. Лесная, д. 2\",тяжелая\n" + // вес не число
+		"1,Сидоров,\"Псков, ул. Лесная, д. 3\"\n" + // не хватает поля
+		"2,Смирнов,\"Псков, ул. Лесная, д. 4\",1000\n" + // клиента 2 нет
+		"1,Кузнецов,\"Псков, ул. Лесная, д. 5\",1000\n"
+
+	// пробная загрузка проверяет записи, но не регистрирует посылки
+	var report bytes.Buffer
+	res, err := Import(ctx, service, strings.NewReader(file), ImportOptions{Format: FormatCSV, DryRun: true, Report: &report})
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Records: 5, Imported: 2, Failed: 3}, res)
+
+	page, err := service.List(ctx, models.ParcelFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Parcels)
+
+	rows, err := csv.NewReader(&report).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, []string{"line", "field", "error"}, rows[0])
+	assert.Equal(t, []string{"3", "weight"}, rows[1][:2])
+	assert.Equal(t, []string{"4", "record"}, rows[2][:2])
+	assert.Equal(t, []string{"5", "client"}, rows[3][:2])
+
+	// загрузка пакетами по 2 записи регистрирует корректные записи
+	report.Reset()
+	res, err = Import(ctx, service, strings.NewReader(file), ImportOptions{Format: FormatCSV, BatchSize: 2, Report: &report})
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Records: 5, Imported: 2, Failed: 3}, res)
+
+	page, err = service.List(ctx, models.ParcelFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Parcels, 2)
+	assert.Equal(t, "Иванов", page.Parcels[0].Recipient.Name)
+	assert.Equal(t, "Кузнецов", page.Parcels[1].Recipient.Name)
+
+	// NDJSON: некорректный JSON и неизвестное поле попадают в отчет, пустые строки пропускаются
+	report.Reset()
+	ndjson := `{"client": 1, "recipient": {"name": "Иванов", "address": {"city": "Псков", "street": "ул. Лесная", "house": "1"}}, "weight": 1000}
+
+{"client": 1,
+{"client": 1, "weight": 1000, "color": "red"}
+`
+	res, err = Import(ctx, service, strings.NewReader(ndjson), ImportOptions{Format: FormatNDJSON, Report: &report})
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Records: 3, Imported: 1, Failed: 2}, res)
+	rows, err = csv.NewReader(&report).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "3", rows[1][0])
+	assert.Equal(t, "4", rows[2][0])
+
+	// некорректный заголовок прерывает загрузку
+	for _, file := range []string{"", "client,color\n1,red\n", "client,client\n1,1\n"} {
+		_, err = Import(ctx, service, strings.NewReader(file), ImportOptions{Format: FormatCSV})
+		assert.ErrorIs(t, err, errors.ErrValidation, file)
+	}
+}
+
+// TestFormatOf проверяет выбор формата по расширению файла
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, FormatCSV, FormatOf("parcels.csv"))
+	assert.Equal(t, FormatNDJSON, FormatOf("parcels.ndjson"))
+	assert.Equal(t, FormatNDJSON, FormatOf("parcels.JSONL"))
+	assert.Equal(t, FormatCSV, FormatOf("parcels"))
+}